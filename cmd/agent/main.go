@@ -39,6 +39,12 @@ func run() error {
 	if err := cli.WithCrypto(cfg.CryptoKey); err != nil {
 		log.Printf("agent encrypt opt disabled")
 	}
+	if err := cli.WithLabels(cfg.Labels); err != nil {
+		log.Printf("agent labels opt disabled")
+	}
+	if err := cli.WithGRPC(cfg.GRPCAddress); err != nil {
+		log.Printf("agent grpc opt disabled")
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer stop()
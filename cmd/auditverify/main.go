@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/file"
+)
+
+func main() {
+	key := flag.String("k", "", "hmac key of the audit log, the lines are only checked for the chain when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-k key] audit.log | file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	files := flag.Args()
+	switch len(files) {
+	case 0:
+		flag.Usage()
+		os.Exit(2)
+	case 1:
+		// the single audit log is verified with its rotated files
+		rotated, err := file.Files(files[0])
+		if err != nil {
+			log.Fatalf("list files error: %s", err.Error())
+		}
+		if len(rotated) > 0 {
+			files = rotated
+		}
+	}
+
+	report, err := file.Verify(files, *key)
+	if err != nil {
+		log.Fatalf("verify error: %s", err.Error())
+	}
+
+	fmt.Printf("OK:\n")
+	fmt.Printf("  files: %d\n", len(files))
+	fmt.Printf("  lines: %d (seq %d..%d)\n", report.Lines, report.FirstSeq, report.LastSeq)
+	if report.Skipped > 0 {
+		fmt.Printf("  unchained: %d\n", report.Skipped)
+	}
+}
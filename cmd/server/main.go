@@ -48,6 +48,16 @@ func main() {
 
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
+	go func() {
+		for range hupCh {
+			log.Println("server reopening audit files")
+			di.Reopen(context.Background())
+		}
+	}()
+
 	<-stopCh
 	log.Println("server stoping")
 
@@ -26,18 +26,28 @@ import (
 	"sync"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/MaksimMakarenko1001/ya-go-advanced/api/proto"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
 )
 
 type Client struct {
-	httpClient *http.Client
-	config     Config
-	memStats   runtime.MemStats
-	pollCount  int64
-	backoff    *backoff.Backoff
-	cryptoKey  *rsa.PublicKey
+	httpClient  *http.Client
+	config      Config
+	memStats    runtime.MemStats
+	pollCount   int64
+	backoff     *backoff.Backoff
+	grpcBackoff *backoff.Backoff
+	cryptoKey   *rsa.PublicKey
+	grpcConn    *grpc.ClientConn
+	grpcClient  pb.MetricsClient
+	labels      pkg.Labels
 }
 
 func NewClient(cfg Config) *Client {
@@ -55,6 +65,10 @@ func NewClient(cfg Config) *Client {
 			cfg.MaxRetries,
 			ClassifyHTTPError,
 		),
+		grpcBackoff: backoff.NewBackoff(
+			cfg.MaxRetries,
+			ClassifyGRPCError,
+		),
 	}
 }
 
@@ -100,6 +114,44 @@ func (c *Client) sendBatchJSON(batch []models.Metric) (err error) {
 	return nil
 }
 
+func (c *Client) sendBatchGRPC(batch []models.Metric) (err error) {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	rq := &pb.UpdateBatchRequest{Metrics: make([]*pb.Metric, 0, len(batch))}
+	for _, metric := range batch {
+		rq.Metrics = append(rq.Metrics, &pb.Metric{
+			Id:     metric.ID,
+			Type:   metric.MType,
+			Labels: metric.Labels,
+			Delta:  metric.Delta,
+			Value:  metric.Value,
+		})
+	}
+
+	id := requestid.New()
+	fn := func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			requestid.MetadataKey, id,
+			requestid.TraceparentHeader, requestid.Traceparent(id),
+		)
+
+		_, sendErr := c.grpcClient.UpdateBatch(ctx, rq)
+		return sendErr
+	}
+
+	backoff := c.grpcBackoff.WithLinear(time.Second, time.Second*2)
+	if err := backoff(fn)(context.Background()); err != nil {
+		return fmt.Errorf("batch grpc not ok, %w", err)
+	}
+
+	return nil
+}
+
 func (c *Client) sendGaugeMetric(metricName string, value float64) (err error) {
 	valueStr := strconv.FormatFloat(value, 'f', -1, 64)
 
@@ -237,9 +289,9 @@ func (c *Client) collect(doneCh <-chan struct{}) <-chan models.Metric {
 			case <-poolTicker.C:
 				log.Println("Collects metrics")
 
-				collection = append(collection, genCounters(&c.pollCount)...)
-				collection = append(collection, genGauge(&c.memStats)...)
-				collection = append(collection, genExtraGauge()...)
+				collection = append(collection, withLabels(genCounters(&c.pollCount), c.labels)...)
+				collection = append(collection, withLabels(genGauge(&c.memStats), c.labels)...)
+				collection = append(collection, withLabels(genExtraGauge(), c.labels)...)
 				genChs = append(genChs, gen(doneCh, collection))
 			case <-reportTicker.C:
 				log.Println("Try to report metrics")
@@ -267,7 +319,12 @@ func (c *Client) sendWorker(id int, batchedCh <-chan []models.Metric, results ch
 	for batch := range batchedCh {
 		res := fmt.Sprintf("#%d: success", id)
 
-		err := c.sendBatchJSON(batch)
+		send := c.sendBatchJSON
+		if c.grpcClient != nil {
+			send = c.sendBatchGRPC
+		}
+
+		err := send(batch)
 		if err != nil {
 			res = fmt.Sprintf("#%d: fail, %s", id, err.Error())
 		}
@@ -305,6 +362,10 @@ func (c *Client) Run(ctx context.Context) error {
 	wg.Wait()
 	// stop iterating results
 	close(results)
+
+	if c.grpcConn != nil {
+		c.grpcConn.Close()
+	}
 	return ctx.Err()
 
 }
@@ -336,6 +397,17 @@ func (c *Client) send(req *http.Request) (int, error) {
 	r.Header.Set("Accept-Encoding", "gzip")
 	r.Header.Set("HashSHA256", hash)
 
+	// the ID is kept on the original request, so that retries share it
+	id := req.Header.Get(requestid.Header)
+	if id == "" {
+		id = requestid.New()
+		req.Header.Set(requestid.Header, id)
+	}
+	r.Header.Set(requestid.Header, id)
+	if traceparent := requestid.Traceparent(id); traceparent != "" {
+		r.Header.Set(requestid.TraceparentHeader, traceparent)
+	}
+
 	resp, err := c.httpClient.Do(r)
 
 	if err != nil {
@@ -409,3 +481,34 @@ func (c *Client) WithCrypto(path string) error {
 	c.cryptoKey = &private.PublicKey
 	return nil
 }
+
+func (c *Client) WithLabels(labels string) error {
+	parsed, err := pkg.ParseLabels(labels)
+	if err != nil {
+		return fmt.Errorf("parse labels error: %w", err)
+	}
+	if len(parsed) == 0 {
+		return errors.New("labels not set")
+	}
+
+	c.labels = parsed
+	return nil
+}
+
+func (c *Client) WithGRPC(address string) error {
+	if address == "" {
+		return errors.New("grpc address not set")
+	}
+
+	conn, err := grpc.NewClient(
+		address,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})),
+	)
+	if err != nil {
+		return fmt.Errorf("grpc client error: %w", err)
+	}
+
+	c.grpcConn = conn
+	c.grpcClient = pb.NewMetricsClient(conn)
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 
 type Config struct {
 	Address        string        `env:"ADDRESS" json:"address"`
+	GRPCAddress    string        `env:"GRPC_ADDRESS" json:"grpcAddress"`
 	Timeout        time.Duration `env:"TIMEOUT" envDefault:"10s" json:"timeout"`
 	BatchSize      int           `env:"BATCH_SIZE" envDefault:"3" json:"batchSize"`
 	MaxRetries     uint16        `env:"MAX_RETRIES" envDefault:"3" json:"maxRetries"`
@@ -21,6 +22,7 @@ type Config struct {
 	ReportInterval time.Duration `env:"REPORT_INTERVAL" json:"reportInterval"`
 	RateLimit      int           `env:"RATE_LIMIT" envDefault:"3" json:"rateLimit"`
 	CryptoKey      string        `env:"CRYPTO_KEY" json:"cryptoKey"`
+	Labels         string        `env:"LABELS" json:"labels"`
 	ConfigJSON     struct {
 		Config string `env:"CONFIG" json:"config"`
 	} `json:"configJSON"`
@@ -60,9 +62,11 @@ func (cfg *Config) loadFromJSON(envPrefix string) {
 
 	var config struct {
 		Address        string `json:"address"`
+		GRPCAddress    string `json:"grpc_address"`
 		ReportInterval string `json:"report_interval"`
 		PollInterval   string `json:"poll_interval"`
 		CryptoKey      string `json:"crypto_key"`
+		Labels         string `json:"labels"`
 	}
 
 	data, err := os.ReadFile(cfg.ConfigJSON.Config)
@@ -79,6 +83,9 @@ func (cfg *Config) loadFromJSON(envPrefix string) {
 	if address := config.Address; address != "" {
 		cfg.Address = address
 	}
+	if grpcAddress := config.GRPCAddress; grpcAddress != "" {
+		cfg.GRPCAddress = grpcAddress
+	}
 	if reportInterval := config.ReportInterval; reportInterval != "" {
 		if report, err := time.ParseDuration(reportInterval); err == nil {
 			cfg.ReportInterval = report
@@ -92,30 +99,40 @@ func (cfg *Config) loadFromJSON(envPrefix string) {
 	if cryptoKey := config.CryptoKey; cryptoKey != "" {
 		cfg.CryptoKey = cryptoKey
 	}
+	if labels := config.Labels; labels != "" {
+		cfg.Labels = labels
+	}
 }
 
 func (cfg *Config) loadFromArg() {
 	var config struct {
-		Address   string
-		Pool      int
-		Report    int
-		Key       string
-		RateLimit int
-		CryptoKey string
+		Address     string
+		GRPCAddress string
+		Pool        int
+		Report      int
+		Key         string
+		RateLimit   int
+		CryptoKey   string
+		Labels      string
 	}
 
 	flag.StringVar(&config.Address, "a", "", "agent net address")
+	flag.StringVar(&config.GRPCAddress, "grpc-address", "", "agent grpc net address")
 	flag.IntVar(&config.Pool, "p", 0, "pool interval in seconds")
 	flag.IntVar(&config.Report, "r", 0, "report interval in seconds")
 	flag.StringVar(&config.Key, "k", "", "hash key")
 	flag.IntVar(&config.RateLimit, "l", 0, "num threads work concurrently")
 	flag.StringVar(&config.CryptoKey, "crypto-key", "", "crypto key path")
+	flag.StringVar(&config.Labels, "labels", "", "metric labels, e.g. host=a,service=b")
 
 	flag.Parse()
 
 	if address := config.Address; address != "" {
 		cfg.Address = address
 	}
+	if grpcAddress := config.GRPCAddress; grpcAddress != "" {
+		cfg.GRPCAddress = grpcAddress
+	}
 	if pool := config.Pool; pool > 0 {
 		cfg.PollInterval = time.Second * time.Duration(pool)
 	}
@@ -131,6 +148,9 @@ func (cfg *Config) loadFromArg() {
 	if cryptoKey := config.CryptoKey; cryptoKey != "" {
 		cfg.CryptoKey = cryptoKey
 	}
+	if labels := config.Labels; labels != "" {
+		cfg.Labels = labels
+	}
 }
 
 func (cfg *Config) loadFromEnv(envPrefix string) {
@@ -144,6 +164,9 @@ func (cfg *Config) loadFromEnv(envPrefix string) {
 	if address := config.Address; address != "" {
 		cfg.Address = address
 	}
+	if grpcAddress := config.GRPCAddress; grpcAddress != "" {
+		cfg.GRPCAddress = grpcAddress
+	}
 	if pool := config.PollInterval; pool.String() != "0s" {
 		cfg.PollInterval = pool
 	}
@@ -156,12 +179,21 @@ func (cfg *Config) loadFromEnv(envPrefix string) {
 	if cryptoKey := config.CryptoKey; cryptoKey != "" {
 		cfg.CryptoKey = cryptoKey
 	}
+	if labels := config.Labels; labels != "" {
+		cfg.Labels = labels
+	}
 }
 
 func (cfg *Config) loadFromEnvPassTests() {
 	if address := os.Getenv("ADDRESS"); address != "" {
 		cfg.Address = address
 	}
+	if grpcAddress := os.Getenv("GRPC_ADDRESS"); grpcAddress != "" {
+		cfg.GRPCAddress = grpcAddress
+	}
+	if labels := os.Getenv("LABELS"); labels != "" {
+		cfg.Labels = labels
+	}
 	if key := os.Getenv("KEY"); key != "" {
 		cfg.Key = key
 	}
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"net/url"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
 )
 
@@ -19,3 +22,18 @@ func ClassifyHTTPError(err error) backoff.ErrorClassification {
 
 	return backoff.NonRetriable
 }
+
+func ClassifyGRPCError(err error) backoff.ErrorClassification {
+	if err == nil {
+		return backoff.NonRetriable
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable,
+		codes.DeadlineExceeded,
+		codes.ResourceExhausted:
+		return backoff.Retriable
+	}
+
+	return backoff.NonRetriable
+}
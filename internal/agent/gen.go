@@ -212,6 +212,13 @@ func genExtraGauge() []models.Metric {
 	return pkg.SliceFilter(slice, func(x models.Metric) bool { return x.Value != nil })
 }
 
+func withLabels(metrics []models.Metric, labels pkg.Labels) []models.Metric {
+	for i := range metrics {
+		metrics[i].Labels = labels
+	}
+	return metrics
+}
+
 func gen(doneCh <-chan struct{}, input []models.Metric) <-chan models.Metric {
 	ch := make(chan models.Metric)
 	go func() {
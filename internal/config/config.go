@@ -11,34 +11,54 @@ import (
 	"github.com/caarlos0/env/v6"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/config/db"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/grpchandler"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/logger"
-	auditFileService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/auditFileService/v0"
-	auditRemoteService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/auditRemoteService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox/journal"
+	alertService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/alertService/v0"
 	decryptService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/decryptService/v0"
 	dumpMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/dumpMetricService/v0"
 	hashService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/hashService/v0"
+	retentionService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/retentionService/v0"
+	selfMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/selfMetricService/v0"
+	updateHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateHistogramService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/worker/sworker"
 )
 
 type diConfig struct {
-	HTTP               HTTPServerConfig          `envPrefix:"HTTP_" json:"http"`
-	Logger             logger.Config             `envPrefix:"LOGGER_" json:"logger"`
-	StoreInterval      time.Duration             `env:"STORE_INTERVAL" json:"storeInterval"`
-	FileStoragePath    string                    `env:"FILE_STORAGE_PATH" json:"fileStoragePath"`
-	Restore            bool                      `env:"RESTORE" json:"restore"`
-	Database           db.Config                 `envPrefix:"DATABASE_" json:"database"`
-	HashService        hashService.Config        `envPrefix:"HASH_SERVICE_" json:"hashService"`
-	DecryptService     decryptService.Config     `envPrefix:"DECRYPT_SERVICE_" json:"decryptService"`
-	DumpService        dumpMetricService.Config  `envPrefix:"DUMP_SERVICE_" json:"dumpService"`
-	DumpSyncService    dumpMetricService.Config  `envPrefix:"DUMP_SYNC_SERVICE_" json:"dumpSyncService"`
-	AuditFileService   auditFileService.Config   `envPrefix:"AUDIT_FILE_SERVICE_" json:"auditFileService"`
-	AuditRemoteService auditRemoteService.Config `envPrefix:"AUDIT_REMOTE_SERVICE_" json:"auditRemoteService"`
-	Worker             struct {
-		AuditFile   sworker.Config `envPrefix:"AUDIT_FILE_" json:"auditFile"`
-		AuditRemote sworker.Config `envPrefix:"AUDIT_REMOTE_" json:"auditRemote"`
+	HTTP                   HTTPServerConfig              `envPrefix:"HTTP_" json:"http"`
+	GRPC                   GRPCServerConfig              `envPrefix:"GRPC_" json:"grpc"`
+	GRPCHandler            grpchandler.Config            `envPrefix:"GRPC_HANDLER_" json:"grpcHandler"`
+	Logger                 logger.Config                 `envPrefix:"LOGGER_" json:"logger"`
+	Tracing                tracing.Config                `envPrefix:"TRACING_" json:"tracing"`
+	StoreInterval          time.Duration                 `env:"STORE_INTERVAL" json:"storeInterval"`
+	FileStoragePath        string                        `env:"FILE_STORAGE_PATH" json:"fileStoragePath"`
+	Restore                bool                          `env:"RESTORE" json:"restore"`
+	Database               db.Config                     `envPrefix:"DATABASE_" json:"database"`
+	Outbox                 outbox.Config                 `envPrefix:"OUTBOX_" json:"outbox"`
+	OutboxJournal          journal.Config                `envPrefix:"OUTBOX_JOURNAL_" json:"outboxJournal"`
+	HashService            hashService.Config            `envPrefix:"HASH_SERVICE_" json:"hashService"`
+	UpdateHistogramService updateHistogramService.Config `envPrefix:"UPDATE_HISTOGRAM_SERVICE_" json:"updateHistogramService"`
+	DecryptService         decryptService.Config         `envPrefix:"DECRYPT_SERVICE_" json:"decryptService"`
+	DumpService            dumpMetricService.Config      `envPrefix:"DUMP_SERVICE_" json:"dumpService"`
+	DumpSyncService        dumpMetricService.Config      `envPrefix:"DUMP_SYNC_SERVICE_" json:"dumpSyncService"`
+	AlertService           alertService.Config           `envPrefix:"ALERT_SERVICE_" json:"alertService"`
+	RetentionService       retentionService.Config       `envPrefix:"RETENTION_SERVICE_" json:"retentionService"`
+	SelfMetricService      selfMetricService.Config      `envPrefix:"SELF_METRIC_SERVICE_" json:"selfMetricService"`
+	Worker                 struct {
+		Audit       sworker.Config `envPrefix:"AUDIT_" json:"audit"`
+		Alert       sworker.Config `envPrefix:"ALERT_" json:"alert"`
+		Notify      sworker.Config `envPrefix:"NOTIFY_" json:"notify"`
+		Webhook     sworker.Config `envPrefix:"WEBHOOK_" json:"webhook"`
+		Retention   sworker.Config `envPrefix:"RETENTION_" json:"retention"`
+		SelfMetrics sworker.Config `envPrefix:"SELF_METRICS_" json:"selfMetrics"`
 	} `envPrefix:"WORKER_" json:"worker"`
-	AuditFile   string `env:"AUDIT_FILE" json:"auditFile"`
-	AuditRemote string `env:"AUDIT_URL" json:"auditRemote"`
+	Receivers   []models.Receiver  `json:"receivers"`
+	AuditSinks  []models.AuditSink `json:"auditSinks"`
+	AuditFile   string             `env:"AUDIT_FILE" json:"auditFile"`
+	AuditRemote string             `env:"AUDIT_URL" json:"auditRemote"`
 	ConfigJSON  struct {
 		Config string `env:"CONFIG" json:"config"`
 	} `json:"configJSON"`
@@ -58,15 +78,32 @@ func (cfg *diConfig) loadConfig(envPrefix string) {
 		cfg.DumpService.ReadDumpEnable = false
 		cfg.DumpService.WriteDumpEnable = false
 	}
-	if cfg.AuditFile == "" {
-		cfg.AuditFileService.AuditEnabled = false
+	if cfg.AuditFile != "" {
+		cfg.addAuditSink(models.AuditSink{Name: "file", Type: models.FileSinkType, Path: cfg.AuditFile})
 	}
-	if cfg.AuditRemote == "" {
-		cfg.AuditRemoteService.AuditEnabled = false
+	if cfg.AuditRemote != "" {
+		cfg.addAuditSink(models.AuditSink{Name: "remote", Type: models.HTTPSinkType, URL: cfg.AuditRemote})
 	}
 	if cfg.DecryptService.CryptoKey == "" {
 		cfg.DecryptService.DecryptEnabled = false
 	}
+	if cfg.Tracing.Exporter == tracing.ExporterStdout {
+		if cfg.Tracing.OTLPEndpoint != "" {
+			cfg.Tracing.Exporter = tracing.ExporterOTLP
+		} else if cfg.Tracing.FilePath != "" {
+			cfg.Tracing.Exporter = tracing.ExporterFile
+		}
+	}
+}
+
+// addAuditSink adds the sink unless a sink with the same name is configured already.
+func (cfg *diConfig) addAuditSink(sink models.AuditSink) {
+	for _, s := range cfg.AuditSinks {
+		if s.Name == sink.Name {
+			return
+		}
+	}
+	cfg.AuditSinks = append(cfg.AuditSinks, sink)
 }
 
 func (cfg *diConfig) loadDefaults(envPrefix string) {
@@ -94,12 +131,32 @@ func (cfg *diConfig) loadFromJSON(envPrefix string) {
 	}
 
 	var config struct {
-		Address       string `json:"address"`
-		Restore       bool   `json:"restore"`
-		StoreInterval string `json:"store_interval"`
-		StoreFile     string `json:"store_file"`
-		DatabaseDsn   string `json:"database_dsn"`
-		CryptoKey     string `json:"crypto_key"`
+		Address       string             `json:"address"`
+		GRPCAddress   string             `json:"grpc_address"`
+		Restore       bool               `json:"restore"`
+		StoreInterval string             `json:"store_interval"`
+		StoreFile     string             `json:"store_file"`
+		DatabaseDsn   string             `json:"database_dsn"`
+		CryptoKey     string             `json:"crypto_key"`
+		RetentionTTL  string             `json:"retention_ttl"`
+		HistoryTTL    string             `json:"history_retention_ttl"`
+		SelfMetrics   bool               `json:"self_metrics_store"`
+		AlertRules    []string           `json:"alert_rules"`
+		Receivers     []models.Receiver  `json:"receivers"`
+		AuditSinks    []models.AuditSink `json:"audit_sinks"`
+		Outbox        struct {
+			LockTimeout   string `json:"lock_timeout"`
+			MaxAttempts   *int   `json:"max_attempts"`
+			RetryDelay    string `json:"retry_delay"`
+			MaxRetryDelay string `json:"max_retry_delay"`
+			JournalDir    string `json:"journal_dir"`
+			Segments      int    `json:"segments"`
+		} `json:"outbox"`
+		Tracing struct {
+			Exporter     string `json:"exporter"`
+			File         string `json:"file"`
+			OTLPEndpoint string `json:"otlp_endpoint"`
+		} `json:"tracing"`
 	}
 
 	data, err := os.ReadFile(cfg.ConfigJSON.Config)
@@ -116,6 +173,9 @@ func (cfg *diConfig) loadFromJSON(envPrefix string) {
 	if address := config.Address; address != "" {
 		cfg.HTTP.Address = address
 	}
+	if grpcAddress := config.GRPCAddress; grpcAddress != "" {
+		cfg.GRPC.Address = grpcAddress
+	}
 	if restore := config.Restore; restore {
 		cfg.Restore = restore
 	}
@@ -133,11 +193,61 @@ func (cfg *diConfig) loadFromJSON(envPrefix string) {
 			cfg.StoreInterval = store
 		}
 	}
+	if retentionTTL := config.RetentionTTL; retentionTTL != "" {
+		if ttl, err := time.ParseDuration(retentionTTL); err == nil && ttl > 0 {
+			cfg.RetentionService.TTL = ttl
+		}
+	}
+	if historyTTL := config.HistoryTTL; historyTTL != "" {
+		if ttl, err := time.ParseDuration(historyTTL); err == nil && ttl > 0 {
+			cfg.RetentionService.HistoryTTL = ttl
+		}
+	}
+	if selfMetrics := config.SelfMetrics; selfMetrics {
+		cfg.SelfMetricService.StoreEnabled = selfMetrics
+	}
+	if alertRules := config.AlertRules; len(alertRules) > 0 {
+		cfg.AlertService.Rules = alertRules
+	}
+	if receivers := config.Receivers; len(receivers) > 0 {
+		cfg.Receivers = receivers
+	}
+	if sinks := config.AuditSinks; len(sinks) > 0 {
+		cfg.AuditSinks = sinks
+	}
+	if lockTimeout, err := time.ParseDuration(config.Outbox.LockTimeout); err == nil && lockTimeout > 0 {
+		cfg.Outbox.LockTimeout = lockTimeout
+	}
+	if maxAttempts := config.Outbox.MaxAttempts; maxAttempts != nil {
+		cfg.Outbox.MaxAttempts = *maxAttempts
+	}
+	if retryDelay, err := time.ParseDuration(config.Outbox.RetryDelay); err == nil && retryDelay > 0 {
+		cfg.Outbox.RetryDelay = retryDelay
+	}
+	if maxRetryDelay, err := time.ParseDuration(config.Outbox.MaxRetryDelay); err == nil && maxRetryDelay > 0 {
+		cfg.Outbox.MaxRetryDelay = maxRetryDelay
+	}
+	if segments := config.Outbox.Segments; segments > 0 {
+		cfg.Outbox.Segments = segments
+	}
+	if journalDir := config.Outbox.JournalDir; journalDir != "" {
+		cfg.OutboxJournal.Dir = journalDir
+	}
+	if exporter := config.Tracing.Exporter; exporter != "" {
+		cfg.Tracing.Exporter = tracing.Exporter(exporter)
+	}
+	if file := config.Tracing.File; file != "" {
+		cfg.Tracing.FilePath = file
+	}
+	if endpoint := config.Tracing.OTLPEndpoint; endpoint != "" {
+		cfg.Tracing.OTLPEndpoint = endpoint
+	}
 }
 
 func (cfg *diConfig) loadFromArg() {
 	var config struct {
 		Address         string
+		GRPCAddress     string
 		Store           int
 		FileStoragePath string
 		Restore         bool
@@ -149,6 +259,7 @@ func (cfg *diConfig) loadFromArg() {
 	}
 
 	flag.StringVar(&config.Address, "a", "", "server net address")
+	flag.StringVar(&config.GRPCAddress, "grpc-address", "", "grpc server net address")
 	flag.IntVar(&config.Store, "i", 0, "store interval in seconds")
 	flag.StringVar(&config.FileStoragePath, "f", "", "dump file path")
 	flag.BoolVar(&config.Restore, "r", false, "restore dump file on start")
@@ -163,6 +274,9 @@ func (cfg *diConfig) loadFromArg() {
 	if address := config.Address; address != "" {
 		cfg.HTTP.Address = address
 	}
+	if grpcAddress := config.GRPCAddress; grpcAddress != "" {
+		cfg.GRPC.Address = grpcAddress
+	}
 	if store := config.Store; store > 0 {
 		cfg.StoreInterval = time.Second * time.Duration(store)
 	}
@@ -200,6 +314,9 @@ func (cfg *diConfig) loadFromEnv(envPrefix string) {
 	if address := config.HTTP.Address; address != "" {
 		cfg.HTTP.Address = address
 	}
+	if grpcAddress := config.GRPC.Address; grpcAddress != "" {
+		cfg.GRPC.Address = grpcAddress
+	}
 	if store := config.StoreInterval; store.String() != "0s" {
 		cfg.StoreInterval = store
 	}
@@ -218,15 +335,60 @@ func (cfg *diConfig) loadFromEnv(envPrefix string) {
 	if cryptoKey := config.DecryptService.CryptoKey; cryptoKey != "" {
 		cfg.DecryptService.CryptoKey = cryptoKey
 	}
+	if alertRules := config.AlertService.Rules; len(alertRules) > 0 {
+		cfg.AlertService.Rules = alertRules
+	}
+	if ttl := config.RetentionService.TTL; ttl > 0 {
+		cfg.RetentionService.TTL = ttl
+	}
+	if ttl := config.RetentionService.HistoryTTL; ttl > 0 {
+		cfg.RetentionService.HistoryTTL = ttl
+	}
+	if selfMetrics := config.SelfMetricService.StoreEnabled; selfMetrics {
+		cfg.SelfMetricService.StoreEnabled = selfMetrics
+	}
 	if dsn, err := config.Database.ToDSN(); err == nil {
 		cfg.Database.DSN = dsn
 	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_LOCK_TIMEOUT"); ok {
+		cfg.Outbox.LockTimeout = config.Outbox.LockTimeout
+	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_MAX_ATTEMPTS"); ok {
+		cfg.Outbox.MaxAttempts = config.Outbox.MaxAttempts
+	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_RETRY_DELAY"); ok {
+		cfg.Outbox.RetryDelay = config.Outbox.RetryDelay
+	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_MAX_RETRY_DELAY"); ok {
+		cfg.Outbox.MaxRetryDelay = config.Outbox.MaxRetryDelay
+	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_SEGMENTS"); ok {
+		cfg.Outbox.Segments = config.Outbox.Segments
+	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_JOURNAL_DIR"); ok {
+		cfg.OutboxJournal.Dir = config.OutboxJournal.Dir
+	}
+	if _, ok := os.LookupEnv(envPrefix + "OUTBOX_JOURNAL_SEGMENT_SIZE"); ok {
+		cfg.OutboxJournal.SegmentSize = config.OutboxJournal.SegmentSize
+	}
+	if exporter, ok := os.LookupEnv(envPrefix + "TRACING_EXPORTER"); ok && exporter != "" {
+		cfg.Tracing.Exporter = tracing.Exporter(exporter)
+	}
+	if file := config.Tracing.FilePath; file != "" {
+		cfg.Tracing.FilePath = file
+	}
+	if endpoint := config.Tracing.OTLPEndpoint; endpoint != "" {
+		cfg.Tracing.OTLPEndpoint = endpoint
+	}
 }
 
 func (cfg *diConfig) loadFromEnvToPassTests() {
 	if address := os.Getenv("ADDRESS"); address != "" {
 		cfg.HTTP.Address = address
 	}
+	if grpcAddress := os.Getenv("GRPC_ADDRESS"); grpcAddress != "" {
+		cfg.GRPC.Address = grpcAddress
+	}
 	if fname := os.Getenv("FILE_STORAGE_PATH"); fname != "" {
 		cfg.FileStoragePath = fname
 	}
@@ -258,3 +420,7 @@ func (cfg *diConfig) loadFromEnvToPassTests() {
 type HTTPServerConfig struct {
 	Address string `env:"ADDRESS" json:"address"`
 }
+
+type GRPCServerConfig struct {
+	Address string `env:"ADDRESS" json:"address"`
+}
@@ -11,8 +11,12 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
 )
 
 type PGConnect struct {
@@ -58,7 +62,10 @@ func (pg *PGConnect) Ping(ctx context.Context) error {
 
 func (pg *PGConnect) QueryNoResult(
 	ctx context.Context, query string, args ...any,
-) error {
+) (err error) {
+	ctx, span := startQuery(ctx, "PGConnect.QueryNoResult", query)
+	defer func() { tracing.End(span, err) }()
+
 	fn := func(ctx context.Context) error {
 		_, err := pg.db.ExecContext(ctx, query, args...)
 		return err
@@ -70,7 +77,10 @@ func (pg *PGConnect) QueryNoResult(
 
 func (pg *PGConnect) QueryWithOneResult(
 	ctx context.Context, dst any, query string, args ...any,
-) error {
+) (err error) {
+	ctx, span := startQuery(ctx, "PGConnect.QueryWithOneResult", query)
+	defer func() { tracing.End(span, err) }()
+
 	fn := func(ctx context.Context) error {
 		row := pg.db.QueryRowContext(ctx, query, args...)
 
@@ -107,6 +117,17 @@ func (pg *PGConnect) QueryWithOneResultJSON(
 	return nil
 }
 
+// startQuery starts the span of a query, the backoff adds its failed attempts as events.
+// The span carries the request ID of the context, the query text is left intact,
+// so that the prepared statements are reused across the requests.
+func startQuery(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.query.text", query),
+		attribute.String("request.id", requestid.FromContext(ctx)),
+	)
+}
+
 func (pg *PGConnect) Close() error {
 	if pg.db == nil {
 		return nil
@@ -40,3 +40,12 @@ func ClassifyPgError(err error) backoff.ErrorClassification {
 
 	return backoff.NonRetriable
 }
+
+// PgErrorCode returns the SQLSTATE code of a Postgres error, or an empty string for other errors.
+func PgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	return pgErr.Code
+}
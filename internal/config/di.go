@@ -4,66 +4,131 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/config/db"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/grpchandler"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/handler"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/logger"
-	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/file"
-	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/remote"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/sink"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/encode"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/notify/webhook"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox/journal"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/silence"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/inmemory"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/pg"
-	auditFileService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/auditFileService/v0"
-	auditRemoteService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/auditRemoteService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/self"
+	alertService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/alertService/v0"
+	auditService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/auditService/v0"
+	deadLetterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/deadLetterService/v0"
 	decryptService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/decryptService/service"
 	decryptServiceV0 "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/decryptService/v0"
+	deleteMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/deleteMetricService/v0"
 	dumpMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/dumpMetricService/v0"
+	exportMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/exportMetricService/v0"
 	getCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getCounterService/v0"
 	getFlatService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getFlatService/v0"
 	getGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getGaugeService/v0"
+	getHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getHistogramService/v0"
 	getService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getService/v0"
 	hashService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/hashService/v0"
+	historyService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/historyService/v0"
 	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	notifyService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/notifyService/v0"
+	remoteWriteService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/remoteWriteService/v0"
+	retentionService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/retentionService/v0"
+	selfMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/selfMetricService/v0"
+	silenceService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/silenceService/v0"
 	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
 	updateCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateCounterService/v0"
 	updateFlatService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateFlatService/v0"
 	updateGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateGaugeService/v0"
+	updateHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateHistogramService/v0"
 	updateService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateService/v0"
+	webhookService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/webhookService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/worker/sworker"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
 )
 
+// outboxRepository is implemented by the database outbox and by the journal
+// the server falls back to when the database is not configured.
+type outboxRepository interface {
+	OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) (err error)
+	OutboxGetNext(
+		ctx context.Context, destination models.OutboxDestination, segment string, limit int,
+	) (resp []entities.Outbox, err error)
+	OutboxCommit(
+		ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string,
+	) (err error)
+	OutboxFanout(
+		ctx context.Context, items []entities.Outbox, okIds []entities.OutboxID, segment string,
+	) (err error)
+	OutboxBacklog(ctx context.Context) (resp []entities.OutboxBacklog, err error)
+	DeadLetterList(ctx context.Context, destination string, limit int) (resp []entities.DeadLetter, err error)
+	DeadLetterRequeue(
+		ctx context.Context, ids []entities.OutboxID, destination string,
+	) (requeued []entities.OutboxID, err error)
+	DeadLetterPurge(
+		ctx context.Context, ids []entities.OutboxID, destination string,
+	) (purged []entities.OutboxID, err error)
+}
+
 type DI struct {
 	config       *diConfig
 	logger       *logger.ZapLogger
+	tracer       *tracing.Provider
 	httpServer   *http.Server
+	grpcServer   *grpc.Server
 	repositories struct {
 		encoder         *encode.JSONEncode
 		inmemoryStorage *inmemory.Repository
 		pgStorage       *pg.Repository
-		outbox          *outbox.Repository
-		fileAuditor     *file.Repository
-		remoteAuditor   *remote.Repository
+		selfStorage     *self.Repository
+		outbox          outboxRepository
+		outboxJournal   *journal.Repository
+		auditSinks      *sink.Repository
+		webhookNotifier *webhook.Repository
+		silence         *silence.Repository
 	}
 	services struct {
 		included struct {
-			updateCounterService *updateCounterService.Service
-			updateGaugeService   *updateGaugeService.Service
+			updateCounterService   *updateCounterService.Service
+			updateGaugeService     *updateGaugeService.Service
+			updateHistogramService *updateHistogramService.Service
 
-			getCounterService *getCounterService.Service
-			getGaugeService   *getGaugeService.Service
+			getCounterService   *getCounterService.Service
+			getGaugeService     *getGaugeService.Service
+			getHistogramService *getHistogramService.Service
 		}
 		updateFlatService  *updateFlatService.Service
 		updateBatchService *updateBatchService.Service
 		updateService      *updateService.Service
+		remoteWriteService *remoteWriteService.Service
 
 		getFlatService *getFlatService.Service
 		getService     *getService.Service
 
-		listMetricService *listMetricService.Service
+		listMetricService   *listMetricService.Service
+		exportMetricService *exportMetricService.Service
+		selfExportService   *exportMetricService.Service
+		selfMetricService   *selfMetricService.Service
+
+		historyService *historyService.Service
+
+		deleteMetricService *deleteMetricService.Service
+		retentionService    *retentionService.Service
 
 		dumpMetricService     *dumpMetricService.Service
 		dumpSyncMetricService *dumpMetricService.Service
@@ -71,15 +136,25 @@ type DI struct {
 		hashService    *hashService.Service
 		decryptService decryptService.DecryptService
 
-		auditFileService   *auditFileService.Service
-		auditRemoteService *auditRemoteService.Service
+		auditService *auditService.Service
+
+		alertService      *alertService.Service
+		silenceService    *silenceService.Service
+		deadLetterService *deadLetterService.Service
+		notifyService     *notifyService.Service
+		webhookService    *webhookService.Service
 	}
 	workers struct {
-		auditFile   *sworker.SimpleWorker
-		auditRemote *sworker.SimpleWorker
+		audit       []*sworker.SimpleWorker
+		alert       *sworker.SimpleWorker
+		notify      *sworker.SimpleWorker
+		webhook     *sworker.SimpleWorker
+		retention   *sworker.SimpleWorker
+		selfMetrics *sworker.SimpleWorker
 	}
 	api struct {
 		external *handler.API
+		grpc     *grpchandler.API
 	}
 	infr struct {
 		db *db.PGConnect
@@ -91,6 +166,7 @@ func (di *DI) Init(envPrefix string) {
 	di.config.loadConfig(envPrefix)
 
 	di.InitLogging()
+	di.initTracing()
 	di.initDB()
 	di.initRepositories()
 	di.initServices()
@@ -106,6 +182,14 @@ func (di *DI) InitLogging() {
 	}
 }
 
+func (di *DI) initTracing() {
+	var err error
+	di.tracer, err = tracing.New(di.config.Tracing)
+	if err != nil {
+		log.Println("tracing init not ok,", err.Error())
+	}
+}
+
 func (di *DI) initDB() {
 	var err error
 	di.infr.db, err = db.New(
@@ -120,31 +204,66 @@ func (di *DI) initDB() {
 func (di *DI) initRepositories() {
 	di.repositories.encoder = encode.New()
 	di.repositories.inmemoryStorage = inmemory.New(di.repositories.encoder)
-	di.repositories.pgStorage = pg.New(di.infr.db, di.repositories.inmemoryStorage)
-	di.repositories.outbox = outbox.New(di.infr.db)
-	di.repositories.fileAuditor = file.New(di.config.AuditFile)
-	di.repositories.remoteAuditor = remote.New(di.config.AuditRemote)
+	di.initOutbox()
+	di.repositories.pgStorage = pg.New(di.infr.db, di.repositories.inmemoryStorage, di.repositories.outbox)
+	di.repositories.selfStorage = self.New(selfmetrics.Default)
+	di.repositories.auditSinks = sink.New(di.config.AuditSinks, di.config.HashService.Key)
+	di.repositories.webhookNotifier = webhook.New(di.config.Receivers)
+	di.repositories.silence = silence.New(di.infr.db)
+}
+
+// initOutbox keeps the outbox in the journal when the database is not configured,
+// so the audit and notifications are not lost in the inmemory mode.
+func (di *DI) initOutbox() {
+	if di.infr.db != nil {
+		di.repositories.outbox = outbox.New(di.config.Outbox, di.infr.db)
+		return
+	}
+
+	var err error
+	di.repositories.outboxJournal, err = journal.New(di.config.Outbox, di.config.OutboxJournal)
+	if err != nil {
+		log.Println("outbox journal init not ok,", err.Error())
+		di.repositories.outbox = outbox.New(di.config.Outbox, nil)
+		return
+	}
+	di.repositories.outbox = di.repositories.outboxJournal
 }
 
 func (di *DI) initServices() {
-	di.services.included.updateCounterService = updateCounterService.New(di.repositories.pgStorage)
-	di.services.included.updateGaugeService = updateGaugeService.New(di.repositories.pgStorage)
+	di.services.updateBatchService = updateBatchService.New(di.config.Outbox.Segments, di.repositories.pgStorage)
+
+	di.services.included.updateCounterService = updateCounterService.New(di.services.updateBatchService)
+	di.services.included.updateGaugeService = updateGaugeService.New(di.services.updateBatchService)
+	di.services.included.updateHistogramService = updateHistogramService.New(di.config.UpdateHistogramService,
+		di.services.updateBatchService)
 
 	di.services.included.getCounterService = getCounterService.New(di.repositories.pgStorage)
 	di.services.included.getGaugeService = getGaugeService.New(di.repositories.pgStorage)
+	di.services.included.getHistogramService = getHistogramService.New(di.repositories.pgStorage)
 
 	di.services.updateFlatService = updateFlatService.New(di.services.included.updateCounterService,
-		di.services.included.updateGaugeService)
-	di.services.updateBatchService = updateBatchService.New(di.repositories.pgStorage)
+		di.services.included.updateGaugeService, di.services.included.updateHistogramService)
 	di.services.updateService = updateService.New(di.services.included.updateCounterService,
-		di.services.included.updateGaugeService)
+		di.services.included.updateGaugeService, di.services.included.updateHistogramService)
+	di.services.remoteWriteService = remoteWriteService.New(di.services.updateBatchService, di.repositories.pgStorage)
 
 	di.services.getFlatService = getFlatService.New(di.services.included.getCounterService,
-		di.services.included.getGaugeService)
+		di.services.included.getGaugeService, di.services.included.getHistogramService)
 	di.services.getService = getService.New(di.services.included.getCounterService,
-		di.services.included.getGaugeService)
+		di.services.included.getGaugeService, di.services.included.getHistogramService)
 
 	di.services.listMetricService = listMetricService.New(di.repositories.pgStorage)
+	di.services.exportMetricService = exportMetricService.New(di.repositories.pgStorage)
+	di.services.selfExportService = exportMetricService.New(di.repositories.selfStorage)
+	di.services.selfMetricService = selfMetricService.New(di.config.SelfMetricService, di.repositories.selfStorage,
+		di.repositories.pgStorage, di.repositories.outbox)
+
+	di.services.historyService = historyService.New(di.repositories.pgStorage)
+
+	di.services.deleteMetricService = deleteMetricService.New(di.repositories.pgStorage)
+	di.services.retentionService = retentionService.New(di.config.RetentionService, di.services.deleteMetricService,
+		di.repositories.pgStorage)
 
 	di.services.dumpMetricService = dumpMetricService.New(di.config.DumpService, di.config.FileStoragePath, di.repositories.inmemoryStorage)
 	di.services.dumpSyncMetricService = dumpMetricService.New(di.config.DumpSyncService, di.config.FileStoragePath, di.repositories.inmemoryStorage)
@@ -153,21 +272,73 @@ func (di *DI) initServices() {
 
 	di.services.decryptService = decryptServiceV0.New(di.config.DecryptService)
 
-	di.services.auditFileService = auditFileService.New(di.config.AuditFileService, di.repositories.outbox, di.repositories.fileAuditor)
-	di.services.auditRemoteService = auditRemoteService.New(di.config.AuditRemoteService, di.repositories.outbox, di.repositories.remoteAuditor)
+	di.services.auditService = auditService.New(di.config.AuditSinks, di.repositories.outbox, di.repositories.auditSinks)
+
+	di.services.alertService = alertService.New(di.config.AlertService, di.repositories.pgStorage, di.repositories.outbox)
+	di.services.silenceService = silenceService.New(di.repositories.silence)
+	di.services.deadLetterService = deadLetterService.New(di.repositories.outbox)
+	di.services.notifyService = notifyService.New(di.config.Receivers, di.repositories.outbox, di.services.silenceService)
+	di.services.webhookService = webhookService.New(di.repositories.outbox, di.repositories.webhookNotifier)
 }
 
 func (di *DI) initWorkers() {
-	di.workers.auditFile = sworker.New(
-		di.config.Worker.AuditFile,
-		"audit_file",
-		di.services.auditFileService.Do,
+	di.workers.audit = di.newSegmentWorkers(
+		di.config.Worker.Audit,
+		"audit",
+		di.services.auditService.Do,
+	)
+	for _, name := range di.services.auditService.Sinks() {
+		di.workers.audit = append(di.workers.audit, di.newSegmentWorkers(
+			di.config.Worker.Audit,
+			"audit_sink_"+name,
+			func(ctx context.Context, segment string) error {
+				return di.services.auditService.Deliver(ctx, name, segment)
+			},
+		)...)
+	}
+	di.workers.alert = sworker.New(
+		di.config.Worker.Alert,
+		"alert",
+		di.services.alertService.Do,
+	)
+	di.workers.notify = sworker.New(
+		di.config.Worker.Notify,
+		"notify",
+		di.services.notifyService.Do,
 	)
-	di.workers.auditRemote = sworker.New(
-		di.config.Worker.AuditRemote,
-		"audit_remote",
-		di.services.auditRemoteService.Do,
+	di.workers.webhook = sworker.New(
+		di.config.Worker.Webhook,
+		"webhook",
+		di.services.webhookService.Do,
 	)
+	di.workers.retention = sworker.New(
+		di.config.Worker.Retention,
+		"retention",
+		di.services.retentionService.Do,
+	)
+	di.workers.selfMetrics = sworker.New(
+		di.config.Worker.SelfMetrics,
+		"self_metrics",
+		di.services.selfMetricService.Do,
+	)
+}
+
+// newSegmentWorkers creates a worker per outbox segment, each one owning its segment.
+func (di *DI) newSegmentWorkers(
+	config sworker.Config, pid string, job func(ctx context.Context, segment string) error,
+) []*sworker.SimpleWorker {
+	n := max(di.config.Outbox.Segments, 1)
+
+	workers := make([]*sworker.SimpleWorker, 0, n)
+	for i := range n {
+		segment := pkg.OutboxSegmentName(i)
+		workers = append(workers, sworker.New(
+			config,
+			pid+"_"+strconv.Itoa(i),
+			func(ctx context.Context) error { return job(ctx, segment) },
+		))
+	}
+	return workers
 }
 
 func (di *DI) initAPI() {
@@ -176,20 +347,42 @@ func (di *DI) initAPI() {
 		di.services.updateFlatService,
 		di.services.updateBatchService,
 		di.services.updateService,
+		di.services.remoteWriteService,
 		di.services.getFlatService,
 		di.services.getService,
 		di.services.listMetricService,
+		di.services.exportMetricService,
+		di.services.selfExportService,
+		di.services.historyService,
+		di.services.deleteMetricService,
+		di.services.silenceService,
+		di.services.deadLetterService,
 		di.services.dumpSyncMetricService,
 		di.services.hashService,
 		di.services.decryptService,
 	)
+	di.api.grpc = grpchandler.New(
+		di.config.GRPCHandler,
+		di.logger,
+		di.services.updateBatchService,
+		di.services.updateService,
+		di.services.getService,
+		di.services.listMetricService,
+		di.services.dumpSyncMetricService,
+	)
 }
 
 func (di *DI) Start(errorCh chan<- error, certFile string, keyFile string) {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	di.workers.auditFile.Start(ctx)
-	di.workers.auditRemote.Start(ctx)
+	for _, w := range di.workers.audit {
+		w.Start(ctx)
+	}
+	di.workers.alert.Start(ctx)
+	di.workers.notify.Start(ctx)
+	di.workers.webhook.Start(ctx)
+	di.workers.retention.Start(ctx)
+	di.workers.selfMetrics.Start(ctx)
 
 	if di.config.Restore {
 		if err := di.services.dumpMetricService.ReadDump(); err != nil {
@@ -212,6 +405,7 @@ func (di *DI) Start(errorCh chan<- error, certFile string, keyFile string) {
 			handler.MiddlewareCompress,
 			di.api.external.WithHash,
 			di.api.external.WithDecrypt,
+			handler.MiddlewareRequestID,
 		),
 	}
 
@@ -222,12 +416,73 @@ func (di *DI) Start(errorCh chan<- error, certFile string, keyFile string) {
 			errorCh <- err
 		}
 	}()
+
+	if di.config.GRPC.Address != "" {
+		di.startGRPC(errorCh, certFile, keyFile)
+	}
+}
+
+func (di *DI) startGRPC(errorCh chan<- error, certFile string, keyFile string) {
+	creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+	if err != nil {
+		errorCh <- err
+		return
+	}
+
+	listen, err := net.Listen("tcp", di.config.GRPC.Address)
+	if err != nil {
+		errorCh <- err
+		return
+	}
+
+	di.grpcServer = grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(grpchandler.WithRequestID, di.api.grpc.WithLogging, di.api.grpc.WithSync),
+		grpc.ChainStreamInterceptor(grpchandler.WithStreamRequestID, di.api.grpc.WithStreamLogging, di.api.grpc.WithStreamSync),
+	)
+	di.api.grpc.RegisterHandlers(di.grpcServer)
+
+	go func() {
+		if err := di.grpcServer.Serve(listen); !errors.Is(err, grpc.ErrServerStopped) {
+			errorCh <- err
+		}
+	}()
+}
+
+// Reopen reopens the audit files after they were moved by the external rotation.
+func (di *DI) Reopen(ctx context.Context) {
+	if err := di.repositories.auditSinks.SinkReopen(ctx); err != nil {
+		log.Println("audit sinks reopen not ok,", err.Error())
+	}
 }
 
 func (di *DI) Stop(ctx context.Context) {
 	di.httpServer.Shutdown(ctx)
+	if di.grpcServer != nil {
+		di.stopGRPC(ctx)
+	}
 	di.infr.db.Close()
-	di.repositories.fileAuditor.FileClose(context.TODO())
+	di.repositories.auditSinks.SinkClose(context.TODO())
+	if di.repositories.outboxJournal != nil {
+		di.repositories.outboxJournal.Close()
+	}
+	if err := di.tracer.Shutdown(ctx); err != nil {
+		log.Println("tracing shutdown not ok,", err.Error())
+	}
+}
+
+func (di *DI) stopGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		di.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-ctx.Done():
+		di.grpcServer.Stop()
+	case <-stopped:
+	}
 }
 
 func (di *DI) doDump(ctx context.Context) {
@@ -1,19 +1,75 @@
 package entities
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+// ErrVersionConflict is returned by repositories when the stored version
+// of a metric differs from the expected one.
+var ErrVersionConflict = errors.New("version conflict")
 
 type CounterItem struct {
-	MetricType  string    `json:"metric_type"`
-	MetricName  string    `json:"metric_name"`
-	MetricValue int64     `json:"metric_value"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	MetricType  string     `json:"metric_type"`
+	MetricName  string     `json:"metric_name"`
+	Labels      pkg.Labels `json:"labels"`
+	Op          string     `json:"op,omitempty"`
+	MetricValue int64      `json:"metric_value"`
+	Version     int64      `json:"version,omitempty"`
+	IfVersion   *int64     `json:"if_version,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type GaugeItem struct {
-	MetricType  string    `json:"metric_type"`
-	MetricName  string    `json:"metric_name"`
-	MetricValue float64   `json:"metric_value"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	MetricType  string     `json:"metric_type"`
+	MetricName  string     `json:"metric_name"`
+	Labels      pkg.Labels `json:"labels"`
+	Op          string     `json:"op,omitempty"`
+	MetricValue float64    `json:"metric_value"`
+	Version     int64      `json:"version,omitempty"`
+	IfVersion   *int64     `json:"if_version,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type HistogramItem struct {
+	MetricType string     `json:"metric_type"`
+	MetricName string     `json:"metric_name"`
+	Labels     pkg.Labels `json:"labels"`
+	Bounds     []float64  `json:"bounds"`
+	Counts     []uint64   `json:"counts"`
+	Sum        float64    `json:"sum"`
+	Count      uint64     `json:"count"`
+	Version    int64      `json:"version,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type HistoryPoint struct {
+	TS    time.Time `json:"ts"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	Last  float64   `json:"last"`
+	Count int64     `json:"count"`
+}
+
+// MetricKey identifies a stored series.
+type MetricKey struct {
+	MetricType string     `json:"metric_type"`
+	MetricName string     `json:"metric_name"`
+	Labels     pkg.Labels `json:"labels"`
+}
+
+// MetricFilter selects the metrics to delete, an empty field matches any metric.
+// Labels are compared exactly and only together with MetricName.
+type MetricFilter struct {
+	MetricType    string
+	MetricName    string
+	Labels        pkg.Labels
+	Prefix        string
+	UpdatedBefore time.Time
 }
@@ -13,4 +13,32 @@ type Outbox struct {
 	Segment     string          `json:"segment"`
 	Payload     json.RawMessage `json:"payload"`
 	LockUntil   *time.Time      `json:"lock_until"`
+	Attempts    int             `json:"attempts,omitempty"`
+	LastError   *string         `json:"last_error,omitempty"`
+}
+
+// OutboxFailure is a failed delivery of an outbox item.
+type OutboxFailure struct {
+	ID    OutboxID `json:"id"`
+	Error string   `json:"error"`
+	// Permanent failures are moved to the dead letters at once, redelivery cannot fix them.
+	Permanent bool `json:"permanent,omitempty"`
+}
+
+// DeadLetter is an outbox item moved aside after too many failed deliveries.
+type DeadLetter struct {
+	ID          OutboxID        `json:"id"`
+	Destination string          `json:"destination"`
+	Segment     string          `json:"segment"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	LastError   *string         `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// OutboxBacklog is the number of pending outbox items of a destination and segment.
+type OutboxBacklog struct {
+	Destination string `json:"destination"`
+	Segment     string `json:"segment"`
+	Count       int64  `json:"count"`
 }
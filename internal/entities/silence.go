@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type SilenceID = string
+
+type Silence struct {
+	ID         SilenceID  `json:"id"`
+	Rule       string     `json:"rule"`
+	MetricName string     `json:"metric_name"`
+	Matchers   pkg.Labels `json:"matchers"`
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     time.Time  `json:"ends_at"`
+	CreatedBy  string     `json:"created_by"`
+	Comment    string     `json:"comment"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
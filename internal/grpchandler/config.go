@@ -0,0 +1,6 @@
+package grpchandler
+
+type Config struct {
+	StreamChunkSize     int `env:"STREAM_CHUNK_SIZE" envDefault:"500" json:"streamChunkSize"`
+	StreamMaxRejections int `env:"STREAM_MAX_REJECTIONS" envDefault:"100" json:"streamMaxRejections"`
+}
@@ -0,0 +1,190 @@
+package grpchandler
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/MaksimMakarenko1001/ya-go-advanced/api/proto"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/principal"
+)
+
+func (api *API) Update(ctx context.Context, rq *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	metric := toModel(rq.GetMetric())
+
+	if err := api.updateService.Do(withPrincipal(ctx), peerAddress(ctx), metric); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if metric.Version != nil {
+		next := *metric.Version + 1
+		metric.Version = &next
+	}
+
+	return &pb.UpdateResponse{Metric: toProto(metric)}, nil
+}
+
+func (api *API) UpdateBatch(ctx context.Context, rq *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+	metrics := make([]models.Metric, 0, len(rq.GetMetrics()))
+	for _, metric := range rq.GetMetrics() {
+		metrics = append(metrics, toModel(metric))
+	}
+
+	req := models.Request{IPAddress: peerAddress(ctx), Metrics: metrics}
+	if err := api.updateBatchService.Do(withPrincipal(ctx), time.Now(), req); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.UpdateBatchResponse{Metrics: rq.GetMetrics()}, nil
+}
+
+func (api *API) UpdateStream(stream grpc.ClientStreamingServer[pb.Metric, pb.UpdateStreamResponse]) error {
+	ctx := withPrincipal(stream.Context())
+	ipAddress := peerAddress(ctx)
+
+	// the response lists only the rejected metrics, up to the configured
+	// number, so that its size does not grow with the stream
+	var resp pb.UpdateStreamResponse
+	chunk := make([]models.Metric, 0, max(api.config.StreamChunkSize, 1))
+	chunkResults := make([]*pb.MetricResult, 0, cap(chunk))
+
+	reject := func(result *pb.MetricResult, err error) {
+		resp.Rejected++
+		if len(resp.Results) < api.config.StreamMaxRejections {
+			result.Error = err.Error()
+			resp.Results = append(resp.Results, result)
+		}
+	}
+
+	commit := func() {
+		if len(chunk) == 0 {
+			return
+		}
+
+		req := models.Request{IPAddress: ipAddress, Metrics: chunk}
+		if err := api.updateBatchService.Do(ctx, time.Now(), req); err != nil {
+			for _, result := range chunkResults {
+				reject(result, err)
+			}
+		} else {
+			resp.Accepted += uint64(len(chunkResults))
+		}
+
+		chunk, chunkResults = chunk[:0], chunkResults[:0]
+	}
+
+	for index := uint64(0); ; index++ {
+		metric, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		result := &pb.MetricResult{Index: index, Id: metric.GetId()}
+
+		m := toModel(metric)
+		if err := api.updateBatchService.Check(m); err != nil {
+			reject(result, err)
+			continue
+		}
+
+		chunk = append(chunk, m)
+		chunkResults = append(chunkResults, result)
+
+		if len(chunk) == cap(chunk) {
+			commit()
+		}
+	}
+	commit()
+
+	slices.SortFunc(resp.Results, func(a, b *pb.MetricResult) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	return stream.SendAndClose(&resp)
+}
+
+func (api *API) Get(ctx context.Context, rq *pb.GetRequest) (*pb.GetResponse, error) {
+	metric, err := api.getService.Do(ctx, rq.GetType(), rq.GetId(), rq.GetLabels())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.GetResponse{Metric: toProto(*metric)}, nil
+}
+
+func (api *API) List(ctx context.Context, rq *pb.ListRequest) (*pb.ListResponse, error) {
+	metrics, err := api.listMetricService.List(ctx, rq.GetLabels())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := make([]*pb.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		resp = append(resp, toProto(metric))
+	}
+
+	return &pb.ListResponse{Metrics: resp}, nil
+}
+
+func toStatus(err error) error {
+	var errE *pkg.Error
+	if !errors.As(err, &errE) {
+		errE = pkg.ErrInternalServer
+	}
+
+	code := codes.Internal
+	switch errE.HTTPStatus() {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusConflict:
+		code = codes.Aborted
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusUnsupportedMediaType:
+		code = codes.InvalidArgument
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+
+	return status.Error(code, errE.Error())
+}
+
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// withPrincipal puts the principal of the peer certificate into the context.
+func withPrincipal(ctx context.Context) context.Context {
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	return principal.WithPrincipal(ctx, principal.Resolve(state, false))
+}
@@ -0,0 +1,290 @@
+package grpchandler_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/MaksimMakarenko1001/ya-go-advanced/api/proto"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/grpchandler"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/encode"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/inmemory"
+	dumpMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/dumpMetricService/v0"
+	getCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getCounterService/v0"
+	getGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getGaugeService/v0"
+	getService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getService/v0"
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
+	updateCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateCounterService/v0"
+	updateGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateGaugeService/v0"
+	updateService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type MetricRepositoryMock struct {
+}
+
+func (m *MetricRepositoryMock) AddUpdateBatch(
+	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	outboxes []entities.Outbox, outboxSegment string,
+) (ok bool, err error) {
+	return true, nil
+}
+
+func (m *MetricRepositoryMock) GetCounter(ctx context.Context, name string, labels pkg.Labels) (*entities.CounterItem, bool, error) {
+	if name == "ok_counter" {
+		return &entities.CounterItem{
+			MetricName:  name,
+			MetricValue: 99,
+		}, true, nil
+	}
+	return nil, false, nil
+}
+
+func (m *MetricRepositoryMock) GetGauge(ctx context.Context, name string, labels pkg.Labels) (*entities.GaugeItem, bool, error) {
+	if name == "ok_gauge" {
+		return &entities.GaugeItem{
+			MetricName:  name,
+			MetricValue: 99.99,
+		}, true, nil
+	}
+	return nil, false, nil
+}
+
+func (m *MetricRepositoryMock) List(ctx context.Context) (listMetricService.MetricData, error) {
+	return listMetricService.MetricData{
+		Counters: []entities.CounterItem{
+			{
+				MetricName:  "counter",
+				MetricValue: 99,
+			},
+		},
+		Gauges: []entities.GaugeItem{
+			{
+				MetricName:  "gauge",
+				MetricValue: 99.99,
+			},
+		},
+	}, nil
+}
+
+func newAPI() *grpchandler.API {
+	repo := &MetricRepositoryMock{}
+	batchService := updateBatchService.New(1, repo)
+
+	return grpchandler.New(
+		grpchandler.Config{StreamChunkSize: 2, StreamMaxRejections: 1},
+		nil,
+		batchService,
+		updateService.New(updateCounterService.New(batchService), updateGaugeService.New(batchService), nil),
+		getService.New(getCounterService.New(repo), getGaugeService.New(repo), nil),
+		listMetricService.New(repo),
+		nil,
+	)
+}
+
+func TestAPI_Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   *pb.Metric
+		wantCode codes.Code
+	}{
+		{
+			name:     "positive test [counter]",
+			metric:   &pb.Metric{Id: "ok", Type: pkg.MetricTypeCounter, Delta: pkg.ToPtr[int64](1)},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "positive test [gauge]",
+			metric:   &pb.Metric{Id: "ok", Type: pkg.MetricTypeGauge, Value: pkg.ToPtr(1.5)},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "negative test [no value]",
+			metric:   &pb.Metric{Id: "ok", Type: pkg.MetricTypeGauge},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "negative test [invalid type]",
+			metric:   &pb.Metric{Id: "ok", Type: "unknown", Value: pkg.ToPtr(1.5)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "negative test [reserved name]",
+			metric:   &pb.Metric{Id: pkg.SelfMetricPrefix + "ok", Type: pkg.MetricTypeGauge, Value: pkg.ToPtr(1.5)},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	api := newAPI()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := api.Update(context.Background(), &pb.UpdateRequest{Metric: tt.metric})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, tt.metric.GetId(), resp.GetMetric().GetId())
+			}
+		})
+	}
+}
+
+func TestAPI_UpdateBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  []*pb.Metric
+		wantCode codes.Code
+	}{
+		{
+			name: "positive test",
+			metrics: []*pb.Metric{
+				{Id: "c", Type: pkg.MetricTypeCounter, Delta: pkg.ToPtr[int64](1)},
+				{Id: "g", Type: pkg.MetricTypeGauge, Value: pkg.ToPtr(1.5)},
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "negative test [no delta]",
+			metrics: []*pb.Metric{
+				{Id: "c", Type: pkg.MetricTypeCounter},
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	api := newAPI()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := api.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{Metrics: tt.metrics})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestAPI_Get(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricType string
+		metricName string
+		wantCode   codes.Code
+	}{
+		{
+			name:       "positive test [counter]",
+			metricType: pkg.MetricTypeCounter,
+			metricName: "ok_counter",
+			wantCode:   codes.OK,
+		},
+		{
+			name:       "positive test [gauge]",
+			metricType: pkg.MetricTypeGauge,
+			metricName: "ok_gauge",
+			wantCode:   codes.OK,
+		},
+		{
+			name:       "negative test [not found]",
+			metricType: pkg.MetricTypeGauge,
+			metricName: "not_found",
+			wantCode:   codes.NotFound,
+		},
+	}
+
+	api := newAPI()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := api.Get(context.Background(), &pb.GetRequest{Id: tt.metricName, Type: tt.metricType})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, tt.metricName, resp.GetMetric().GetId())
+			}
+		})
+	}
+}
+
+func TestAPI_List(t *testing.T) {
+	api := newAPI()
+
+	resp, err := api.List(context.Background(), &pb.ListRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 2)
+
+	assert.Equal(t, "counter", resp.GetMetrics()[0].GetId())
+	assert.Equal(t, int64(99), resp.GetMetrics()[0].GetDelta())
+	assert.Equal(t, "gauge", resp.GetMetrics()[1].GetId())
+	assert.Equal(t, 99.99, resp.GetMetrics()[1].GetValue())
+}
+
+func TestAPI_UpdateStream(t *testing.T) {
+	listen := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	newAPI().RegisterHandlers(server)
+	go server.Serve(listen)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listen.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := pb.NewMetricsClient(conn).UpdateStream(context.Background())
+	require.NoError(t, err)
+
+	metrics := []*pb.Metric{
+		{Id: "c", Type: pkg.MetricTypeCounter, Delta: pkg.ToPtr[int64](1)},
+		{Id: "bad", Type: pkg.MetricTypeCounter},
+		{Id: "g", Type: pkg.MetricTypeGauge, Value: pkg.ToPtr(1.5)},
+		{Id: "unknown", Type: "unknown"},
+		{Id: "g", Type: pkg.MetricTypeGauge, Value: pkg.ToPtr(2.5)},
+	}
+	for _, metric := range metrics {
+		require.NoError(t, stream.Send(metric))
+	}
+
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(3), resp.GetAccepted())
+	assert.Equal(t, uint64(2), resp.GetRejected())
+	require.Len(t, resp.GetResults(), 1, "rejections over the limit are only counted")
+
+	result := resp.GetResults()[0]
+	assert.Equal(t, uint64(1), result.GetIndex())
+	assert.Equal(t, "bad", result.GetId())
+	assert.False(t, result.GetAccepted())
+	assert.NotEmpty(t, result.GetError())
+}
+
+func TestAPI_WithSync(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "dump.json")
+	storage := inmemory.New(encode.New())
+	dump := dumpMetricService.New(dumpMetricService.Config{WriteDumpEnable: true}, fname, storage)
+	api := grpchandler.New(grpchandler.Config{}, nil, nil, nil, nil, nil, dump)
+
+	handler := func(ctx context.Context, rq any) (any, error) { return rq, nil }
+
+	_, err := api.WithSync(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: pb.Metrics_Get_FullMethodName}, handler)
+	require.NoError(t, err)
+	assert.NoFileExists(t, fname, "reads are not synced")
+
+	_, err = api.WithSync(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: pb.Metrics_UpdateBatch_FullMethodName}, handler)
+	require.NoError(t, err)
+	assert.FileExists(t, fname)
+}
@@ -0,0 +1,30 @@
+package grpchandler
+
+import (
+	pb "github.com/MaksimMakarenko1001/ya-go-advanced/api/proto"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+)
+
+func toModel(metric *pb.Metric) models.Metric {
+	return models.Metric{
+		ID:      metric.GetId(),
+		MType:   metric.GetType(),
+		Labels:  metric.GetLabels(),
+		Op:      metric.GetOp(),
+		Version: metric.Version,
+		Delta:   metric.Delta,
+		Value:   metric.Value,
+	}
+}
+
+func toProto(metric models.Metric) *pb.Metric {
+	return &pb.Metric{
+		Id:      metric.ID,
+		Type:    metric.MType,
+		Labels:  metric.Labels,
+		Op:      metric.Op,
+		Version: metric.Version,
+		Delta:   metric.Delta,
+		Value:   metric.Value,
+	}
+}
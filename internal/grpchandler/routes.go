@@ -0,0 +1,226 @@
+package grpchandler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/MaksimMakarenko1001/ya-go-advanced/api/proto"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/logger"
+	dumpMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/dumpMetricService/v0"
+	getService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getService/v0"
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
+	updateService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
+)
+
+type API struct {
+	pb.UnimplementedMetricsServer
+
+	config Config
+	logger logger.HTTPLogger
+
+	updateBatchService *updateBatchService.Service
+	updateService      *updateService.Service
+
+	getService *getService.Service
+
+	listMetricService *listMetricService.Service
+
+	dumpSyncMetricService *dumpMetricService.Service
+}
+
+func New(
+	config Config,
+	logger logger.HTTPLogger,
+	updateBatchService *updateBatchService.Service,
+	updateService *updateService.Service,
+	getService *getService.Service,
+	listMetricService *listMetricService.Service,
+	dumpSyncMetricService *dumpMetricService.Service,
+) *API {
+	return &API{
+		config:                config,
+		logger:                logger,
+		updateBatchService:    updateBatchService,
+		updateService:         updateService,
+		getService:            getService,
+		listMetricService:     listMetricService,
+		dumpSyncMetricService: dumpSyncMetricService,
+	}
+}
+
+func (api *API) RegisterHandlers(server *grpc.Server) {
+	pb.RegisterMetricsServer(server, api)
+}
+
+func (api *API) WithLogging(
+	ctx context.Context, rq any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+
+	resp, err := handler(ctx, rq)
+
+	var resInfo logger.ResponseInfo
+	resInfo.Status = httpStatus(status.Code(err))
+	if err != nil {
+		resInfo.Body.WriteString(err.Error())
+	}
+
+	recordCall(info.FullMethod, status.Code(err), start)
+
+	api.logger.LogHTTP(logger.HTTPInfo{
+		RequestID: requestid.FromContext(ctx),
+		URI:       info.FullMethod,
+		Method:    "GRPC",
+		Duration:  time.Since(start),
+		Response:  resInfo,
+	})
+
+	return resp, err
+}
+
+func (api *API) WithStreamLogging(
+	srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+
+	err := handler(srv, ss)
+
+	var resInfo logger.ResponseInfo
+	resInfo.Status = httpStatus(status.Code(err))
+	if err != nil {
+		resInfo.Body.WriteString(err.Error())
+	}
+
+	recordCall(info.FullMethod, status.Code(err), start)
+
+	api.logger.LogHTTP(logger.HTTPInfo{
+		RequestID: requestid.FromContext(ss.Context()),
+		URI:       info.FullMethod,
+		Method:    "GRPC",
+		Duration:  time.Since(start),
+		Response:  resInfo,
+	})
+
+	return err
+}
+
+// syncMethods are the calls updating the metrics, the dump is written after them.
+var syncMethods = map[string]struct{}{
+	pb.Metrics_Update_FullMethodName:       {},
+	pb.Metrics_UpdateBatch_FullMethodName:  {},
+	pb.Metrics_UpdateStream_FullMethodName: {},
+}
+
+// WithSync writes the dump after the updating calls, the same way the HTTP API does.
+func (api *API) WithSync(
+	ctx context.Context, rq any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	resp, err := handler(ctx, rq)
+	if _, ok := syncMethods[info.FullMethod]; !ok {
+		return resp, err
+	}
+
+	if dumpErr := api.dumpSyncMetricService.WriteDump(); dumpErr != nil && err == nil {
+		return nil, status.Error(codes.Internal, dumpErr.Error())
+	}
+	return resp, err
+}
+
+// WithStreamSync is the streaming counterpart of WithSync.
+func (api *API) WithStreamSync(
+	srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	err := handler(srv, ss)
+	if _, ok := syncMethods[info.FullMethod]; !ok {
+		return err
+	}
+
+	if dumpErr := api.dumpSyncMetricService.WriteDump(); dumpErr != nil && err == nil {
+		return status.Error(codes.Internal, dumpErr.Error())
+	}
+	return err
+}
+
+// recordCall counts the call and observes its latency in the default selfmetrics registry.
+func recordCall(method string, code codes.Code, start time.Time) {
+	labels := pkg.Labels{"method": method}
+	selfmetrics.ObserveSince("grpc_request_duration_seconds", labels, start)
+
+	labels["code"] = code.String()
+	selfmetrics.Inc("grpc_requests_total", labels)
+}
+
+// WithRequestID puts the request ID taken from the x-request-id or traceparent metadata,
+// or a new one, into the call context and returns it in the response header.
+func WithRequestID(
+	ctx context.Context, rq any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	ctx = withRequestID(ctx)
+	return handler(ctx, rq)
+}
+
+// WithStreamRequestID is the streaming counterpart of WithRequestID.
+func WithStreamRequestID(
+	srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	return handler(srv, &requestIDStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var id, traceparent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestid.MetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+		if values := md.Get(requestid.TraceparentHeader); len(values) > 0 {
+			traceparent = values[0]
+		}
+	}
+
+	id = requestid.Resolve(id, traceparent)
+	grpc.SetHeader(ctx, metadata.Pairs(requestid.MetadataKey, id))
+
+	return requestid.WithID(ctx, id)
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -9,45 +9,78 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/api/prompb"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
+// Limits of a remote write request: the snappy compressed body and the
+// protobuf message it decodes to.
+const (
+	maxRemoteWriteBodySize    = 8 << 20
+	maxRemoteWriteDecodedSize = 32 << 20
+)
+
 const html = `<html>
     <head>
     <title></title>
     </head>
     <body>
         <table>
-			<tbody>{{ range . }}
+			<tbody>{{ range . }}{{ if .Group }}
+				<tr>
+					<th colspan="2">{{ .Group }}</th>
+				</tr>{{ end }}{{ range .Items }}
 				<tr>
 					<td>{{ .Name }}</td>
 					<td>{{ .Value }}</td>
-				</tr>{{ end }}
+				</tr>{{ end }}{{ end }}
 			</tbody>
 		</table>
     </body>
 </html>`
 
 type (
-	UpdateFlatService  func(ctx context.Context, metricType, metricName, metricValue string) (err error)
+	UpdateFlatService  func(ctx context.Context, ipAddress, metricType, metricName string, labels pkg.Labels, op string, version *int64, metricValue string) (err error)
 	UpdateBatchService func(ctx context.Context, ts time.Time, request models.Request) (err error)
-	UpdateService      func(ctx context.Context, metric models.Metric) (err error)
+	UpdateService      func(ctx context.Context, ipAddress string, metric models.Metric) (err error)
+
+	GetGaugeService   func(ctx context.Context, metricName string, labels pkg.Labels) (metricValue *float64, err error)
+	GetCounterService func(ctx context.Context, metricName string, labels pkg.Labels) (metricValue *int64, err error)
+	GetFlatService    func(ctx context.Context, metricType, metricName string, labels pkg.Labels, quantile string) (metricValue string, err error)
+	GetService        func(ctx context.Context, metricType, metricName string, labels pkg.Labels) (metric *models.Metric, err error)
+
+	ListMetricService func(ctx context.Context, template string, filter pkg.Labels, groupBy string) (index string, err error)
+
+	ExportMetricService func(ctx context.Context, accept string) (body string, contentType string, err error)
+
+	RemoteWriteService func(ctx context.Context, ipAddress string, request *prompb.WriteRequest) (report *models.RemoteWriteReport, err error)
+
+	HistoryService func(ctx context.Context, metricType, metricName string, labels pkg.Labels, from, to, step string) (history *models.History, err error)
+
+	DeleteFlatService   func(ctx context.Context, ipAddress, metricType, metricName string, labels pkg.Labels) (err error)
+	DeletePrefixService func(ctx context.Context, ipAddress, metricType, prefix string) (deleted []string, err error)
 
-	GetGaugeService   func(ctx context.Context, metricName string) (metricValue *float64, err error)
-	GetCounterService func(ctx context.Context, metricName string) (metricValue *int64, err error)
-	GetFlatService    func(ctx context.Context, metricType, metricName string) (metricValue string, err error)
-	GetService        func(ctx context.Context, metricType, metricName string) (metric *models.Metric, err error)
+	AddSilenceService    func(ctx context.Context, silence models.Silence) (created *models.Silence, err error)
+	ListSilenceService   func(ctx context.Context) (silences []models.Silence, err error)
+	DeleteSilenceService func(ctx context.Context, id string) (err error)
 
-	ListMetricService func(ctx context.Context, template string) (index string, err error)
+	ListDeadLetterService    func(ctx context.Context, destination, limit string) (letters []models.DeadLetter, err error)
+	RequeueDeadLetterService func(ctx context.Context, destination string, ids []string, all string) (requeued []string, err error)
+	PurgeDeadLetterService   func(ctx context.Context, destination string, ids []string, all string) (purged []string, err error)
 )
 
 func DoListMetricResponse(srv ListMetricService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		index, err := srv(r.Context(), html)
+		query := r.URL.Query()
+
+		index, err := srv(r.Context(), html, queryLabels(query, "group_by"), query.Get("group_by"))
 		if err != nil {
-			WriteError(w, err)
+			WriteError(w, r, err)
 			return
 		}
 
@@ -57,10 +90,37 @@ func DoListMetricResponse(srv ListMetricService) http.HandlerFunc {
 	}
 }
 
+func DoExportMetricResponse(srv ExportMetricService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, contentType, err := srv(r.Context(), r.Header.Get("Accept"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+	}
+}
+
 func DoUpdateFlatResponse(srv UpdateFlatService, metricType, metricName, metricValue string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := srv(r.Context(), metricType, metricName, metricValue); err != nil {
-			WriteError(w, err)
+		query := r.URL.Query()
+
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" {
+			ifMatch = query.Get("version")
+		}
+		version, err := parseVersion(ifMatch)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		labels := queryLabels(query, "op", "version")
+		if err := srv(r.Context(), r.RemoteAddr, metricType, metricName, labels, query.Get("op"), version, metricValue); err != nil {
+			WriteError(w, r, err)
 			return
 		}
 
@@ -73,15 +133,31 @@ func DoUpdateJSONResponse(srv UpdateService) http.HandlerFunc {
 		var metric models.Metric
 
 		if err := json.NewDecoder(r.Body).Decode(&metric); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			WriteError(w, r, pkg.ErrBadRequest.SetInfo(err.Error()))
 			return
 		}
 
-		if err := srv(r.Context(), metric); err != nil {
-			WriteError(w, err)
+		if metric.Version == nil {
+			version, err := parseVersion(r.Header.Get("If-Match"))
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			metric.Version = version
+		}
+
+		if err := srv(r.Context(), r.RemoteAddr, metric); err != nil {
+			WriteError(w, r, err)
 			return
 		}
 
+		if metric.Version != nil {
+			// a conditional write always moves the metric to the next version
+			next := *metric.Version + 1
+			metric.Version = &next
+			w.Header().Set("ETag", etag(next))
+		}
+
 		resp, _ := json.Marshal(metric)
 		WriteJSONResult(w, resp)
 	}
@@ -91,13 +167,13 @@ func DoUpdateBatchJSONResponse(srv UpdateBatchService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var metrics []models.Metric
 		if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			WriteError(w, r, pkg.ErrBadRequest.SetInfo(err.Error()))
 			return
 		}
 
 		req := models.Request{IPAddress: r.RemoteAddr, Metrics: metrics}
 		if err := srv(r.Context(), time.Now(), req); err != nil {
-			WriteError(w, err)
+			WriteError(w, r, err)
 			return
 		}
 
@@ -106,11 +182,58 @@ func DoUpdateBatchJSONResponse(srv UpdateBatchService) http.HandlerFunc {
 	}
 }
 
+func DoRemoteWriteResponse(srv RemoteWriteService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		compressed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRemoteWriteBodySize))
+		if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+			WriteError(w, r, pkg.ErrRequestTooLarge.SetInfof("body exceeds %d bytes", maxBytesErr.Limit))
+			return
+		}
+		if err != nil {
+			WriteError(w, r, pkg.ErrBadRequest.SetInfo(err.Error()))
+			return
+		}
+
+		size, err := snappy.DecodedLen(compressed)
+		if err != nil {
+			WriteError(w, r, pkg.ErrBadRequest.SetInfof("snappy decode not ok, %v", err))
+			return
+		}
+		if size > maxRemoteWriteDecodedSize {
+			WriteError(w, r, pkg.ErrRequestTooLarge.SetInfof("decoded body exceeds %d bytes", maxRemoteWriteDecodedSize))
+			return
+		}
+
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			WriteError(w, r, pkg.ErrBadRequest.SetInfof("snappy decode not ok, %v", err))
+			return
+		}
+
+		var request prompb.WriteRequest
+		if err := proto.Unmarshal(data, &request); err != nil {
+			WriteError(w, r, pkg.ErrBadRequest.SetInfof("write request decode not ok, %v", err))
+			return
+		}
+
+		report, err := srv(r.Context(), r.RemoteAddr, &request)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, _ := json.Marshal(*report)
+		WriteJSONResult(w, resp)
+	}
+}
+
 func DoGetFlatResponse(srv GetFlatService, metricType, metricName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		value, err := srv(r.Context(), metricType, metricName)
+		query := r.URL.Query()
+
+		value, err := srv(r.Context(), metricType, metricName, queryLabels(query, "q"), query.Get("q"))
 		if err != nil {
-			WriteError(w, err)
+			WriteError(w, r, err)
 			return
 		}
 
@@ -123,21 +246,161 @@ func DoGetJSONResponse(srv GetService) http.HandlerFunc {
 		var request models.Metric
 
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			WriteError(w, r, pkg.ErrBadRequest.SetInfo(err.Error()))
 			return
 		}
-		metric, err := srv(r.Context(), request.MType, request.ID)
+		metric, err := srv(r.Context(), request.MType, request.ID, request.Labels)
 		if err != nil {
-			WriteError(w, err)
+			WriteError(w, r, err)
 			return
 		}
 
 		resp, err := json.Marshal(*metric)
 		if err != nil {
-			WriteError(w, fmt.Errorf("convert to get response not ok, %w", err))
+			WriteError(w, r, fmt.Errorf("convert to get response not ok, %w", err))
+			return
+		}
+
+		if metric.Version != nil {
+			w.Header().Set("ETag", etag(*metric.Version))
+		}
+
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoHistoryJSONResponse(srv HistoryService, metricType, metricName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		history, err := srv(r.Context(), metricType, metricName, queryLabels(query, "from", "to", "step"),
+			query.Get("from"), query.Get("to"), query.Get("step"),
+		)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(*history)
+		if err != nil {
+			WriteError(w, r, fmt.Errorf("convert to history response not ok, %w", err))
+			return
+		}
+
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoDeleteFlatResponse(srv DeleteFlatService, metricType, metricName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := srv(r.Context(), r.RemoteAddr, metricType, metricName, queryLabels(r.URL.Query())); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		WriteOK(w)
+	}
+}
+
+func DoDeletePrefixResponse(srv DeletePrefixService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		deleted, err := srv(r.Context(), r.RemoteAddr, query.Get("type"), query.Get("prefix"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, _ := json.Marshal(deleted)
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoAddSilenceResponse(srv AddSilenceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var silence models.Silence
+		if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+			WriteError(w, r, pkg.ErrBadRequest.SetInfo(err.Error()))
+			return
+		}
+
+		created, err := srv(r.Context(), silence)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, _ := json.Marshal(*created)
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoListSilenceResponse(srv ListSilenceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		silences, err := srv(r.Context())
+		if err != nil {
+			WriteError(w, r, err)
 			return
 		}
 
+		resp, _ := json.Marshal(silences)
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoDeleteSilenceResponse(srv DeleteSilenceService, id string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := srv(r.Context(), id); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		WriteOK(w)
+	}
+}
+
+func DoListDeadLetterResponse(srv ListDeadLetterService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		letters, err := srv(r.Context(), query.Get("destination"), query.Get("limit"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, _ := json.Marshal(letters)
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoRequeueDeadLetterResponse(srv RequeueDeadLetterService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		requeued, err := srv(r.Context(), query.Get("destination"), query["id"], query.Get("all"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, _ := json.Marshal(requeued)
+		WriteJSONResult(w, resp)
+	}
+}
+
+func DoPurgeDeadLetterResponse(srv PurgeDeadLetterService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		purged, err := srv(r.Context(), query.Get("destination"), query["id"], query.Get("all"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, _ := json.Marshal(purged)
 		WriteJSONResult(w, resp)
 	}
 }
@@ -146,7 +409,7 @@ func WriteJSONResult(w http.ResponseWriter, response []byte) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(response); err != nil {
-		WriteError(w, fmt.Errorf("write json not ok, %w", err))
+		WriteError(w, nil, fmt.Errorf("write json not ok, %w", err))
 	}
 }
 
@@ -161,8 +424,9 @@ func WriteOK(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func WriteError(w http.ResponseWriter, err error) {
-	w.Header().Set("Content-Type", "text/plain")
+// WriteError writes the error as plain text, or as an RFC 7807 problem
+// when the request accepts application/problem+json.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
 	if err == nil {
 		err = pkg.ErrInternalServer
 	}
@@ -172,5 +436,17 @@ func WriteError(w http.ResponseWriter, err error) {
 	if !errors.As(err, &errE) {
 		errE = pkg.ErrInternalServer
 	}
+
+	if r != nil && acceptsProblem(r.Header.Get("Accept")) {
+		resp, _ := json.Marshal(newProblem(r, errE))
+
+		w.Header().Set("Content-Type", TypeContentProblemJSON)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(errE.HTTPStatus())
+		w.Write(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
 	http.Error(w, errE.Error(), errE.HTTPStatus())
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/handler"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 // ExampleDoListMetricResponse demonstrates how to use DoListMetricResponse handler
@@ -17,7 +18,9 @@ import (
 // The handler returns an HTML table with all metrics (both counters and gauges).
 func ExampleDoListMetricResponse() {
 	// Create the handler with the service
-	h := handler.DoListMetricResponse(func(ctx context.Context, template string) (string, error) {
+	h := handler.DoListMetricResponse(func(
+		ctx context.Context, template string, filter pkg.Labels, groupBy string,
+	) (string, error) {
 		return "<example/>", nil
 	})
 
@@ -42,7 +45,9 @@ func ExampleDoListMetricResponse() {
 // and returns the metric value in JSON format.
 func ExampleDoGetJSONResponse() {
 	// Create the handler with the service
-	h := handler.DoGetJSONResponse(func(ctx context.Context, metricType, metricName string) (metric *models.Metric, err error) {
+	h := handler.DoGetJSONResponse(func(
+		ctx context.Context, metricType, metricName string, labels pkg.Labels,
+	) (metric *models.Metric, err error) {
 		value := 42.5
 		return &models.Metric{ID: metricName, MType: metricType, Value: &value}, nil
 	})
@@ -69,7 +74,7 @@ func ExampleDoGetJSONResponse() {
 // and returns the updated metric in JSON format.
 func ExampleDoUpdateJSONResponse() {
 	// Create the handler with the service
-	h := handler.DoUpdateJSONResponse(func(ctx context.Context, metric models.Metric) (err error) {
+	h := handler.DoUpdateJSONResponse(func(ctx context.Context, ipAddress string, metric models.Metric) (err error) {
 		return nil
 	})
 
@@ -1,22 +1,48 @@
 package handler_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang/snappy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/MaksimMakarenko1001/ya-go-advanced/api/prompb"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/handler"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/encode"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/silence"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/inmemory"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/pg"
+	deleteMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/deleteMetricService/v0"
+	exportMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/exportMetricService/v0"
 	getCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getCounterService/v0"
 	getFlatService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getFlatService/v0"
 	getGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getGaugeService/v0"
+	getHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getHistogramService/v0"
+	getService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getService/v0"
+	historyService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/historyService/v0"
 	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	remoteWriteService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/remoteWriteService/v0"
+	silenceService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/silenceService/v0"
+	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
 	updateCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateCounterService/v0"
 	updateFlatService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateFlatService/v0"
 	updateGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateGaugeService/v0"
+	updateHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateHistogramService/v0"
+	updateService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 const html = `<html>
@@ -42,15 +68,24 @@ const html = `<html>
 type MetricRepositoryMock struct {
 }
 
-func (m *MetricRepositoryMock) Add(ctx context.Context, item entities.CounterItem) (ok bool, err error) {
-	return item.MetricName == "ok", nil
-}
-
-func (m *MetricRepositoryMock) Update(ctx context.Context, item entities.GaugeItem) (ok bool, err error) {
-	return item.MetricName == "ok", nil
+func (m *MetricRepositoryMock) AddUpdateBatch(
+	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	outboxes []entities.Outbox, outboxSegment string,
+) (ok bool, err error) {
+	for _, item := range counters {
+		if item.MetricName != "ok" {
+			return false, nil
+		}
+	}
+	for _, item := range gauges {
+		if item.MetricName != "ok" {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
-func (m *MetricRepositoryMock) GetCounter(ctx context.Context, name string) (*entities.CounterItem, bool, error) {
+func (m *MetricRepositoryMock) GetCounter(ctx context.Context, name string, labels pkg.Labels) (*entities.CounterItem, bool, error) {
 	if name == "ok_counter" {
 		return &entities.CounterItem{
 			MetricName:  name,
@@ -60,7 +95,7 @@ func (m *MetricRepositoryMock) GetCounter(ctx context.Context, name string) (*en
 	return nil, false, nil
 }
 
-func (m *MetricRepositoryMock) GetGauge(ctx context.Context, name string) (*entities.GaugeItem, bool, error) {
+func (m *MetricRepositoryMock) GetGauge(ctx context.Context, name string, labels pkg.Labels) (*entities.GaugeItem, bool, error) {
 	if name == "ok_gauge" {
 		return &entities.GaugeItem{
 			MetricName:  name,
@@ -119,6 +154,299 @@ func TestDoListMetricResponse(t *testing.T) {
 	}
 }
 
+func TestDoListMetricResponse_Labels(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	for _, item := range []entities.GaugeItem{
+		{MetricType: "gauge", MetricName: "Alloc", Labels: pkg.Labels{"host": "a", "dc": "x"}, MetricValue: 1},
+		{MetricType: "gauge", MetricName: "Alloc", Labels: pkg.Labels{"host": "b", "dc": "x"}, MetricValue: 2},
+		{MetricType: "gauge", MetricName: "Alloc", MetricValue: 3},
+	} {
+		_, ok, err := repo.AddUpdateBatch(context.Background(), nil, []entities.GaugeItem{item}, nil)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	row := func(name, value string) string {
+		return "\n\t\t\t\t<tr>\n\t\t\t\t\t<td>" + name + "</td>\n\t\t\t\t\t<td>" + value + "</td>\n\t\t\t\t</tr>"
+	}
+	group := func(name string) string {
+		return "\n\t\t\t\t<tr>\n\t\t\t\t\t<th colspan=\"2\">" + name + "</th>\n\t\t\t\t</tr>"
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		rows  string
+	}{
+		{
+			name:  "positive test [filter]",
+			query: "?host=b",
+			rows:  row(`Alloc{dc=&#34;x&#34;,host=&#34;b&#34;}`, "2"),
+		},
+		{
+			name:  "positive test [group by]",
+			query: "?dc=x&group_by=host",
+			rows: group(`host=&#34;a&#34;`) + row(`Alloc{dc=&#34;x&#34;,host=&#34;a&#34;}`, "1") +
+				group(`host=&#34;b&#34;`) + row(`Alloc{dc=&#34;x&#34;,host=&#34;b&#34;}`, "2"),
+		},
+	}
+
+	handler := handler.DoListMetricResponse(listMetricService.New(repo).Do)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request)
+
+			assert.Equal(t, 200, w.Code)
+			assert.Contains(t, w.Body.String(), "<tbody>"+tt.rows+"\n\t\t\t</tbody>")
+		})
+	}
+}
+
+func TestDoGetFlatResponse_Labels(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	service := updateFlatService.New(updateCounterService.New(newMetricWriter(repo)), updateGaugeService.New(newMetricWriter(repo)), nil)
+
+	for _, query := range []string{"?host=a", "?host=b", ""} {
+		request := httptest.NewRequest(http.MethodPost, "/update/counter/hits/5"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.DoUpdateFlatResponse(service.Do, "counter", "hits", "5").ServeHTTP(w, request)
+		require.Equal(t, 200, w.Code)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/update/counter/hits/5?host=a", nil)
+	w := httptest.NewRecorder()
+	handler.DoUpdateFlatResponse(service.Do, "counter", "hits", "5").ServeHTTP(w, request)
+	require.Equal(t, 200, w.Code)
+
+	getService := getFlatService.New(getCounterService.New(repo), getGaugeService.New(repo), nil)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		body  string
+	}{
+		{name: "positive test [host a]", query: "?host=a", code: 200, body: "10"},
+		{name: "positive test [host b]", query: "?host=b", code: 200, body: "5"},
+		{name: "positive test [no labels]", query: "", code: 200, body: "5"},
+		{name: "negative test [not found]", query: "?host=c", code: 404, body: "[METRIC_NOT_FOUND] Not found (`hits{host=\"c\"}` not found)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/value/counter/hits"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.DoGetFlatResponse(getService.Do, "counter", "hits").ServeHTTP(w, request)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestDoUpdateFlatResponse_Op(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	service := updateFlatService.New(updateCounterService.New(newMetricWriter(repo)), updateGaugeService.New(newMetricWriter(repo)), nil)
+	getService := getFlatService.New(getCounterService.New(repo), getGaugeService.New(repo), nil)
+
+	tests := []struct {
+		name       string
+		metricType string
+		query      string
+		value      string
+		code       int
+		want       string
+	}{
+		{name: "counter [inc]", metricType: "counter", value: "5", code: 200, want: "5"},
+		{name: "counter [default inc]", metricType: "counter", query: "?op=inc", value: "5", code: 200, want: "10"},
+		{name: "counter [set]", metricType: "counter", query: "?op=set", value: "2", code: 200, want: "2"},
+		{name: "counter [invalid op]", metricType: "counter", query: "?op=max", value: "7", code: 400, want: "2"},
+		{name: "gauge [min on new]", metricType: "gauge", query: "?op=min", value: "3", code: 200, want: "3"},
+		{name: "gauge [inc]", metricType: "gauge", query: "?op=inc", value: "1.5", code: 200, want: "4.5"},
+		{name: "gauge [dec]", metricType: "gauge", query: "?op=dec", value: "2", code: 200, want: "2.5"},
+		{name: "gauge [max]", metricType: "gauge", query: "?op=max", value: "1", code: 200, want: "2.5"},
+		{name: "gauge [min]", metricType: "gauge", query: "?op=min", value: "1", code: 200, want: "1"},
+		{name: "gauge [default set]", metricType: "gauge", value: "9", code: 200, want: "9"},
+		{name: "gauge [invalid op]", metricType: "gauge", query: "?op=mul", value: "2", code: 400, want: "9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/update/"+tt.metricType+"/"+tt.metricType+"/"+tt.value+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.DoUpdateFlatResponse(service.Do, tt.metricType, tt.metricType, tt.value).ServeHTTP(w, request)
+			require.Equal(t, tt.code, w.Code)
+
+			request = httptest.NewRequest(http.MethodGet, "/value/"+tt.metricType+"/"+tt.metricType, nil)
+			w = httptest.NewRecorder()
+
+			handler.DoGetFlatResponse(getService.Do, tt.metricType, tt.metricType).ServeHTTP(w, request)
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+func TestDoUpdateJSONResponse_Version(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	counterService, gaugeService := updateCounterService.New(newMetricWriter(repo)), updateGaugeService.New(newMetricWriter(repo))
+	jsonService := updateService.New(counterService, gaugeService, nil)
+	flatService := updateFlatService.New(counterService, gaugeService, nil)
+	getJSONService := getService.New(getCounterService.New(repo), getGaugeService.New(repo), nil)
+
+	tests := []struct {
+		name     string
+		flat     bool
+		body     string
+		query    string
+		ifMatch  string
+		code     int
+		wantETag string
+	}{
+		{name: "create only", body: `{"id":"g","type":"gauge","value":1,"version":0}`, code: 200, wantETag: `"1"`},
+		{name: "create only [exists]", body: `{"id":"g","type":"gauge","value":2,"version":0}`, code: 409, wantETag: `"1"`},
+		{name: "unconditional", body: `{"id":"g","type":"gauge","value":3}`, code: 200, wantETag: `"2"`},
+		{name: "body version", body: `{"id":"g","type":"gauge","value":4,"version":2}`, code: 200, wantETag: `"3"`},
+		{name: "body version [stale]", body: `{"id":"g","type":"gauge","value":5,"version":2}`, code: 409, wantETag: `"3"`},
+		{name: "if-match", body: `{"id":"g","type":"gauge","value":6}`, ifMatch: `"3"`, code: 200, wantETag: `"4"`},
+		{name: "if-match [stale]", body: `{"id":"g","type":"gauge","value":7}`, ifMatch: `"3"`, code: 409, wantETag: `"4"`},
+		{name: "if-match [invalid]", body: `{"id":"g","type":"gauge","value":8}`, ifMatch: `W/"4"`, code: 400, wantETag: `"4"`},
+		{name: "flat query", flat: true, query: "?version=4", code: 200, wantETag: `"5"`},
+		{name: "flat if-match [stale]", flat: true, ifMatch: `"4"`, code: 409, wantETag: `"5"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request *http.Request
+			if tt.flat {
+				request = httptest.NewRequest(http.MethodPost, "/update/gauge/g/9"+tt.query, nil)
+			} else {
+				request = httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(tt.body))
+			}
+			if tt.ifMatch != "" {
+				request.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			if tt.flat {
+				handler.DoUpdateFlatResponse(flatService.Do, "gauge", "g", "9").ServeHTTP(w, request)
+			} else {
+				handler.DoUpdateJSONResponse(jsonService.Do).ServeHTTP(w, request)
+			}
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+
+			request = httptest.NewRequest(http.MethodPost, "/value/", strings.NewReader(`{"id":"g","type":"gauge"}`))
+			w = httptest.NewRecorder()
+
+			handler.DoGetJSONResponse(getJSONService.Do).ServeHTTP(w, request)
+			require.Equal(t, 200, w.Code)
+			assert.Equal(t, tt.wantETag, w.Header().Get("ETag"))
+			assert.Contains(t, w.Body.String(), `"version":`+strings.Trim(tt.wantETag, `"`))
+		})
+	}
+}
+
+func TestDoUpdateResponse_Audit(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	outboxRepo := &OutboxRepositoryMock{}
+	writer := updateBatchService.New(1, pg.New(nil, repo, outboxRepo))
+	histogramService := updateHistogramService.New(updateHistogramService.Config{Buckets: []float64{1, 2}}, writer)
+	counterService, gaugeService := updateCounterService.New(writer), updateGaugeService.New(writer)
+	flatService := updateFlatService.New(counterService, gaugeService, histogramService)
+	jsonService := updateService.New(counterService, gaugeService, histogramService)
+
+	for _, path := range []string{"/update/counter/hits/2", "/update/gauge/temp/1.5", "/update/histogram/latency/0.5"} {
+		parts := strings.Split(path, "/")
+		request := httptest.NewRequest(http.MethodPost, path, nil)
+		w := httptest.NewRecorder()
+
+		handler.DoUpdateFlatResponse(flatService.Do, parts[2], parts[3], parts[4]).ServeHTTP(w, request)
+		require.Equal(t, 200, w.Code)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(`{"id":"hits","type":"counter","delta":3}`))
+	w := httptest.NewRecorder()
+	handler.DoUpdateJSONResponse(jsonService.Do).ServeHTTP(w, request)
+	require.Equal(t, 200, w.Code)
+
+	request = httptest.NewRequest(http.MethodPost, "/update/counter/hits/x", nil)
+	w = httptest.NewRecorder()
+	handler.DoUpdateFlatResponse(flatService.Do, "counter", "hits", "x").ServeHTTP(w, request)
+	require.Equal(t, 400, w.Code)
+
+	require.Len(t, outboxRepo.items, 4)
+
+	var events []models.UpdateEvent
+	for _, item := range outboxRepo.items {
+		require.Equal(t, string(models.AuditOutboxDestination), item.Destination)
+
+		var event models.UpdateEvent
+		require.NoError(t, json.Unmarshal(item.Payload, &event))
+		assert.Equal(t, models.AuditActionUpdate, event.Action)
+		assert.Equal(t, request.RemoteAddr, event.IPAddress)
+		require.Len(t, event.Changes, 1)
+		events = append(events, event)
+	}
+
+	assert.Equal(t, []string{"hits"}, events[0].Metrics)
+	assert.Equal(t, []string{"temp"}, events[1].Metrics)
+	assert.Equal(t, []string{"latency"}, events[2].Metrics)
+
+	assert.JSONEq(t, `2`, string(events[3].Changes[0].Previous))
+	assert.JSONEq(t, `5`, string(events[3].Changes[0].Result))
+}
+
+func TestDoGetFlatResponse_Histogram(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	histogramService := updateHistogramService.New(updateHistogramService.Config{Buckets: []float64{1, 2, 4}}, newMetricWriter(repo))
+	service := updateFlatService.New(updateCounterService.New(newMetricWriter(repo)), updateGaugeService.New(newMetricWriter(repo)), histogramService)
+
+	for _, value := range []string{"0.5", "1.5", "1.5", "3"} {
+		request := httptest.NewRequest(http.MethodPost, "/update/histogram/latency/"+value, nil)
+		w := httptest.NewRecorder()
+
+		handler.DoUpdateFlatResponse(service.Do, "histogram", "latency", value).ServeHTTP(w, request)
+		require.Equal(t, 200, w.Code)
+	}
+
+	getService := getFlatService.New(
+		getCounterService.New(repo), getGaugeService.New(repo), getHistogramService.New(repo),
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		body  string
+	}{
+		{name: "positive test [default quantile]", query: "", code: 200, body: "1.5"},
+		{name: "positive test [q=0.25]", query: "?q=0.25", code: 200, body: "1"},
+		{name: "positive test [q=1]", query: "?q=1", code: 200, body: "4"},
+		{name: "negative test [invalid quantile]", query: "?q=2", code: 400},
+		{name: "negative test [not found]", query: "?host=a", code: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/value/histogram/latency"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.DoGetFlatResponse(getService.Do, "histogram", "latency").ServeHTTP(w, request)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestDoGetCounterResponse(t *testing.T) {
 	type expected struct {
 		code int
@@ -142,7 +470,7 @@ func TestDoGetCounterResponse(t *testing.T) {
 			metricName: "not_found",
 			expected: expected{
 				code: 404,
-				body: "[NOT_FOUND] Not found (`not_found` not found)\n",
+				body: "[METRIC_NOT_FOUND] Not found (`not_found` not found)\n",
 			},
 		},
 	}
@@ -150,6 +478,7 @@ func TestDoGetCounterResponse(t *testing.T) {
 	service := getFlatService.New(
 		getCounterService.New(&MetricRepositoryMock{}),
 		nil,
+		nil,
 	)
 
 	for _, tt := range tests {
@@ -167,6 +496,73 @@ func TestDoGetCounterResponse(t *testing.T) {
 	}
 }
 
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		err         error
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "plain text",
+			err:         pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricValue).SetInfo("invalid metric value"),
+			code:        400,
+			contentType: "text/plain; charset=utf-8",
+			body:        "[INVALID_METRIC_VALUE] Bad request (invalid metric value)\n",
+		},
+		{
+			name:        "problem",
+			accept:      "application/json, application/problem+json;q=0.9",
+			err:         pkg.ErrConflict.SetCode(pkg.CodeVersionConflict).SetInfo("version conflict"),
+			code:        409,
+			contentType: "application/problem+json",
+			body: `{"type":"about:blank","title":"Conflict","status":409,"detail":"version conflict",` +
+				`"instance":"/update/","code":"VERSION_CONFLICT","request_id":"42"}`,
+		},
+		{
+			name:        "problem [unknown error]",
+			accept:      "application/problem+json",
+			err:         errors.New("boom"),
+			code:        500,
+			contentType: "application/problem+json",
+			body: `{"type":"about:blank","title":"Internal error","status":500,` +
+				`"instance":"/update/","code":"INTERNAL_SERVER_ERROR","request_id":"42"}`,
+		},
+		{
+			name:        "problem [new status]",
+			accept:      "application/problem+json",
+			err:         pkg.ErrUnavailable,
+			code:        503,
+			contentType: "application/problem+json",
+			body: `{"type":"about:blank","title":"Service unavailable","status":503,` +
+				`"instance":"/update/","code":"UNAVAILABLE","request_id":"42"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/update/", nil)
+			request.Header.Set("Accept", tt.accept)
+			request.Header.Set("X-Request-ID", "42")
+			w := httptest.NewRecorder()
+
+			handler.MiddlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler.WriteError(w, r, tt.err)
+			})).ServeHTTP(w, request)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			if strings.HasPrefix(tt.contentType, "application/problem+json") {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			} else {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestDoGetGaugeResponse(t *testing.T) {
 	type expected struct {
 		code int
@@ -190,7 +586,7 @@ func TestDoGetGaugeResponse(t *testing.T) {
 			metricName: "not_found",
 			expected: expected{
 				code: 404,
-				body: "[NOT_FOUND] Not found (`not_found` not found)\n",
+				body: "[METRIC_NOT_FOUND] Not found (`not_found` not found)\n",
 			},
 		},
 	}
@@ -198,6 +594,7 @@ func TestDoGetGaugeResponse(t *testing.T) {
 	service := getFlatService.New(
 		nil,
 		getGaugeService.New(&MetricRepositoryMock{}),
+		nil,
 	)
 
 	for _, tt := range tests {
@@ -241,13 +638,14 @@ func TestDoUpdateCounterResponse(t *testing.T) {
 			metricValue: "99.99",
 			expected: expected{
 				code: 400,
-				body: "[BAD_REQUEST] Bad request (invalid metric value)\n",
+				body: "[INVALID_METRIC_VALUE] Bad request (invalid metric value)\n",
 			},
 		},
 	}
 
 	service := updateFlatService.New(
-		updateCounterService.New(&MetricRepositoryMock{}),
+		updateCounterService.New(updateBatchService.New(1, &MetricRepositoryMock{})),
+		nil,
 		nil,
 	)
 
@@ -292,14 +690,15 @@ func TestDoUpdateGaugeResponse(t *testing.T) {
 			metricValue: "99,99",
 			expected: expected{
 				code: 400,
-				body: "[BAD_REQUEST] Bad request (invalid metric value)\n",
+				body: "[INVALID_METRIC_VALUE] Bad request (invalid metric value)\n",
 			},
 		},
 	}
 
 	service := updateFlatService.New(
 		nil,
-		updateGaugeService.New(&MetricRepositoryMock{}),
+		updateGaugeService.New(updateBatchService.New(1, &MetricRepositoryMock{})),
+		nil,
 	)
 
 	for _, tt := range tests {
@@ -316,3 +715,386 @@ func TestDoUpdateGaugeResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestDoHistoryJSONResponse(t *testing.T) {
+	repo := inmemory.New(encode.New())
+
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, value := range []float64{1, 3, 2, 10} {
+		_, ok, err := repo.AddUpdateBatch(context.Background(), nil, []entities.GaugeItem{{
+			MetricType:  "gauge",
+			MetricName:  "ok",
+			MetricValue: value,
+			UpdatedAt:   ts.Add(time.Duration(i) * 20 * time.Second),
+		}}, nil)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	type expected struct {
+		code int
+		body string
+	}
+	tests := []struct {
+		name     string
+		query    string
+		expected expected
+	}{
+		{
+			name:  "positive test",
+			query: "?from=2026-01-01T00:00:00Z&to=2026-01-01T00:02:00Z&step=1m",
+			expected: expected{
+				code: 200,
+				body: `{"id":"ok","type":"gauge","from":"2026-01-01T00:00:00Z","to":"2026-01-01T00:02:00Z","step":"1m0s","points":[` +
+					`{"ts":"2026-01-01T00:00:00Z","min":1,"max":3,"avg":2,"last":2,"count":3},` +
+					`{"ts":"2026-01-01T00:01:00Z","min":10,"max":10,"avg":10,"last":10,"count":1}]}`,
+			},
+		},
+		{
+			name:  "negative test [invalid step]",
+			query: "?step=1ms",
+			expected: expected{
+				code: 400,
+				body: "[BAD_REQUEST] Bad request (invalid step)\n",
+			},
+		},
+		{
+			name:  "negative test [invalid range]",
+			query: "?from=2026-01-01T00:02:00Z&to=2026-01-01T00:00:00Z",
+			expected: expected{
+				code: 400,
+				body: "[BAD_REQUEST] Bad request (invalid range)\n",
+			},
+		},
+	}
+
+	service := historyService.New(repo)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/history/gauge/ok"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.DoHistoryJSONResponse(service.Do, "gauge", "ok").ServeHTTP(w, request)
+
+			assert.Equal(t, tt.expected.code, w.Code)
+			assert.Equal(t, tt.expected.body, w.Body.String())
+		})
+	}
+}
+
+type OutboxRepositoryMock struct {
+	items []entities.Outbox
+}
+
+func (m *OutboxRepositoryMock) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) error {
+	m.items = append(m.items, items...)
+	return nil
+}
+
+// newMetricWriter writes the updates to the repository the way the server does, the audit events are dropped.
+func newMetricWriter(repo *inmemory.Repository) *updateBatchService.Service {
+	return updateBatchService.New(1, pg.New(nil, repo, &OutboxRepositoryMock{}))
+}
+
+func TestDoDeleteResponse(t *testing.T) {
+	repo := inmemory.New(encode.New())
+	outboxRepo := &OutboxRepositoryMock{}
+	service := deleteMetricService.New(pg.New(nil, repo, outboxRepo))
+
+	ts := time.Now()
+	for _, item := range []entities.GaugeItem{
+		{MetricName: "HeapAlloc", MetricValue: 1, UpdatedAt: ts},
+		{MetricName: "HeapAlloc", Labels: pkg.Labels{"host": "a"}, MetricValue: 1, UpdatedAt: ts},
+		{MetricName: "HeapIdle", MetricValue: 1, UpdatedAt: ts},
+		{MetricName: "Alloc", MetricValue: 1, UpdatedAt: ts.Add(-2 * time.Hour)},
+	} {
+		_, _, err := repo.AddUpdateBatch(context.Background(), nil, []entities.GaugeItem{item}, nil)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "positive test", path: "/value/gauge/HeapAlloc?host=a", code: 200},
+		{name: "negative test [already deleted]", path: "/value/gauge/HeapAlloc?host=a", code: 404},
+		{name: "negative test [type mismatch]", path: "/value/counter/HeapAlloc", code: 404},
+		{name: "negative test [invalid type]", path: "/value/unknown/HeapAlloc", code: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodDelete, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			parts := strings.Split(request.URL.Path, "/")
+			handler.DoDeleteFlatResponse(service.Do, parts[2], parts[3]).ServeHTTP(w, request)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodDelete, "/values/?prefix=Heap", nil)
+	w := httptest.NewRecorder()
+	handler.DoDeletePrefixResponse(service.DoPrefix).ServeHTTP(w, request)
+	require.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `["HeapAlloc","HeapIdle"]`, w.Body.String())
+
+	request = httptest.NewRequest(http.MethodDelete, "/values/", nil)
+	w = httptest.NewRecorder()
+	handler.DoDeletePrefixResponse(service.DoPrefix).ServeHTTP(w, request)
+	assert.Equal(t, 400, w.Code)
+
+	deleted, err := service.Expire(context.Background(), ts.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Alloc"}, deleted)
+
+	data, err := repo.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, data.Gauges)
+
+	var reasons []string
+	var metrics [][]string
+	for _, item := range outboxRepo.items {
+		if item.Destination != string(models.AuditOutboxDestination) {
+			continue
+		}
+		var event models.DeleteEvent
+		require.NoError(t, json.Unmarshal(item.Payload, &event))
+		assert.Equal(t, "delete", event.Action)
+		reasons = append(reasons, event.Reason)
+		metrics = append(metrics, event.Metrics)
+	}
+	assert.Equal(t, []string{models.DeleteReasonRequest, models.DeleteReasonRequest, models.DeleteReasonRetention}, reasons)
+	assert.Equal(t, [][]string{{`HeapAlloc{host="a"}`}, {"HeapAlloc", "HeapIdle"}, {"Alloc"}}, metrics)
+}
+
+func TestDoSilenceResponse(t *testing.T) {
+	service := silenceService.New(silence.New(nil))
+
+	add := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/silences", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.DoAddSilenceResponse(service.Add).ServeHTTP(w, request)
+		return w
+	}
+
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	w := add(`{"metric_name":"HeapAlloc","matchers":{"host":"a"},"ends_at":"` + endsAt + `","comment":"deploy"}`)
+	require.Equal(t, 200, w.Code)
+
+	var created models.Silence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "1", created.ID)
+	assert.Equal(t, "HeapAlloc", created.MetricName)
+	assert.False(t, created.StartsAt.IsZero())
+
+	w = add(`{"ends_at":"` + endsAt + `"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "[BAD_REQUEST] Bad request (silence must match a rule, a metric or labels)\n", w.Body.String())
+
+	w = add(`{"metric_name":"HeapAlloc","ends_at":"2020-01-01T00:00:00Z"}`)
+	assert.Equal(t, 400, w.Code)
+
+	request := httptest.NewRequest(http.MethodGet, "/silences", nil)
+	w = httptest.NewRecorder()
+	handler.DoListSilenceResponse(service.List).ServeHTTP(w, request)
+	require.Equal(t, 200, w.Code)
+
+	var silences []models.Silence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &silences))
+	require.Len(t, silences, 1)
+	assert.Equal(t, created.ID, silences[0].ID)
+
+	for _, code := range []int{200, 404} {
+		request := httptest.NewRequest(http.MethodDelete, "/silences/1", nil)
+		w := httptest.NewRecorder()
+		handler.DoDeleteSilenceResponse(service.Delete, "1").ServeHTTP(w, request)
+		assert.Equal(t, code, w.Code)
+	}
+}
+
+func TestDoExportMetricResponse(t *testing.T) {
+	type expected struct {
+		code        int
+		contentType string
+		body        string
+	}
+	tests := []struct {
+		name     string
+		accept   string
+		expected expected
+	}{
+		{
+			name: "positive test [text]",
+			expected: expected{
+				code:        200,
+				contentType: exportMetricService.ContentTypeText,
+				body: "# HELP counter counter\n# TYPE counter counter\ncounter 99\n" +
+					"# HELP gauge gauge\n# TYPE gauge gauge\ngauge 99.99\n",
+			},
+		},
+		{
+			name:   "positive test [openmetrics]",
+			accept: "application/openmetrics-text;version=1.0.0,text/plain;q=0.5",
+			expected: expected{
+				code:        200,
+				contentType: exportMetricService.ContentTypeOpenMetrics,
+				body: "# HELP counter counter\n# TYPE counter counter\ncounter_total 99\n" +
+					"# HELP gauge gauge\n# TYPE gauge gauge\ngauge 99.99\n# EOF\n",
+			},
+		},
+	}
+
+	handler := handler.DoExportMetricResponse(exportMetricService.New(&MetricRepositoryMock{}).Do)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			request.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.expected.code, w.Code)
+			assert.Equal(t, tt.expected.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expected.body, w.Body.String())
+		})
+	}
+}
+
+type BatchRepositoryMock struct {
+	counters []entities.CounterItem
+	gauges   []entities.GaugeItem
+}
+
+func (m *BatchRepositoryMock) AddUpdateBatch(
+	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	outboxes []entities.Outbox, outboxSegment string,
+) (ok bool, err error) {
+	m.counters = append(m.counters, counters...)
+	m.gauges = append(m.gauges, gauges...)
+	return true, nil
+}
+
+func TestDoRemoteWriteResponse(t *testing.T) {
+	label := func(name, value string) *prompb.Label {
+		return &prompb.Label{Name: name, Value: value}
+	}
+	sample := func(value float64) *prompb.Sample {
+		return &prompb.Sample{Value: value}
+	}
+
+	write := &prompb.WriteRequest{
+		Metadata: []*prompb.MetricMetadata{
+			{MetricFamilyName: "requests", Type: prompb.MetricMetadata_COUNTER},
+			{MetricFamilyName: "info", Type: prompb.MetricMetadata_INFO},
+		},
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{label("__name__", "temp"), label("host", "b"), label("dc", "a")},
+				Samples: []*prompb.Sample{sample(1.5), sample(2.5)},
+			},
+			{
+				Labels:  []*prompb.Label{label("__name__", "requests")},
+				Samples: []*prompb.Sample{sample(10), sample(15)},
+			},
+			{
+				Labels:  []*prompb.Label{label("__name__", "info")},
+				Samples: []*prompb.Sample{sample(1)},
+			},
+			{
+				Labels:  []*prompb.Label{label("host", "a")},
+				Samples: []*prompb.Sample{sample(1)},
+			},
+			{
+				Labels:     []*prompb.Label{label("__name__", "latency")},
+				Histograms: []*prompb.Histogram{{}},
+			},
+		},
+	}
+
+	data, err := proto.Marshal(write)
+	require.NoError(t, err)
+
+	repo := &BatchRepositoryMock{}
+	service := remoteWriteService.New(updateBatchService.New(1, repo), &MetricRepositoryMock{})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(snappy.Encode(nil, data)))
+	w := httptest.NewRecorder()
+
+	handler.DoRemoteWriteResponse(service.Do).ServeHTTP(w, request)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"accepted":2,"rejected":[`+
+		`{"series":"info","reason":"unsupported metric type"},`+
+		`{"series":"{host=\"a\"}","reason":"missing __name__ label"},`+
+		`{"series":"latency","reason":"native histograms are not supported"}]}`, w.Body.String())
+
+	require.Len(t, repo.gauges, 1)
+	assert.Equal(t, "temp", repo.gauges[0].MetricName)
+	assert.Equal(t, pkg.Labels{"dc": "a", "host": "b"}, repo.gauges[0].Labels)
+	assert.Equal(t, 2.5, repo.gauges[0].MetricValue)
+
+	require.Len(t, repo.counters, 1)
+	assert.Equal(t, "requests", repo.counters[0].MetricName)
+	assert.Equal(t, int64(15), repo.counters[0].MetricValue)
+
+	t.Run("positive test [counter increase]", func(t *testing.T) {
+		repo.counters = nil
+
+		for _, value := range []float64{20, 26} {
+			data, err := proto.Marshal(&prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{
+					{
+						Labels:  []*prompb.Label{label("__name__", "requests_total")},
+						Samples: []*prompb.Sample{sample(value)},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(snappy.Encode(nil, data)))
+			w := httptest.NewRecorder()
+
+			handler.DoRemoteWriteResponse(service.Do).ServeHTTP(w, request)
+			assert.Equal(t, 200, w.Code)
+		}
+
+		require.Len(t, repo.counters, 2)
+		assert.Equal(t, int64(20), repo.counters[0].MetricValue)
+		assert.Equal(t, int64(6), repo.counters[1].MetricValue)
+	})
+
+	t.Run("negative test [not snappy]", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader([]byte("plain")))
+		w := httptest.NewRecorder()
+
+		handler.DoRemoteWriteResponse(service.Do).ServeHTTP(w, request)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
+	t.Run("negative test [body too large]", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(make([]byte, 9<<20)))
+		w := httptest.NewRecorder()
+
+		handler.DoRemoteWriteResponse(service.Do).ServeHTTP(w, request)
+
+		assert.Equal(t, 413, w.Code)
+	})
+
+	t.Run("negative test [decoded body too large]", func(t *testing.T) {
+		// the snappy preamble declares the decoded length ahead of the data
+		body := binary.AppendUvarint(nil, 64<<20)
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.DoRemoteWriteResponse(service.Do).ServeHTTP(w, request)
+
+		assert.Equal(t, 413, w.Code)
+	})
+}
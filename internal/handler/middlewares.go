@@ -2,21 +2,44 @@ package handler
 
 import (
 	"compress/gzip"
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
 )
 
 const (
 	TypeContentTextPlain       = "text/plain"
 	TypeContentApplicationJSON = "application/json"
+	TypeContentProblemJSON     = "application/problem+json"
+)
+
+var (
+	errUnsupportedContentType = pkg.ErrUnsupportedMediaType.SetInfo("not supported Content-Type")
+	errInvalidURL             = pkg.ErrNotFound.SetInfo("invalid URL")
+	errInvalidMetricType      = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
+	errInvalidMetricName      = pkg.ErrNotFound.SetCode(pkg.CodeInvalidMetricName).SetInfo("invalid metric name")
 )
 
 var allowMetricType = map[string]struct{}{
-	pkg.MetricTypeGauge:   {},
-	pkg.MetricTypeCounter: {},
+	pkg.MetricTypeGauge:     {},
+	pkg.MetricTypeCounter:   {},
+	pkg.MetricTypeHistogram: {},
 }
 
 type Middleware = func(next http.Handler) http.Handler
@@ -28,10 +51,69 @@ func Conveyor(h http.Handler, middlewares ...Middleware) http.Handler {
 	return h
 }
 
+// MiddlewareRequestID puts the request ID taken from X-Request-ID or traceparent,
+// or a new one, into the request context and echoes it in the response.
+func MiddlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		id := requestid.Resolve(rq.Header.Get(requestid.Header), rq.Header.Get(requestid.TraceparentHeader))
+		w.Header().Set(requestid.Header, id)
+
+		next.ServeHTTP(w, rq.WithContext(requestid.WithID(rq.Context(), id)))
+	})
+}
+
+// MiddlewareTracing starts a server span for the request, continuing the trace of the
+// incoming traceparent header, and names it after the matched route once it is served.
+func MiddlewareTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(rq.Context(), propagation.HeaderCarrier(rq.Header))
+		ctx, span := tracing.Start(ctx, rq.Method,
+			attribute.String("http.request.method", rq.Method),
+			attribute.String("url.path", rq.URL.Path),
+			attribute.String("request.id", requestid.FromContext(ctx)),
+		)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, rq.WithContext(ctx))
+
+		if route := chi.RouteContext(rq.Context()).RoutePattern(); route != "" {
+			span.SetName(rq.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprint(sw.status))
+		}
+	})
+}
+
+// MiddlewareSelfMetrics counts the requests and observes their latencies
+// per matched route in the default selfmetrics registry.
+func MiddlewareSelfMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		start := time.Now()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, rq)
+
+		route := chi.RouteContext(rq.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := pkg.Labels{"method": rq.Method, "route": route}
+		selfmetrics.ObserveSince("http_request_duration_seconds", labels, start)
+
+		labels["status"] = strconv.Itoa(sw.status)
+		selfmetrics.Inc("http_requests_total", labels)
+	})
+}
+
 func MiddlewareTypeContentTextPlain(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
 		if rq.Header.Get("Content-Type") != TypeContentTextPlain {
-			http.Error(w, "not supported Content-Type", http.StatusNotFound)
+			writeRejection(w, rq, errUnsupportedContentType, http.StatusNotFound)
 			return
 		}
 
@@ -42,7 +124,7 @@ func MiddlewareTypeContentTextPlain(next http.Handler) http.Handler {
 func MiddlewareTypeContentApplicationJSON(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
 		if rq.Header.Get("Content-Type") != TypeContentApplicationJSON {
-			http.Error(w, "not supported Content-Type", http.StatusNotFound)
+			writeRejection(w, rq, errUnsupportedContentType, http.StatusNotFound)
 			return
 		}
 
@@ -53,7 +135,7 @@ func MiddlewareTypeContentApplicationJSON(next http.Handler) http.Handler {
 func MiddlewareURLPath(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
 		if len(strings.Split(rq.URL.Path, "/")) != 5 {
-			http.Error(w, "invalid URL", http.StatusNotFound)
+			writeRejection(w, rq, errInvalidURL, http.StatusNotFound)
 			return
 		}
 		next.ServeHTTP(w, rq)
@@ -64,7 +146,7 @@ func MiddlewareMetricType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
 		parts := strings.Split(rq.URL.Path, "/")
 		if _, ok := allowMetricType[parts[2]]; !ok {
-			http.Error(w, "invalid metric type", http.StatusBadRequest)
+			writeRejection(w, rq, errInvalidMetricType, http.StatusBadRequest)
 			return
 		}
 
@@ -76,7 +158,7 @@ func MiddlewareMetricName(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
 		name := strings.Split(rq.URL.Path, "/")[3]
 		if name == "" {
-			http.Error(w, "invalid metric name", http.StatusNotFound)
+			writeRejection(w, rq, errInvalidMetricName, http.StatusNotFound)
 			return
 		}
 
@@ -84,6 +166,16 @@ func MiddlewareMetricName(next http.Handler) http.Handler {
 	})
 }
 
+// writeRejection answers the clients asking for problem+json with the error,
+// the others get the plain body and status the middlewares have always answered with.
+func writeRejection(w http.ResponseWriter, rq *http.Request, err *pkg.Error, legacyStatus int) {
+	if acceptsProblem(rq.Header.Get("Accept")) {
+		WriteError(w, rq, err)
+		return
+	}
+	http.Error(w, err.Info, legacyStatus)
+}
+
 func MiddlewareCompress(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		w := rw
@@ -117,13 +209,10 @@ func MiddlewareCompress(next http.Handler) http.Handler {
 	})
 }
 
+// MiddlewareLocalhost hides the routes from the clients connected from other hosts.
 func MiddlewareLocalhost(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cond := strings.HasPrefix(r.Host, "localhost:") ||
-			strings.HasPrefix(r.Host, "127.0.0.1:") ||
-			strings.HasPrefix(r.Host, "[::1]:")
-
-		if !cond {
+		if !isLoopback(r.RemoteAddr) {
 			http.NotFound(w, r)
 			return
 		}
@@ -131,3 +220,14 @@ func MiddlewareLocalhost(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r)
 	})
 }
+
+// isLoopback reports whether the connection comes from the loopback interface.
+// The peer address is checked, the Host header is set by the client and proves nothing.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.Unmap().IsLoopback()
+}
@@ -3,15 +3,25 @@ package handler_test
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/handler"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
 )
 
 const testMessage = `Got you`
@@ -72,6 +82,49 @@ func TestMiddlewareTypeContentTextPlain(t *testing.T) {
 	}
 }
 
+func TestMiddlewareRejectionProblem(t *testing.T) {
+	tests := []struct {
+		name        string
+		middleware  handler.Middleware
+		url         string
+		contentType string
+		code        int
+		errCode     string
+	}{
+		{
+			name:        "content type",
+			middleware:  handler.MiddlewareTypeContentApplicationJSON,
+			url:         "/update/",
+			contentType: "text/plain",
+			code:        415,
+			errCode:     "UNSUPPORTED_MEDIA_TYPE",
+		},
+		{
+			name:       "metric type",
+			middleware: handler.MiddlewareMetricType,
+			url:        "/update/other/name/1",
+			code:       400,
+			errCode:    "INVALID_METRIC_TYPE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, tt.url, nil)
+			request.Header.Set("Content-Type", tt.contentType)
+			request.Header.Set("Accept", "application/problem+json")
+			w := httptest.NewRecorder()
+
+			tt.middleware(testHandler()).ServeHTTP(w, request)
+
+			var problem handler.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.errCode, string(problem.Code))
+		})
+	}
+}
+
 func TestMiddlewareURLPath(t *testing.T) {
 	type want struct {
 		code    int
@@ -229,6 +282,88 @@ func TestMiddlewareMetricName(t *testing.T) {
 	}
 }
 
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestID   string
+		traceparent string
+		want        string
+	}{
+		{name: "request id", requestID: "req-1", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: "req-1"},
+		{name: "traceparent", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("X-Request-ID", tt.requestID)
+			request.Header.Set("traceparent", tt.traceparent)
+			w := httptest.NewRecorder()
+
+			var got string
+			middleware := handler.MiddlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestid.FromContext(r.Context())
+			}))
+			middleware.ServeHTTP(w, request)
+
+			require.NotEmpty(t, got)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, got)
+			}
+			assert.Equal(t, got, w.Header().Get("X-Request-ID"))
+		})
+	}
+}
+
+func TestMiddlewareTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(prev)
+
+	router := chi.NewRouter()
+	router.Use(handler.MiddlewareTracing)
+	router.Get("/value/{type}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/value/gauge/alloc", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /value/{type}/{name}", spans[0].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+}
+
+func TestMiddlewareSelfMetrics(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(handler.MiddlewareSelfMetrics)
+	router.Post("/update/{type}/{name}/{value}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	count := func() int64 {
+		for _, x := range selfmetrics.Default.Snapshot().Counters {
+			if x.Name == "http_requests_total" && x.Labels.Equal(pkg.Labels{
+				"method": http.MethodPost, "route": "/update/{type}/{name}/{value}", "status": "400",
+			}) {
+				return x.Value
+			}
+		}
+		return 0
+	}
+
+	before := count()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/update/gauge/alloc/x", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/update/gauge/alloc/y", nil))
+
+	assert.Equal(t, before+2, count())
+}
+
 func TestMiddlewareCompress(t *testing.T) {
 	middleware := handler.MiddlewareCompress(testHandler())
 
@@ -292,45 +427,55 @@ func TestMiddlewareLocalhost(t *testing.T) {
 		message string
 	}
 	tests := []struct {
-		name string
-		host string
-		want want
+		name       string
+		remoteAddr string
+		host       string
+		want       want
 	}{
 		{
-			name: "positive test [localhost]",
-			host: "localhost:",
+			name:       "positive test [127.0.0.1]",
+			remoteAddr: "127.0.0.1:50000",
 			want: want{
 				code:    200,
 				message: testMessage,
 			},
 		},
 		{
-			name: "positive test [127.0.0.1]",
-			host: "127.0.0.1:",
+			name:       "positive test [::1] with brackets",
+			remoteAddr: "[::1]:50000",
 			want: want{
 				code:    200,
 				message: testMessage,
 			},
 		},
 		{
-			name: "positive test [::1] with brackets",
-			host: "[::1]:",
+			name:       "positive test [ipv4 mapped]",
+			remoteAddr: "[::ffff:127.0.0.1]:50000",
 			want: want{
 				code:    200,
 				message: testMessage,
 			},
 		},
 		{
-			name: "negative test [example.com]",
-			host: "example.com:",
+			name:       "negative test [192.168.1.1]",
+			remoteAddr: "192.168.1.1:50000",
+			want: want{
+				code:    404,
+				message: "404 page not found\n",
+			},
+		},
+		{
+			name:       "negative test [spoofed host]",
+			remoteAddr: "203.0.113.7:50000",
+			host:       "localhost:8080",
 			want: want{
 				code:    404,
 				message: "404 page not found\n",
 			},
 		},
 		{
-			name: "negative test [192.168.1.1]",
-			host: "192.168.1.1:",
+			name:       "negative test [no port]",
+			remoteAddr: "127.0.0.1",
 			want: want{
 				code:    404,
 				message: "404 page not found\n",
@@ -340,7 +485,10 @@ func TestMiddlewareLocalhost(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodGet, "/", nil)
-			request.Host = tt.host
+			request.RemoteAddr = tt.remoteAddr
+			if tt.host != "" {
+				request.Host = tt.host
+			}
 			w := httptest.NewRecorder()
 
 			middleware := handler.MiddlewareLocalhost(testHandler())
@@ -5,6 +5,13 @@ import (
 	"compress/gzip"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
 )
 
 type responseHashWriter struct {
@@ -23,13 +30,23 @@ func (rh *responseHashWriter) WriteHeader(statusCode int) {
 	if statusCode == http.StatusOK {
 		hash, err := rh.hashFunc(rh.body.Bytes())
 		if err != nil {
-			WriteError(rh.ResponseWriter, err)
+			WriteError(rh.ResponseWriter, nil, err)
 			return
 		}
 		rh.ResponseWriter.Header().Set("HashSHA256", hash)
 	}
 }
 
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(statusCode int) {
+	s.ResponseWriter.WriteHeader(statusCode)
+	s.status = statusCode
+}
+
 type ResponseInfo struct {
 	Size   int
 	Status int
@@ -93,3 +110,75 @@ func (c *compressReader) Close() error {
 	}
 	return c.zr.Close()
 }
+
+// queryLabels collects the query parameters, except the reserved ones, as metric labels.
+func queryLabels(query url.Values, reserved ...string) pkg.Labels {
+	var labels pkg.Labels
+	for name, values := range query {
+		if len(values) == 0 || slices.Contains(reserved, name) {
+			continue
+		}
+		if labels == nil {
+			labels = make(pkg.Labels, len(query))
+		}
+		labels[name] = values[0]
+	}
+	return labels
+}
+
+// etag returns the strong entity tag of a metric version, e.g. `"5"`.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseVersion parses the expected metric version given either as an entity tag
+// from the If-Match header or as a plain number, `*` and an empty value match any version.
+func parseVersion(s string) (*int64, error) {
+	if s == "" || s == "*" {
+		return nil, nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	version, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || version < 0 {
+		return nil, pkg.ErrBadRequest.SetInfof("invalid version %q", s)
+	}
+	return &version, nil
+}
+
+// Problem is the RFC 7807 representation of an error.
+type Problem struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Status    int           `json:"status"`
+	Detail    string        `json:"detail,omitempty"`
+	Instance  string        `json:"instance,omitempty"`
+	Code      pkg.ErrorCode `json:"code"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+func newProblem(r *http.Request, err *pkg.Error) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     err.Message,
+		Status:    err.HTTPStatus(),
+		Detail:    err.Info,
+		Instance:  r.URL.Path,
+		Code:      err.Code,
+		RequestID: requestid.FromContext(r.Context()),
+	}
+}
+
+// acceptsProblem reports whether the Accept header lists application/problem+json.
+func acceptsProblem(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.TrimSpace(mediaType) == TypeContentProblemJSON {
+			return true
+		}
+	}
+	return false
+}
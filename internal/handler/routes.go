@@ -12,15 +12,24 @@ import (
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/config/db"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/logger"
+	deadLetterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/deadLetterService/v0"
 	decryptService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/decryptService/service"
+	deleteMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/deleteMetricService/v0"
 	dumpMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/dumpMetricService/v0"
+	exportMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/exportMetricService/v0"
 	getFlatService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getFlatService/v0"
 	getService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getService/v0"
 	hashService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/hashService/v0"
+	historyService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/historyService/v0"
 	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	remoteWriteService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/remoteWriteService/v0"
+	silenceService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/silenceService/v0"
 	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
 	updateFlatService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateFlatService/v0"
 	updateService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/principal"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
 )
 
 type Route struct {
@@ -36,11 +45,22 @@ type API struct {
 	updateFlatService  *updateFlatService.Service
 	updateBatchService *updateBatchService.Service
 	updateService      *updateService.Service
+	remoteWriteService *remoteWriteService.Service
 
 	getFlatService *getFlatService.Service
 	getService     *getService.Service
 
-	listMetricService *listMetricService.Service
+	listMetricService   *listMetricService.Service
+	exportMetricService *exportMetricService.Service
+	selfExportService   *exportMetricService.Service
+
+	historyService *historyService.Service
+
+	deleteMetricService *deleteMetricService.Service
+
+	silenceService *silenceService.Service
+
+	deadLetterService *deadLetterService.Service
 
 	dumpSyncMetricService *dumpMetricService.Service
 	hashService           *hashService.Service
@@ -53,22 +73,40 @@ func New(
 	updateFlatService *updateFlatService.Service,
 	updateBatchService *updateBatchService.Service,
 	updateService *updateService.Service,
+	remoteWriteService *remoteWriteService.Service,
 	getFlatService *getFlatService.Service,
 	getService *getService.Service,
 	listMetricService *listMetricService.Service,
+	exportMetricService *exportMetricService.Service,
+	selfExportService *exportMetricService.Service,
+	historyService *historyService.Service,
+	deleteMetricService *deleteMetricService.Service,
+	silenceService *silenceService.Service,
+	deadLetterService *deadLetterService.Service,
 	dumpSyncMetricService *dumpMetricService.Service,
 	hashService *hashService.Service,
 	decryptService decryptService.DecryptService,
 ) *API {
+	router := chi.NewRouter()
+	router.Use(MiddlewareTracing)
+	router.Use(MiddlewareSelfMetrics)
+
 	return &API{
-		router:                chi.NewRouter(),
+		router:                router,
 		logger:                logger,
 		updateFlatService:     updateFlatService,
 		updateBatchService:    updateBatchService,
 		updateService:         updateService,
+		remoteWriteService:    remoteWriteService,
 		getFlatService:        getFlatService,
 		getService:            getService,
 		listMetricService:     listMetricService,
+		exportMetricService:   exportMetricService,
+		selfExportService:     selfExportService,
+		historyService:        historyService,
+		deleteMetricService:   deleteMetricService,
+		silenceService:        silenceService,
+		deadLetterService:     deadLetterService,
 		dumpSyncMetricService: dumpSyncMetricService,
 		hashService:           hashService,
 		decryptService:        decryptService,
@@ -85,7 +123,8 @@ func (api API) RegisterPing(db *db.PGConnect) {
 		defer cancel()
 
 		if err := db.Ping(ctx); err != nil {
-			WriteError(w, err)
+			WriteError(w, r, pkg.ErrUnavailable.SetInfo(err.Error()))
+			return
 		}
 
 		WriteOK(w)
@@ -97,6 +136,14 @@ func (api API) RegisterHandlers() {
 		r.Get("/", DoListMetricResponse(api.listMetricService.Do).ServeHTTP)
 	})
 
+	api.router.Group(func(r chi.Router) {
+		r.Get("/metrics", DoExportMetricResponse(api.exportMetricService.Do).ServeHTTP)
+	})
+
+	api.router.Group(func(r chi.Router) {
+		r.Get("/internal/metrics", DoExportMetricResponse(api.selfExportService.Do).ServeHTTP)
+	})
+
 	api.router.Group(func(r chi.Router) {
 		r.Use(api.WithLogging)
 		r.Use(MiddlewareMetricName)
@@ -119,6 +166,12 @@ func (api API) RegisterHandlers() {
 		r.Post("/updates/", DoUpdateBatchJSONResponse(api.updateBatchService.Do).ServeHTTP)
 	})
 
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.WithLogging)
+		r.Use(api.WithSync)
+		r.Post("/api/v1/write", DoRemoteWriteResponse(api.remoteWriteService.Do).ServeHTTP)
+	})
+
 	api.router.Group(func(r chi.Router) {
 		r.Use(api.WithLogging)
 		r.Use(MiddlewareMetricName)
@@ -133,6 +186,53 @@ func (api API) RegisterHandlers() {
 		r.Use(api.WithLogging)
 		r.Post("/value/", DoGetJSONResponse(api.getService.Do).ServeHTTP)
 	})
+
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.WithLogging)
+		r.Use(api.WithSync)
+		r.Use(MiddlewareMetricName)
+		r.Delete("/value/{type}/{name}", func(w http.ResponseWriter, rq *http.Request) {
+			DoDeleteFlatResponse(
+				api.deleteMetricService.Do, chi.URLParam(rq, "type"), chi.URLParam(rq, "name"),
+			).ServeHTTP(w, rq)
+		})
+	})
+
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.WithLogging)
+		r.Use(api.WithSync)
+		r.Delete("/values/", DoDeletePrefixResponse(api.deleteMetricService.DoPrefix).ServeHTTP)
+	})
+
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.WithLogging)
+		r.Use(MiddlewareMetricName)
+		r.Get("/history/{type}/{name}", func(w http.ResponseWriter, rq *http.Request) {
+			DoHistoryJSONResponse(
+				api.historyService.Do, chi.URLParam(rq, "type"), chi.URLParam(rq, "name"),
+			).ServeHTTP(w, rq)
+		})
+	})
+
+	// the silences mute the alerts, so they are managed from the host only
+	api.router.Group(func(r chi.Router) {
+		r.Use(MiddlewareLocalhost)
+		r.Use(api.WithLogging)
+		r.Get("/silences", DoListSilenceResponse(api.silenceService.List).ServeHTTP)
+		r.Post("/silences", DoAddSilenceResponse(api.silenceService.Add).ServeHTTP)
+		r.Delete("/silences/{id}", func(w http.ResponseWriter, rq *http.Request) {
+			DoDeleteSilenceResponse(api.silenceService.Delete, chi.URLParam(rq, "id")).ServeHTTP(w, rq)
+		})
+	})
+
+	// the dead letters carry the audit events, so they are managed from the host only
+	api.router.Group(func(r chi.Router) {
+		r.Use(MiddlewareLocalhost)
+		r.Use(api.WithLogging)
+		r.Get("/admin/dead-letters", DoListDeadLetterResponse(api.deadLetterService.List).ServeHTTP)
+		r.Post("/admin/dead-letters/requeue", DoRequeueDeadLetterResponse(api.deadLetterService.Requeue).ServeHTTP)
+		r.Delete("/admin/dead-letters", DoPurgeDeadLetterResponse(api.deadLetterService.Purge).ServeHTTP)
+	})
 }
 
 func (api API) WithLogging(h http.Handler) http.Handler {
@@ -148,9 +248,10 @@ func (api API) WithLogging(h http.Handler) http.Handler {
 		h.ServeHTTP(&rw, r)
 
 		api.logger.LogHTTP(logger.HTTPInfo{
-			URI:      r.RequestURI,
-			Method:   r.Method,
-			Duration: time.Since(start),
+			RequestID: requestid.FromContext(r.Context()),
+			URI:       r.RequestURI,
+			Method:    r.Method,
+			Duration:  time.Since(start),
 			Response: logger.ResponseInfo{
 				Size:   resp.Size,
 				Status: resp.Status,
@@ -172,22 +273,25 @@ func (api API) WithSync(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r)
 
 		if err := api.dumpSyncMetricService.WriteDump(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, pkg.ErrInternalServer.SetInfo(err.Error()))
 		}
 	})
 }
 
+// WithHash validates the signed requests and signs their responses, the request
+// context carries the principal the request was made by.
 func (api API) WithHash(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		hw := w
+		signed := false
 		if hash := r.Header.Get("HashSHA256"); hash != "" {
 			buf := new(bytes.Buffer)
 			if _, err := io.Copy(buf, r.Body); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				WriteError(w, r, pkg.ErrInternalServer.SetInfo(err.Error()))
 			}
 
 			if err := api.hashService.Validate(r.Context(), buf.Bytes(), hash); err != nil {
-				WriteError(w, err)
+				WriteError(w, r, err)
 				return
 			}
 
@@ -200,9 +304,10 @@ func (api API) WithHash(h http.Handler) http.Handler {
 					return api.hashService.Hash(r.Context(), message)
 				},
 			}
+			signed = true
 		}
 
-		h.ServeHTTP(hw, r)
+		h.ServeHTTP(hw, r.WithContext(principal.WithPrincipal(r.Context(), principal.Resolve(r.TLS, signed))))
 	})
 }
 
@@ -210,12 +315,12 @@ func (api API) WithDecrypt(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		encrypted, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, pkg.ErrInternalServer.SetInfo(err.Error()))
 		}
 
 		decrypted, err := api.decryptService.Decrypt(r.Context(), encrypted)
 		if err != nil {
-			WriteError(w, err)
+			WriteError(w, r, err)
 			return
 		}
 
@@ -10,10 +10,11 @@ type HTTPLogger interface {
 }
 
 type HTTPInfo struct {
-	URI      string        `json:"uri"`
-	Method   string        `json:"method"`
-	Duration time.Duration `json:"duration"`
-	Response ResponseInfo  `json:"response"`
+	RequestID string        `json:"request_id,omitempty"`
+	URI       string        `json:"uri"`
+	Method    string        `json:"method"`
+	Duration  time.Duration `json:"duration"`
+	Response  ResponseInfo  `json:"response"`
 }
 
 type ResponseInfo struct {
@@ -0,0 +1,56 @@
+package models
+
+// Audit sink types.
+const (
+	FileSinkType   = "file"
+	HTTPSinkType   = "http"
+	SyslogSinkType = "syslog"
+	UnixSinkType   = "unix"
+	StdoutSinkType = "stdout"
+)
+
+// AuditSink is a named destination the audit events are delivered to.
+type AuditSink struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Path is the file of the file sink or the socket of the unix sink.
+	Path string `json:"path"`
+	// URL is the endpoint of the http sink.
+	URL string `json:"url"`
+	// Network is udp or tcp for the syslog sink, unix or unixgram for the unix sink.
+	Network string `json:"network"`
+	// Address is the host:port of the syslog sink.
+	Address string `json:"address"`
+	// Tag is the app name of the syslog messages.
+	Tag string `json:"tag"`
+	// Filter routes only the matching events to the sink.
+	Filter AuditFilter `json:"filter"`
+	// BatchSize is the number of events delivered to the sink per run.
+	BatchSize int `json:"batch_size"`
+	// MaxSize rotates the file of the file sink when it grows over the size in bytes.
+	MaxSize int64 `json:"max_size"`
+	// RotateEvery is the duration the file of the file sink is rotated at, e.g. 24h.
+	RotateEvery string `json:"rotate_every"`
+	// MaxFiles is the number of the rotated files of the file sink kept.
+	MaxFiles int `json:"max_files"`
+	// MaxAge is the duration the rotated files of the file sink are kept for, e.g. 720h.
+	MaxAge string `json:"max_age"`
+	// Format is json, array or ndjson, the http sink posts the events one by one
+	// in the json format and RequestSize of them per request in the other ones.
+	Format      string `json:"format"`
+	RequestSize int    `json:"request_size"`
+	// Key signs the requests of the http sink instead of the server key.
+	Key string `json:"key"`
+	// Timeout and ConnectTimeout are the durations of the http sink requests, e.g. 10s.
+	Timeout        string `json:"timeout"`
+	ConnectTimeout string `json:"connect_timeout"`
+	// MaxRetries is the number of the immediate retries of the http sink requests.
+	MaxRetries uint16 `json:"max_retries"`
+}
+
+// AuditFilter matches the audit events, the empty fields match any.
+type AuditFilter struct {
+	Actions []string `json:"actions"`
+	// Metrics are the prefixes of the metric names.
+	Metrics []string `json:"metrics"`
+}
@@ -1,24 +1,102 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 type OutboxDestination string
 
 const (
-	FileOutboxDestination   OutboxDestination = "file"
-	RemoteOutboxDestination OutboxDestination = "remote"
+	AuditOutboxDestination   OutboxDestination = "audit"
+	NotifyOutboxDestination  OutboxDestination = "notify"
+	WebhookOutboxDestination OutboxDestination = "webhook"
+)
+
+// AuditSinkDestination is the destination of the audit events queued for the sink.
+func AuditSinkDestination(sink string) OutboxDestination {
+	return AuditOutboxDestination + ":" + OutboxDestination(sink)
+}
+
+// Audit actions.
+const (
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	AuditActionAlert  = "alert"
 )
 
-type FileEvent struct {
+// DeadLetter is an outbox item that has failed too many deliveries.
+type DeadLetter struct {
+	ID          string          `json:"id"`
+	Destination string          `json:"destination"`
+	Segment     string          `json:"segment"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type UpdateEvent struct {
 	TS        time.Time `json:"ts"`
+	Action    string    `json:"action"`
 	Metrics   []string  `json:"metrics"`
 	IPAddress string    `json:"ip_address"`
+	RequestID string    `json:"request_id,omitempty"`
+	Principal string    `json:"principal,omitempty"`
+	// Changes are filled in by the repository along with the update.
+	Changes []MetricChange `json:"changes,omitempty"`
+}
+
+// MetricChange is the change of a series made by the update.
+type MetricChange struct {
+	Type   string     `json:"type"`
+	Name   string     `json:"name"`
+	Labels pkg.Labels `json:"labels,omitempty"`
+	Op     string     `json:"op,omitempty"`
+	// Submitted is the counter delta, the gauge value or the histogram sum and count of the update.
+	Submitted json.RawMessage `json:"submitted"`
+	// Previous is the stored value before the update, null for the new series.
+	Previous json.RawMessage `json:"previous"`
+	// Result is the stored value after the update.
+	Result  json.RawMessage `json:"result"`
+	Version int64           `json:"version,omitempty"`
 }
 
-type RemoteEvent struct {
+type AlertStatus string
+
+const (
+	AlertFiring   AlertStatus = "firing"
+	AlertResolved AlertStatus = "resolved"
+)
+
+type AlertEvent struct {
+	TS          time.Time   `json:"ts"`
+	Action      string      `json:"action,omitempty"`
+	Status      AlertStatus `json:"status"`
+	Rule        string      `json:"rule"`
+	MetricType  string      `json:"metric_type"`
+	Metric      string      `json:"metric"`
+	MetricName  string      `json:"metric_name"`
+	Labels      pkg.Labels  `json:"labels,omitempty"`
+	Value       float64     `json:"value"`
+	ActiveSince time.Time   `json:"active_since"`
+}
+
+const (
+	DeleteReasonRequest   = "request"
+	DeleteReasonRetention = "retention"
+)
+
+// DeleteEvent is the audit event of a delete, its metrics are filled in by the
+// repository along with the delete.
+type DeleteEvent struct {
 	TS        time.Time `json:"ts"`
+	Action    string    `json:"action"`
+	Reason    string    `json:"reason"`
 	Metrics   []string  `json:"metrics"`
-	IPAddress string    `json:"ip_address"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Principal string    `json:"principal,omitempty"`
 }
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type HistoryPoint struct {
+	TS    time.Time `json:"ts"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	Last  float64   `json:"last"`
+	Count int64     `json:"count"`
+}
+
+type History struct {
+	ID     string         `json:"id"`
+	MType  string         `json:"type"`
+	Labels pkg.Labels     `json:"labels,omitempty"`
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Step   string         `json:"step"`
+	Points []HistoryPoint `json:"points"`
+}
@@ -1,8 +1,11 @@
 package models
 
+import "github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+
 const (
-	Counter = "counter"
-	Gauge   = "gauge"
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
 )
 
 // NOTE: Не усложняем пример, вводя иерархическую вложенность структур.
@@ -11,11 +14,16 @@ const (
 // что бы отличать значение "0", от не заданного значения
 // и соответственно не кодировать в структуру.
 type Metric struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
-	Hash  string   `json:"hash,omitempty"`
+	ID        string             `json:"id"`
+	MType     string             `json:"type"`
+	Labels    pkg.Labels         `json:"labels,omitempty"`
+	Op        string             `json:"op,omitempty"`
+	Version   *int64             `json:"version,omitempty"`
+	Delta     *int64             `json:"delta,omitempty"`
+	Value     *float64           `json:"value,omitempty"`
+	Histogram *pkg.Histogram     `json:"histogram,omitempty"`
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+	Hash      string             `json:"hash,omitempty"`
 }
 
 type Request struct {
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+// Receiver formats.
+const (
+	SlackFormat    = "slack"
+	TelegramFormat = "telegram"
+	JSONFormat     = "json"
+)
+
+// Receiver is a named webhook the alert notifications are sent to.
+type Receiver struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	// Template overrides the body of the format, it is a Go text/template.
+	Template string `json:"template"`
+	// ChatID is the telegram chat the notifications are sent to.
+	ChatID string `json:"chat_id"`
+	// Match routes only the alerts with these labels to the receiver.
+	Match          pkg.Labels `json:"match"`
+	GroupBy        []string   `json:"group_by"`
+	RepeatInterval string     `json:"repeat_interval"`
+}
+
+// Notification is a rendered body ready to be delivered to the receiver.
+type Notification struct {
+	Receiver string `json:"receiver"`
+	Body     string `json:"body"`
+}
+
+type Silence struct {
+	ID         string     `json:"id"`
+	Rule       string     `json:"rule,omitempty"`
+	MetricName string     `json:"metric_name,omitempty"`
+	Matchers   pkg.Labels `json:"matchers,omitempty"`
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     time.Time  `json:"ends_at"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Mutes reports whether the silence is active at ts and matches the alert.
+func (s Silence) Mutes(event AlertEvent, ts time.Time) bool {
+	if ts.Before(s.StartsAt) || !ts.Before(s.EndsAt) {
+		return false
+	}
+	if s.Rule != "" && s.Rule != event.Rule {
+		return false
+	}
+	if s.MetricName != "" && s.MetricName != event.MetricName {
+		return false
+	}
+	return event.Labels.Match(s.Matchers)
+}
@@ -0,0 +1,11 @@
+package models
+
+type RejectedSeries struct {
+	Series string `json:"series"`
+	Reason string `json:"reason"`
+}
+
+type RemoteWriteReport struct {
+	Accepted int              `json:"accepted"`
+	Rejected []RejectedSeries `json:"rejected"`
+}
@@ -0,0 +1,136 @@
+package file
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	errNotObject  = errors.New("audit event is not a json object")
+	errUnchained  = errors.New("line is not chained")
+	errBrokenSeq  = errors.New("sequence is broken")
+	errBrokenHash = errors.New("previous hash does not match")
+	errNoHMAC     = errors.New("hmac is missing")
+	errBadHMAC    = errors.New("hmac does not match")
+)
+
+// hmacField is always the last field of the line, the hmac signs the line without it.
+const hmacField = `,"hmac":"`
+
+// link is the chain fields appended to every audit event.
+type link struct {
+	Seq      uint64 `json:"seq"`
+	PrevHash string `json:"prev_hash"`
+	HMAC     string `json:"hmac,omitempty"`
+}
+
+// chain links every line to the previous one by its hash and signs it with the key.
+type chain struct {
+	key  []byte
+	seq  uint64
+	prev string
+}
+
+// seal returns the line of the payload and the chain advanced past it.
+func (c chain) seal(payload []byte) ([]byte, chain, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) < 2 || payload[0] != '{' || payload[len(payload)-1] != '}' {
+		return nil, c, errNotObject
+	}
+
+	body := payload[:len(payload)-1]
+	line := make([]byte, 0, len(payload)+192)
+	line = append(line, body...)
+	if len(bytes.TrimSpace(body[1:])) > 0 {
+		line = append(line, ',')
+	}
+	line = fmt.Appendf(line, `"seq":%d,"prev_hash":%q`, c.seq+1, c.prev)
+
+	if len(c.key) > 0 {
+		mac := sign(c.key, append(line, '}'))
+		line = append(line, hmacField...)
+		line = append(line, mac...)
+		line = append(line, '"')
+	}
+	line = append(line, '}')
+
+	return line, chain{key: c.key, seq: c.seq + 1, prev: hash(line)}, nil
+}
+
+// resume continues the chain after the last written line.
+func (c chain) resume(last []byte) chain {
+	var l link
+	if err := json.Unmarshal(last, &l); err != nil || l.Seq == 0 {
+		return chain{key: c.key}
+	}
+	return chain{key: c.key, seq: l.Seq, prev: hash(last)}
+}
+
+// verifier checks the lines of the audit log follow each other.
+type verifier struct {
+	key     []byte
+	started bool
+	seq     uint64
+	prev    string
+
+	// skipped is the number of the unchained lines before the chain starts.
+	skipped int
+}
+
+// next verifies the line is the next link of the chain. The chain may start
+// at any line since the older files are removed, the leading unchained lines
+// written before the chaining was enabled are skipped.
+func (v *verifier) next(line []byte) error {
+	var l link
+	if err := json.Unmarshal(line, &l); err != nil {
+		return fmt.Errorf("invalid line: %w", err)
+	}
+	if l.Seq == 0 {
+		if v.started {
+			return errUnchained
+		}
+		v.skipped++
+		return nil
+	}
+
+	if v.started {
+		if l.Seq != v.seq+1 {
+			return fmt.Errorf("%w: want %d, got %d", errBrokenSeq, v.seq+1, l.Seq)
+		}
+		if l.PrevHash != v.prev {
+			return errBrokenHash
+		}
+	} else if l.Seq == 1 && l.PrevHash != "" {
+		return errBrokenHash
+	}
+
+	if len(v.key) > 0 {
+		i := bytes.LastIndex(line, []byte(hmacField))
+		if i < 0 || l.HMAC == "" {
+			return errNoHMAC
+		}
+		if !hmac.Equal([]byte(sign(v.key, append(line[:i:i], '}'))), []byte(l.HMAC)) {
+			return errBadHMAC
+		}
+	}
+
+	v.started, v.seq, v.prev = true, l.Seq, hash(line)
+	return nil
+}
+
+func hash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+func sign(key, message []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(message)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -3,50 +3,235 @@ package file
 import (
 	"context"
 	"errors"
+	"log"
 	"os"
 	"sync"
+	"time"
 )
 
 var errNoFileOpen = errors.New("error no file open")
 
+// Config of the hash-chained audit log.
+type Config struct {
+	Path string
+	// Key signs every line with the hmac, the lines are only chained when it is empty.
+	Key string
+	// MaxSize rotates the file before it grows over the size in bytes, zero disables the rotation by size.
+	MaxSize int64
+	// RotateEvery rotates the file on the interval boundaries, zero disables the rotation by time.
+	RotateEvery time.Duration
+	// MaxFiles is the number of the rotated files kept, zero keeps all.
+	MaxFiles int
+	// MaxAge removes the rotated files older than it, zero keeps all.
+	MaxAge time.Duration
+}
+
+// Repository appends the audit events to the file, linking every line to
+// the previous one across the restarts and the rotations. The rotated files
+// are compressed and removed by the retention in the background.
 type Repository struct {
-	mx   *sync.Mutex
-	file *os.File
+	config Config
+
+	mx      sync.Mutex
+	file    *os.File
+	size    int64
+	since   time.Time
+	chain   chain
+	resumed bool
+	closed  bool
+
+	housekeep chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func New(fname string) *Repository {
-	file, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+// New opens the file, when it fails the file is opened again on the next append.
+func New(config Config) *Repository {
+	r := &Repository{
+		config:    config,
+		chain:     chain{key: []byte(config.Key)},
+		housekeep: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if err := r.open(); err != nil {
+		log.Printf("audit file not ok {path=%v, err=%v}\n", config.Path, err.Error())
+	}
+
+	go r.housekeeping()
+	r.triggerHousekeeping()
+
+	return r
+}
+
+// open opens the file and resumes the chain after its last line or the last
+// line of the latest rotated file when it is empty.
+func (r *Repository) open() error {
+	last, size, err := lastLine(r.config.Path)
 	if err != nil {
-		return &Repository{}
+		return err
 	}
 
-	return &Repository{
-		mx:   &sync.Mutex{},
-		file: file,
+	file, err := openFile(r.config.Path)
+	if err != nil {
+		return err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if fi.Size() > size {
+		// drops the torn tail of the interrupted write
+		if err := file.Truncate(size); err != nil {
+			file.Close()
+			return err
+		}
 	}
+
+	if !r.resumed {
+		if last == nil {
+			if files, err := Files(r.config.Path); err == nil && len(files) > 1 {
+				last, _, _ = lastLine(files[len(files)-2])
+			}
+		}
+		if last != nil {
+			r.chain = r.chain.resume(last)
+		}
+		r.resumed = true
+	}
+
+	r.file, r.size, r.since = file, size, time.Now()
+	if size > 0 {
+		r.since = fi.ModTime()
+	}
+
+	return nil
 }
 
-func (r *Repository) FileAppend(ctx context.Context, line []byte) error {
-	if r.file == nil {
+func (r *Repository) FileAppend(ctx context.Context, payload []byte) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.closed {
 		return errNoFileOpen
 	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return errors.Join(errNoFileOpen, err)
+		}
+	}
+
+	line, next, err := r.chain.seal(payload)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if now := time.Now(); r.size > 0 && (r.oversized(len(line)) || r.expired(now)) {
+		if err := r.rotate(now); err != nil {
+			return err
+		}
+	}
 
+	// the line is synced before the outbox item is committed, the torn one
+	// is truncated for the item to be written again
+	n, err := r.file.Write(line)
+	if err == nil {
+		err = r.file.Sync()
+	}
+	if err != nil {
+		if n > 0 {
+			_ = r.file.Truncate(r.size)
+		}
+		return err
+	}
+
+	r.size += int64(n)
+	r.chain = next
+
+	return nil
+}
+
+func (r *Repository) oversized(n int) bool {
+	return r.config.MaxSize > 0 && r.size+int64(n) > r.config.MaxSize
+}
+
+// expired reports the file was started before the current interval.
+func (r *Repository) expired(now time.Time) bool {
+	return r.config.RotateEvery > 0 && r.since.Before(now.Truncate(r.config.RotateEvery))
+}
+
+// rotate renames the file by the rotation time and starts the new one,
+// the chain continues in the new file.
+func (r *Repository) rotate(now time.Time) error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	if err := os.Rename(r.config.Path, rotatedName(r.config.Path, now)); err != nil {
+		return err
+	}
+
+	file, err := openFile(r.config.Path)
+	if err != nil {
+		return err
+	}
+
+	r.file, r.size, r.since = file, 0, now
+	r.triggerHousekeeping()
+
+	return nil
+}
+
+// FileReopen closes the file and opens it by the path again after it was
+// moved by the external rotation, the chain continues in the new file.
+func (r *Repository) FileReopen(ctx context.Context) error {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 
-	line = append(line, '\n')
-	_, err := r.file.Write(line)
-	return err
+	if r.closed {
+		return errNoFileOpen
+	}
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			log.Printf("audit file close not ok {path=%v, err=%v}\n", r.config.Path, err.Error())
+		}
+		r.file = nil
+	}
+
+	return r.open()
 }
 
 func (r *Repository) FileClose(ctx context.Context) error {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.closed = true
 	if r.file == nil {
 		return nil
 	}
 
-	if err := r.file.Sync(); err != nil {
+	file := r.file
+	r.file = nil
+
+	if err := file.Sync(); err != nil {
+		file.Close()
 		return err
 	}
 
-	return r.file.Close()
+	return file.Close()
+}
+
+func openFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
 }
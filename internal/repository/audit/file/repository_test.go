@@ -0,0 +1,187 @@
+package file_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/file"
+)
+
+const testKey = "secret"
+
+func appendEvents(t *testing.T, config file.Config, from, to int) {
+	t.Helper()
+
+	r := file.New(config)
+	for i := from; i < to; i++ {
+		require.NoError(t, r.FileAppend(context.Background(), fmt.Appendf(nil, `{"n":%d}`, i)))
+	}
+	require.NoError(t, r.FileClose(context.Background()))
+}
+
+func TestRepository_Chain(t *testing.T) {
+	config := file.Config{Path: filepath.Join(t.TempDir(), "audit.log"), Key: testKey, MaxSize: 256}
+
+	appendEvents(t, config, 0, 10)
+	appendEvents(t, config, 10, 20)
+
+	files, err := file.Files(config.Path)
+	require.NoError(t, err)
+	require.Greater(t, len(files), 2)
+	assert.Equal(t, config.Path, files[len(files)-1])
+
+	for _, name := range files {
+		fi, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, fi.Size(), config.MaxSize, name)
+	}
+
+	report, err := file.Verify(files, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, file.Report{Lines: 20, FirstSeq: 1, LastSeq: 20}, report)
+
+	_, err = file.Verify(files, "other")
+	assert.Error(t, err)
+
+	var broken *file.BrokenLinkError
+	_, err = file.Verify(files[1:], testKey)
+	assert.NoError(t, err)
+	_, err = file.Verify([]string{files[0], files[2]}, testKey)
+	require.ErrorAs(t, err, &broken)
+	assert.Equal(t, files[2], broken.File)
+	assert.Equal(t, files[0], broken.Prev)
+}
+
+func TestRepository_Tampered(t *testing.T) {
+	config := file.Config{Path: filepath.Join(t.TempDir(), "audit.log"), Key: testKey}
+	appendEvents(t, config, 0, 5)
+
+	data, err := os.ReadFile(config.Path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+		line int
+	}{
+		{
+			name: "edited",
+			data: bytes.Replace(data, []byte(`{"n":2,`), []byte(`{"n":7,`), 1),
+			line: 3,
+		},
+		{
+			name: "removed",
+			data: bytes.Replace(data, data[bytes.IndexByte(data, '\n')+1:bytes.Index(data, []byte(`{"n":2,`))], nil, 1),
+			line: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(config.Path, tt.data, 0o644))
+
+			var broken *file.BrokenLinkError
+			_, err := file.Verify([]string{config.Path}, testKey)
+			require.ErrorAs(t, err, &broken)
+			assert.Equal(t, tt.line, broken.Line)
+		})
+	}
+}
+
+func TestRepository_Resume(t *testing.T) {
+	config := file.Config{Path: filepath.Join(t.TempDir(), "audit.log")}
+	require.NoError(t, os.WriteFile(config.Path, []byte("{\"legacy\":1}\n{\"legacy\":2}\n"), 0o644))
+
+	appendEvents(t, config, 0, 2)
+
+	f, err := os.OpenFile(config.Path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"n":2,"se`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	appendEvents(t, config, 2, 4)
+
+	report, err := file.Verify([]string{config.Path}, "")
+	require.NoError(t, err)
+	assert.Equal(t, file.Report{Lines: 4, Skipped: 2, FirstSeq: 1, LastSeq: 4}, report)
+}
+
+func TestRepository_NotObject(t *testing.T) {
+	r := file.New(file.Config{Path: filepath.Join(t.TempDir(), "audit.log")})
+	defer r.FileClose(context.Background())
+
+	assert.Error(t, r.FileAppend(context.Background(), []byte(`[1,2]`)))
+}
+
+func TestRepository_Housekeeping(t *testing.T) {
+	config := file.Config{Path: filepath.Join(t.TempDir(), "audit.log"), Key: testKey, MaxSize: 128}
+	appendEvents(t, config, 0, 10)
+
+	config.MaxFiles = 3
+	r := file.New(config)
+	defer r.FileClose(context.Background())
+
+	assert.Eventually(t, func() bool {
+		files, err := file.Files(config.Path)
+		if err != nil || len(files) != config.MaxFiles+1 {
+			return false
+		}
+		for _, name := range files[:config.MaxFiles] {
+			if filepath.Ext(name) != ".gz" {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, r.FileAppend(context.Background(), []byte(`{"n":10}`)))
+	require.NoError(t, r.FileClose(context.Background()))
+
+	files, err := file.Files(config.Path)
+	require.NoError(t, err)
+
+	report, err := file.Verify(files, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(11), report.LastSeq)
+}
+
+func TestRepository_RotateEvery(t *testing.T) {
+	config := file.Config{Path: filepath.Join(t.TempDir(), "audit.log"), RotateEvery: 50 * time.Millisecond}
+
+	r := file.New(config)
+	defer r.FileClose(context.Background())
+
+	require.NoError(t, r.FileAppend(context.Background(), []byte(`{"n":0}`)))
+	time.Sleep(2 * config.RotateEvery)
+	require.NoError(t, r.FileAppend(context.Background(), []byte(`{"n":1}`)))
+
+	files, err := file.Files(config.Path)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestRepository_Reopen(t *testing.T) {
+	config := file.Config{Path: filepath.Join(t.TempDir(), "audit.log"), Key: testKey}
+
+	r := file.New(config)
+	defer r.FileClose(context.Background())
+
+	require.NoError(t, r.FileAppend(context.Background(), []byte(`{"n":0}`)))
+	require.NoError(t, os.Rename(config.Path, config.Path+".1"))
+	require.NoError(t, r.FileAppend(context.Background(), []byte(`{"n":1}`)))
+	require.NoError(t, r.FileReopen(context.Background()))
+	require.NoError(t, r.FileAppend(context.Background(), []byte(`{"n":2}`)))
+
+	report, err := file.Verify([]string{config.Path + ".1", config.Path}, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, file.Report{Lines: 3, FirstSeq: 1, LastSeq: 3}, report)
+}
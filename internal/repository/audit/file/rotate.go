@@ -0,0 +1,291 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// rotatedLayout is the suffix of the rotated files, their names sort by the rotation time.
+	rotatedLayout = "20060102T150405.000000000"
+	gzipExt       = ".gz"
+)
+
+func rotatedName(path string, now time.Time) string {
+	return path + "." + now.UTC().Format(rotatedLayout)
+}
+
+// rotatedTime returns the rotation time of the rotated file, false when the name is not one.
+func rotatedTime(path, name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, path+".")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	ts, err := time.Parse(rotatedLayout, strings.TrimSuffix(suffix, gzipExt))
+	return ts, err == nil
+}
+
+// rotated returns the rotated files from the oldest one, the file which is
+// still being compressed is returned instead of its archive.
+func rotated(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(matches))
+	for _, name := range matches {
+		if _, ok := rotatedTime(path, name); !ok {
+			continue
+		}
+		if archive, ok := strings.CutSuffix(name, gzipExt); ok && slices.Contains(matches, archive) {
+			continue
+		}
+		files = append(files, name)
+	}
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// Files returns the rotated files of the audit log from the oldest one and
+// the current file last when it exists.
+func Files(path string) ([]string, error) {
+	files, err := rotated(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+func (r *Repository) triggerHousekeeping() {
+	select {
+	case r.housekeep <- struct{}{}:
+	default:
+	}
+}
+
+// housekeeping compresses the rotated files and applies the retention after every rotation.
+func (r *Repository) housekeeping() {
+	defer close(r.done)
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-r.housekeep:
+			if err := r.compress(); err != nil {
+				log.Printf("audit file compress not ok {path=%v, err=%v}\n", r.config.Path, err.Error())
+			}
+			if err := r.prune(time.Now()); err != nil {
+				log.Printf("audit file retention not ok {path=%v, err=%v}\n", r.config.Path, err.Error())
+			}
+		}
+	}
+}
+
+func (r *Repository) compress() error {
+	files, err := rotated(r.config.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		if strings.HasSuffix(name, gzipExt) {
+			continue
+		}
+
+		select {
+		case <-r.stop:
+			return nil
+		default:
+		}
+
+		if err := gzipFile(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gzipFile replaces the file by its archive, the archive is complete once it has the name.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + gzipExt + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, name+gzipExt); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// prune removes the rotated files older than MaxAge and the oldest ones over MaxFiles.
+func (r *Repository) prune(now time.Time) error {
+	files, err := rotated(r.config.Path)
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	if r.config.MaxAge > 0 {
+		for len(files) > 0 {
+			ts, _ := rotatedTime(r.config.Path, files[0])
+			if !ts.Before(now.Add(-r.config.MaxAge)) {
+				break
+			}
+			remove, files = append(remove, files[0]), files[1:]
+		}
+	}
+	if r.config.MaxFiles > 0 && len(files) > r.config.MaxFiles {
+		remove = append(remove, files[:len(files)-r.config.MaxFiles]...)
+	}
+
+	var errs []error
+	for _, name := range remove {
+		errs = append(errs, os.Remove(name))
+	}
+
+	return errors.Join(errs...)
+}
+
+// openReader reads the file or the archive.
+func openReader(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, gzipExt) {
+		return file, nil
+	}
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, file}, nil
+}
+
+// lastLine returns the last complete line of the file and the size of the
+// file up to its end, nil line when the file is missing or has no lines.
+func lastLine(path string) ([]byte, int64, error) {
+	if strings.HasSuffix(path, gzipExt) {
+		return lastArchivedLine(path)
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// reads the tail backwards by the growing chunks until it has two line ends
+	var (
+		size  = fi.Size()
+		chunk = int64(64 << 10)
+		tail  []byte
+	)
+	for {
+		from := max(size-chunk, 0)
+		tail = make([]byte, size-from)
+		if _, err := file.ReadAt(tail, from); err != nil && !errors.Is(err, io.EOF) {
+			return nil, 0, err
+		}
+
+		end := bytes.LastIndexByte(tail, '\n')
+		if end < 0 && from > 0 {
+			chunk *= 2
+			continue
+		}
+		if end < 0 {
+			return nil, 0, nil
+		}
+
+		start := bytes.LastIndexByte(tail[:end], '\n')
+		if start < 0 && from > 0 {
+			chunk *= 2
+			continue
+		}
+
+		return tail[start+1 : end], from + int64(end) + 1, nil
+	}
+}
+
+// lastArchivedLine reads the archive through since it can not be read backwards.
+func lastArchivedLine(path string) ([]byte, int64, error) {
+	reader, err := openReader(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	var (
+		last []byte
+		size int64
+		br   = bufio.NewReader(reader)
+	)
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return last, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		last, size = bytes.TrimSuffix(line, []byte("\n")), size+int64(len(line))
+	}
+}
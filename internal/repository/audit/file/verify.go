@@ -0,0 +1,98 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Report is the result of the audit log verification.
+type Report struct {
+	// Lines is the number of the verified lines.
+	Lines int
+	// Skipped is the number of the unchained lines before the chain starts.
+	Skipped int
+	// FirstSeq and LastSeq are the sequence numbers of the verified chain.
+	FirstSeq, LastSeq uint64
+}
+
+// BrokenLinkError is the first line that does not follow the previous one.
+type BrokenLinkError struct {
+	File string
+	Line int
+	// Prev is the previous file when the link between the files is broken.
+	Prev string
+	Err  error
+}
+
+func (e *BrokenLinkError) Error() string {
+	if e.Prev != "" {
+		return fmt.Sprintf("%s:%d: %v, continuity with %s is broken", e.File, e.Line, e.Err, e.Prev)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *BrokenLinkError) Unwrap() error {
+	return e.Err
+}
+
+// Verify walks the files in the given order as one chain and stops at the first broken link.
+// The hmac of the lines is only checked when the key is set.
+func Verify(files []string, key string) (Report, error) {
+	var (
+		report Report
+		v      = &verifier{key: []byte(key)}
+	)
+
+	for i, name := range files {
+		prev := ""
+		if i > 0 {
+			prev = files[i-1]
+		}
+
+		if err := verifyFile(v, &report, name, prev); err != nil {
+			return report, err
+		}
+	}
+
+	report.Skipped, report.LastSeq = v.skipped, v.seq
+	return report, nil
+}
+
+func verifyFile(v *verifier, report *Report, name, prev string) error {
+	file, err := openReader(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		started := v.started
+		if err := v.next(line); err != nil {
+			broken := &BrokenLinkError{File: name, Line: n, Err: err}
+			if n == 1 {
+				broken.Prev = prev
+			}
+			return broken
+		}
+
+		if v.started {
+			if !started {
+				report.FirstSeq = v.seq
+			}
+			report.Lines++
+		}
+	}
+}
@@ -2,30 +2,199 @@ package remote
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+)
+
+// The formats of the request body.
+const (
+	// FormatJSON posts every event as a JSON object of its own.
+	FormatJSON = "json"
+	// FormatArray posts the events as a JSON array.
+	FormatArray = "array"
+	// FormatNDJSON posts the events as newline delimited JSON.
+	FormatNDJSON = "ndjson"
+)
+
+// SignatureHeader is the header with the base64 HMAC-SHA256 of the request body.
+const SignatureHeader = "HashSHA256"
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultConnectTimeout = 5 * time.Second
+	defaultBatchSize      = 100
+
+	retryDelay = time.Second
 )
 
+type Config struct {
+	URL    string
+	Format string
+	// BatchSize is the number of events posted per request in the array and ndjson formats.
+	BatchSize int
+	// Key signs the request bodies, they are not signed when it is empty.
+	Key            string
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	// MaxRetries is the number of the immediate retries of the retriable failures,
+	// the outbox redelivers the events later anyway.
+	MaxRetries uint16
+}
+
+// StatusError is a response other than 2xx.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to send, status_code=%d", e.StatusCode)
+}
+
+// ClassifyError tells the failures the endpoint may recover from apart from
+// the rejected requests, which fail the same way however often they are sent.
+// The rejected events are dead-lettered at once, so an endpoint misconfigured to
+// answer 401 or 403 dead-letters every event until it is fixed, the dead letters
+// are requeued afterwards. A 413 is only final for a single event, the batches
+// are split by RemoteSendBatch.
+func ClassifyError(err error) backoff.ErrorClassification {
+	if err == nil {
+		return backoff.NonRetriable
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode >= http.StatusInternalServerError,
+			statusErr.StatusCode == http.StatusRequestTimeout,
+			statusErr.StatusCode == http.StatusTooEarly,
+			statusErr.StatusCode == http.StatusTooManyRequests:
+			return backoff.Retriable
+		}
+		return backoff.NonRetriable
+	}
+
+	var urlError *url.Error
+	if errors.As(err, &urlError) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return backoff.Retriable
+	}
+
+	return backoff.NonRetriable
+}
+
 type Repository struct {
-	url    string
-	client *http.Client
+	config  Config
+	client  *http.Client
+	backoff *backoff.Backoff
 }
 
-func New(url string) *Repository {
-	return &Repository{
-		url:    url,
-		client: &http.Client{},
+func New(config Config) (*Repository, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("url not ok, %w", err)
 	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	config.Format = cmp.Or(config.Format, FormatJSON)
+	switch config.Format {
+	case FormatJSON:
+		config.BatchSize = 1
+	case FormatArray, FormatNDJSON:
+		if config.BatchSize < 0 {
+			return nil, errors.New("batch size is negative")
+		}
+		config.BatchSize = cmp.Or(config.BatchSize, defaultBatchSize)
+	default:
+		return nil, fmt.Errorf("unknown format %q", config.Format)
+	}
+	config.Timeout = cmp.Or(config.Timeout, defaultTimeout)
+	config.ConnectTimeout = cmp.Or(config.ConnectTimeout, defaultConnectTimeout)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: config.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+
+	return &Repository{
+		config: config,
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: transport,
+		},
+		backoff: backoff.NewBackoff(config.MaxRetries, ClassifyError),
+	}, nil
 }
 
 func (r *Repository) RemoteSend(ctx context.Context, body []byte) error {
-	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewBuffer(body))
+	return r.RemoteSendBatch(ctx, [][]byte{body})[0]
+}
+
+// RemoteSendBatch posts the events by BatchSize per request and returns the
+// error of every event, nil for the delivered ones.
+func (r *Repository) RemoteSendBatch(ctx context.Context, payloads [][]byte) []error {
+	errs := make([]error, len(payloads))
+	for from := 0; from < len(payloads); from += r.config.BatchSize {
+		to := min(from+r.config.BatchSize, len(payloads))
+		r.send(ctx, payloads[from:to], errs[from:to])
+	}
+	return errs
+}
+
+// send posts the events in one request and sets their errors. The batch the
+// endpoint rejects as too large is split in halves down to the single events,
+// so that one large event does not fail the others.
+func (r *Repository) send(ctx context.Context, payloads [][]byte, errs []error) {
+	contentType, body := r.encode(payloads)
+	err := r.backoff.WithLinear(retryDelay, retryDelay)(func(ctx context.Context) error {
+		return r.post(ctx, contentType, body)
+	})(ctx)
+
+	var statusErr *StatusError
+	if len(payloads) > 1 && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestEntityTooLarge {
+		half := len(payloads) / 2
+		r.send(ctx, payloads[:half], errs[:half])
+		r.send(ctx, payloads[half:], errs[half:])
+		return
+	}
+
+	for i := range errs {
+		errs[i] = err
+	}
+}
+
+func (r *Repository) encode(payloads [][]byte) (contentType string, body []byte) {
+	switch r.config.Format {
+	case FormatArray:
+		body = append([]byte{'['}, bytes.Join(payloads, []byte(","))...)
+		return "application/json", append(body, ']')
+	case FormatNDJSON:
+		return "application/x-ndjson", append(bytes.Join(payloads, []byte("\n")), '\n')
+	default:
+		return "application/json", payloads[0]
+	}
+}
+
+func (r *Repository) post(ctx context.Context, contentType string, body []byte) error {
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.URL, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("request not ok, %w", err)
 	}
 
-	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Content-Type", contentType)
+	if r.config.Key != "" {
+		rq.Header.Set(SignatureHeader, sign(r.config.Key, body))
+	}
 
 	resp, err := r.client.Do(rq)
 	if err != nil {
@@ -33,9 +202,17 @@ func (r *Repository) RemoteSend(ctx context.Context, body []byte) error {
 	}
 
 	defer resp.Body.Close()
+	// the rest of the body is read out, so that the connection is reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to send, status_code=%d", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 	return nil
 }
+
+func sign(key string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(body)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+)
+
+// syslogPriority is the local0 facility with the informational severity.
+const syslogPriority = 16*8 + 6
+
+// connWriter sends the events over a socket, it dials on the first write
+// and again after a failed one.
+type connWriter struct {
+	network string
+	address string
+	frame   func(payload []byte) []byte
+
+	mx   sync.Mutex
+	conn net.Conn
+}
+
+// newSyslogWriter sends the events as RFC 5424 messages, framed by the octet
+// counting of RFC 6587 over tcp.
+func newSyslogWriter(sink models.AuditSink) (writer, error) {
+	network := cmp.Or(sink.Network, "udp")
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unknown syslog network %q", network)
+	}
+
+	hostname, _ := os.Hostname()
+	hostname = cmp.Or(hostname, "-")
+	tag := cmp.Or(sink.Tag, "metrics-server")
+	pid := strconv.Itoa(os.Getpid())
+
+	return &connWriter{
+		network: network,
+		address: sink.Address,
+		frame: func(payload []byte) []byte {
+			msg := fmt.Appendf(nil, "<%d>1 %s %s %s %s - - %s",
+				syslogPriority, time.Now().UTC().Format(time.RFC3339Nano), hostname, tag, pid, payload,
+			)
+			if network == "tcp" {
+				return fmt.Appendf(nil, "%d %s", len(msg), msg)
+			}
+			return msg
+		},
+	}, nil
+}
+
+// newUnixWriter sends the events as lines over a stream socket or as datagrams.
+func newUnixWriter(sink models.AuditSink) (writer, error) {
+	network := cmp.Or(sink.Network, "unix")
+	if network != "unix" && network != "unixgram" {
+		return nil, fmt.Errorf("unknown unix network %q", network)
+	}
+
+	return &connWriter{
+		network: network,
+		address: sink.Path,
+		frame: func(payload []byte) []byte {
+			if network == "unix" {
+				return append(slices.Clip(payload), '\n')
+			}
+			return payload
+		},
+	}, nil
+}
+
+func (w *connWriter) Write(ctx context.Context, payload []byte) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, w.network, w.address)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := w.conn.SetWriteDeadline(deadline); err != nil {
+		return w.reset(err)
+	}
+	if _, err := w.conn.Write(w.frame(payload)); err != nil {
+		return w.reset(err)
+	}
+	return nil
+}
+
+func (w *connWriter) reset(err error) error {
+	w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *connWriter) Close() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,267 @@
+package sink
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/file"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/remote"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+)
+
+type writer interface {
+	Write(ctx context.Context, payload []byte) error
+	Close() error
+}
+
+// batchWriter is the writer which delivers several events at once.
+type batchWriter interface {
+	WriteBatch(ctx context.Context, payloads [][]byte) []error
+}
+
+// classifier is the writer which tells the permanent failures apart.
+type classifier interface {
+	Classify(err error) backoff.ErrorClassification
+}
+
+// reopener is the writer of the file which may be moved by the external rotation.
+type reopener interface {
+	Reopen(ctx context.Context) error
+}
+
+// Repository delivers the audit events to the named sinks.
+type Repository struct {
+	sinks map[string]writer
+}
+
+// New opens the sinks, the key signs the lines of the file sinks.
+func New(sinks []models.AuditSink, key string) *Repository {
+	writers := make(map[string]writer, len(sinks))
+	for _, sink := range sinks {
+		w, err := newWriter(sink, key)
+		if err != nil {
+			log.Printf("audit sink not ok {name=%v, err=%v}\n", sink.Name, err.Error())
+			continue
+		}
+		writers[sink.Name] = w
+	}
+
+	return &Repository{
+		sinks: writers,
+	}
+}
+
+func newWriter(sink models.AuditSink, key string) (writer, error) {
+	switch sink.Type {
+	case models.FileSinkType:
+		if sink.Path == "" {
+			return nil, errors.New("file path is not set")
+		}
+		return newFileWriter(sink, key)
+	case models.HTTPSinkType:
+		if sink.URL == "" {
+			return nil, errors.New("url is not set")
+		}
+		return newRemoteWriter(sink, key)
+	case models.SyslogSinkType:
+		if sink.Address == "" {
+			return nil, errors.New("address is not set")
+		}
+		return newSyslogWriter(sink)
+	case models.UnixSinkType:
+		if sink.Path == "" {
+			return nil, errors.New("socket path is not set")
+		}
+		return newUnixWriter(sink)
+	case models.StdoutSinkType:
+		return &streamWriter{w: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", sink.Type)
+	}
+}
+
+// SinkOpened reports whether the writer of the sink was created.
+func (r *Repository) SinkOpened(sink string) bool {
+	_, ok := r.sinks[sink]
+	return ok
+}
+
+func (r *Repository) SinkWrite(ctx context.Context, sink string, payload []byte) error {
+	w, ok := r.sinks[sink]
+	if !ok {
+		return fmt.Errorf("unknown sink %q", sink)
+	}
+
+	return w.Write(ctx, payload)
+}
+
+// SinkWriteBatch writes the events to the sink and returns the error of every
+// event, nil for the delivered ones.
+func (r *Repository) SinkWriteBatch(ctx context.Context, sink string, payloads [][]byte) []error {
+	w, ok := r.sinks[sink]
+	if !ok {
+		errs := make([]error, len(payloads))
+		for i := range errs {
+			errs[i] = fmt.Errorf("unknown sink %q", sink)
+		}
+		return errs
+	}
+
+	if w, ok := w.(batchWriter); ok {
+		return w.WriteBatch(ctx, payloads)
+	}
+
+	errs := make([]error, len(payloads))
+	for i, payload := range payloads {
+		errs[i] = w.Write(ctx, payload)
+	}
+	return errs
+}
+
+// SinkClassify reports whether the failure of the sink may pass on redelivery,
+// the failures of the sinks which cannot tell are retriable. Nothing passes
+// for the sink which was not opened.
+func (r *Repository) SinkClassify(sink string, err error) backoff.ErrorClassification {
+	w, ok := r.sinks[sink]
+	if !ok {
+		return backoff.NonRetriable
+	}
+	if w, ok := w.(classifier); ok {
+		return w.Classify(err)
+	}
+	return backoff.Retriable
+}
+
+// SinkReopen reopens the files of the file sinks after the external rotation.
+func (r *Repository) SinkReopen(ctx context.Context) error {
+	var errs []error
+	for name, w := range r.sinks {
+		if w, ok := w.(reopener); ok {
+			if err := w.Reopen(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("sink %q: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Repository) SinkClose(ctx context.Context) error {
+	var errs []error
+	for _, w := range r.sinks {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type fileWriter struct {
+	repo *file.Repository
+}
+
+func newFileWriter(sink models.AuditSink, key string) (writer, error) {
+	config := file.Config{Path: sink.Path, Key: key, MaxSize: sink.MaxSize, MaxFiles: sink.MaxFiles}
+	if err := parseDuration(sink.RotateEvery, &config.RotateEvery); err != nil {
+		return nil, err
+	}
+	if err := parseDuration(sink.MaxAge, &config.MaxAge); err != nil {
+		return nil, err
+	}
+
+	return fileWriter{file.New(config)}, nil
+}
+
+// parseDuration sets the positive duration, the empty value keeps the default.
+func parseDuration(value string, dst *time.Duration) error {
+	if value == "" {
+		return nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*dst = duration
+	return nil
+}
+
+func (w fileWriter) Write(ctx context.Context, payload []byte) error {
+	return w.repo.FileAppend(ctx, payload)
+}
+
+func (w fileWriter) Reopen(ctx context.Context) error {
+	return w.repo.FileReopen(ctx)
+}
+
+func (w fileWriter) Close() error {
+	return w.repo.FileClose(context.TODO())
+}
+
+type remoteWriter struct {
+	repo *remote.Repository
+}
+
+// newRemoteWriter posts the events of a run in one request unless RequestSize is set,
+// the requests are signed by the key of the sink or else by the server key.
+func newRemoteWriter(sink models.AuditSink, key string) (writer, error) {
+	config := remote.Config{
+		URL:        sink.URL,
+		Format:     sink.Format,
+		BatchSize:  cmp.Or(sink.RequestSize, sink.BatchSize),
+		Key:        cmp.Or(sink.Key, key),
+		MaxRetries: sink.MaxRetries,
+	}
+	if err := parseDuration(sink.Timeout, &config.Timeout); err != nil {
+		return nil, err
+	}
+	if err := parseDuration(sink.ConnectTimeout, &config.ConnectTimeout); err != nil {
+		return nil, err
+	}
+
+	repo, err := remote.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return remoteWriter{repo}, nil
+}
+
+func (w remoteWriter) Write(ctx context.Context, payload []byte) error {
+	return w.repo.RemoteSend(ctx, payload)
+}
+
+func (w remoteWriter) WriteBatch(ctx context.Context, payloads [][]byte) []error {
+	return w.repo.RemoteSendBatch(ctx, payloads)
+}
+
+func (w remoteWriter) Classify(err error) backoff.ErrorClassification {
+	return remote.ClassifyError(err)
+}
+
+func (w remoteWriter) Close() error {
+	return nil
+}
+
+// streamWriter writes the events as lines.
+type streamWriter struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+func (w *streamWriter) Write(ctx context.Context, payload []byte) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	_, err := w.w.Write(append(slices.Clip(payload), '\n'))
+	return err
+}
+
+func (w *streamWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,271 @@
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/audit/sink"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+)
+
+func TestRepository_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	repo := sink.New([]models.AuditSink{{Name: "file", Type: models.FileSinkType, Path: path}}, "")
+
+	require.NoError(t, repo.SinkWrite(context.Background(), "file", []byte(`{"n":1}`)))
+	require.NoError(t, repo.SinkWrite(context.Background(), "file", []byte(`{"n":2}`)))
+	require.NoError(t, repo.SinkClose(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"n\":1,\"seq\":1,\"prev_hash\":\"\"}\n"+
+		"{\"n\":2,\"seq\":2,\"prev_hash\":\"69cd7975f5409fba9f1b0a978a28e59d1f51444083138726effc6e4171c222f0\"}\n", string(data))
+}
+
+func TestRepository_Syslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	repo := sink.New([]models.AuditSink{
+		{Name: "siem", Type: models.SyslogSinkType, Address: conn.LocalAddr().String(), Tag: "test"},
+	}, "")
+	defer repo.SinkClose(context.Background())
+
+	require.NoError(t, repo.SinkWrite(context.Background(), "siem", []byte(`{"n":1}`)))
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<134>1 "), msg)
+	assert.Contains(t, msg, " test ")
+	assert.True(t, strings.HasSuffix(msg, ` - - {"n":1}`), msg)
+}
+
+func TestRepository_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	repo := sink.New([]models.AuditSink{{Name: "collector", Type: models.UnixSinkType, Path: path}}, "")
+	defer repo.SinkClose(context.Background())
+
+	require.NoError(t, repo.SinkWrite(context.Background(), "collector", []byte(`{"n":1}`)))
+	require.NoError(t, repo.SinkWrite(context.Background(), "collector", []byte(`{"n":2}`)))
+
+	for _, want := range []string{`{"n":1}`, `{"n":2}`} {
+		select {
+		case line := <-lines:
+			assert.Equal(t, want, line)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no line received")
+		}
+	}
+}
+
+func TestRepository_HTTP(t *testing.T) {
+	type request struct {
+		contentType string
+		signature   string
+		body        string
+	}
+
+	const key = "secret"
+
+	var (
+		mx       sync.Mutex
+		requests []request
+		status   = http.StatusAccepted
+		// maxBody rejects the larger request bodies as too large, 0 accepts any
+		maxBody int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mx.Lock()
+		defer mx.Unlock()
+		requests = append(requests, request{r.Header.Get("Content-Type"), r.Header.Get("HashSHA256"), string(body)})
+		if maxBody > 0 && len(body) > maxBody {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	repo := sink.New([]models.AuditSink{
+		{Name: "json", Type: models.HTTPSinkType, URL: server.URL},
+		{Name: "array", Type: models.HTTPSinkType, URL: server.URL, Format: "array", RequestSize: 2, Key: "other"},
+		{Name: "ndjson", Type: models.HTTPSinkType, URL: server.URL, Format: "ndjson"},
+	}, key)
+	defer repo.SinkClose(context.Background())
+
+	payloads := [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)}
+	sign := func(secret, body string) string {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(body))
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+
+	tests := []struct {
+		name string
+		want []request
+	}{
+		{
+			name: "json",
+			want: []request{
+				{"application/json", sign(key, `{"n":1}`), `{"n":1}`},
+				{"application/json", sign(key, `{"n":2}`), `{"n":2}`},
+				{"application/json", sign(key, `{"n":3}`), `{"n":3}`},
+			},
+		},
+		{
+			name: "array",
+			want: []request{
+				{"application/json", sign("other", `[{"n":1},{"n":2}]`), `[{"n":1},{"n":2}]`},
+				{"application/json", sign("other", `[{"n":3}]`), `[{"n":3}]`},
+			},
+		},
+		{
+			name: "ndjson",
+			want: []request{
+				{"application/x-ndjson", sign(key, "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"), "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mx.Lock()
+			requests = nil
+			mx.Unlock()
+
+			for _, err := range repo.SinkWriteBatch(context.Background(), tt.name, payloads) {
+				assert.NoError(t, err)
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			assert.Equal(t, tt.want, requests)
+		})
+	}
+
+	t.Run("too large", func(t *testing.T) {
+		mx.Lock()
+		requests, maxBody = nil, len("{\"n\":1}\n{\"n\":2}\n")
+		mx.Unlock()
+		defer func() {
+			mx.Lock()
+			maxBody = 0
+			mx.Unlock()
+		}()
+
+		large := append(payloads, []byte(`{"n":4,"large":true}`))
+		errs := repo.SinkWriteBatch(context.Background(), "ndjson", large)
+		require.Len(t, errs, 4)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.NoError(t, errs[2])
+		require.Error(t, errs[3])
+		assert.Equal(t, backoff.NonRetriable, repo.SinkClassify("ndjson", errs[3]))
+
+		mx.Lock()
+		defer mx.Unlock()
+		bodies := make([]string, 0, len(requests))
+		for _, rq := range requests {
+			bodies = append(bodies, rq.body)
+		}
+		assert.Equal(t, []string{
+			"{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n{\"n\":4,\"large\":true}\n",
+			"{\"n\":1}\n{\"n\":2}\n",
+			"{\"n\":3}\n{\"n\":4,\"large\":true}\n",
+			"{\"n\":3}\n",
+			"{\"n\":4,\"large\":true}\n",
+		}, bodies)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		for _, tt := range []struct {
+			status int
+			want   backoff.ErrorClassification
+		}{
+			{http.StatusBadRequest, backoff.NonRetriable},
+			{http.StatusMovedPermanently, backoff.NonRetriable},
+			{http.StatusTooManyRequests, backoff.Retriable},
+			{http.StatusServiceUnavailable, backoff.Retriable},
+		} {
+			mx.Lock()
+			status = tt.status
+			mx.Unlock()
+
+			errs := repo.SinkWriteBatch(context.Background(), "array", payloads)
+			require.Len(t, errs, 3)
+			for _, err := range errs {
+				require.Error(t, err)
+				assert.Equal(t, tt.want, repo.SinkClassify("array", err), tt.status)
+			}
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		unreachable := sink.New([]models.AuditSink{{Name: "http", Type: models.HTTPSinkType, URL: "http://127.0.0.1:1"}}, "")
+
+		err := unreachable.SinkWrite(context.Background(), "http", []byte(`{}`))
+		require.Error(t, err)
+		assert.Equal(t, backoff.Retriable, unreachable.SinkClassify("http", err))
+	})
+}
+
+func TestRepository_Invalid(t *testing.T) {
+	repo := sink.New([]models.AuditSink{
+		{Name: "nofile", Type: models.FileSinkType},
+		{Name: "unknown", Type: "kafka"},
+		{Name: "network", Type: models.SyslogSinkType, Address: "localhost:514", Network: "sctp"},
+		{Name: "rotate", Type: models.FileSinkType, Path: filepath.Join(t.TempDir(), "audit.log"), RotateEvery: "daily"},
+		{Name: "scheme", Type: models.HTTPSinkType, URL: "ftp://localhost/audit"},
+		{Name: "format", Type: models.HTTPSinkType, URL: "http://localhost/audit", Format: "xml"},
+		{Name: "timeout", Type: models.HTTPSinkType, URL: "http://localhost/audit", Timeout: "-1s"},
+	}, "")
+
+	for _, name := range []string{"nofile", "unknown", "network", "rotate", "scheme", "format", "timeout", "missing"} {
+		err := repo.SinkWrite(context.Background(), name, []byte(`{}`))
+		assert.Error(t, err, name)
+		assert.False(t, repo.SinkOpened(name), name)
+		assert.Equal(t, backoff.NonRetriable, repo.SinkClassify(name, err), name)
+	}
+}
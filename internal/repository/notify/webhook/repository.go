@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+)
+
+type Repository struct {
+	urls   map[string]string
+	client *http.Client
+}
+
+func New(receivers []models.Receiver) *Repository {
+	urls := make(map[string]string, len(receivers))
+	for _, receiver := range receivers {
+		urls[receiver.Name] = receiver.URL
+	}
+
+	return &Repository{
+		urls:   urls,
+		client: &http.Client{},
+	}
+}
+
+func (r *Repository) WebhookSend(ctx context.Context, receiver string, body []byte) error {
+	url, ok := r.urls[receiver]
+	if !ok {
+		return fmt.Errorf("unknown receiver %q", receiver)
+	}
+
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("request not ok, %w", err)
+	}
+
+	rq.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(rq)
+	if err != nil {
+		return fmt.Errorf("http not ok, %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("failed to send, status_code=%d", resp.StatusCode)
+	}
+	return nil
+}
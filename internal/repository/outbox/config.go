@@ -0,0 +1,18 @@
+package outbox
+
+import "time"
+
+type Config struct {
+	// LockTimeout is how long a fetched item stays invisible to other consumers.
+	LockTimeout time.Duration `env:"LOCK_TIMEOUT" envDefault:"30s" json:"lockTimeout"`
+	// MaxAttempts moves an item to the dead letters after that many failed deliveries,
+	// zero retries it forever.
+	MaxAttempts int `env:"MAX_ATTEMPTS" envDefault:"10" json:"maxAttempts"`
+	// RetryDelay is the delay after the first failure, it doubles after each next one
+	// up to MaxRetryDelay.
+	RetryDelay    time.Duration `env:"RETRY_DELAY" envDefault:"1s" json:"retryDelay"`
+	MaxRetryDelay time.Duration `env:"MAX_RETRY_DELAY" envDefault:"1h" json:"maxRetryDelay"`
+	// Segments spreads the audit events over that many segments, each consumed by its
+	// own workers. The items of the dropped segments wait until it is raised back.
+	Segments int `env:"SEGMENTS" envDefault:"1" json:"segments"`
+}
@@ -0,0 +1,9 @@
+package journal
+
+type Config struct {
+	// Dir holds the segment files and the checkpoint.
+	Dir string `env:"DIR" envDefault:"outbox" json:"dir"`
+	// SegmentSize starts a new segment once the active one grows by it
+	// beyond the live items it started with.
+	SegmentSize int64 `env:"SEGMENT_SIZE" envDefault:"16777216" json:"segmentSize"`
+}
@@ -0,0 +1,371 @@
+// Package journal implements the outbox on the local disk for the server running
+// without the database. Every change of an item is appended to the active segment
+// file and synced before it takes effect, the checkpoint file tells where the
+// replay starts when the journal is opened again.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+const (
+	segmentExt     = ".seg"
+	checkpointName = "checkpoint"
+)
+
+// record is the state of an item after a change, the last record of an id wins.
+type record struct {
+	ID            entities.OutboxID `json:"id"`
+	Deleted       bool              `json:"deleted,omitempty"`
+	Destination   string            `json:"destination,omitempty"`
+	Segment       string            `json:"segment,omitempty"`
+	Payload       json.RawMessage   `json:"payload,omitempty"`
+	Attempts      int               `json:"attempts,omitempty"`
+	LastError     *string           `json:"last_error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at,omitzero"`
+	NextAttemptAt time.Time         `json:"next_attempt_at,omitzero"`
+	DeadAt        time.Time         `json:"dead_at,omitzero"`
+	// Group holds the records written as one line, so that they take effect together.
+	Group []record `json:"group,omitempty"`
+}
+
+// checkpoint is the position the replay starts from, everything before it is settled.
+type checkpoint struct {
+	Segment int    `json:"segment"`
+	Offset  int64  `json:"offset"`
+	LastID  uint64 `json:"last_id"`
+}
+
+// item is a live outbox item, the lock is not persisted and is lost on restart.
+type item struct {
+	record
+	seq       uint64
+	lockUntil time.Time
+}
+
+func (r *Repository) open() error {
+	if err := os.MkdirAll(r.journal.Dir, 0o755); err != nil {
+		return err
+	}
+
+	cp, err := r.readCheckpoint()
+	if err != nil {
+		return err
+	}
+	r.lastID = cp.LastID
+
+	segments, err := r.listSegments()
+	if err != nil {
+		return err
+	}
+
+	active := cp.Segment
+	for i, n := range segments {
+		if n < cp.Segment {
+			if err := os.Remove(r.segmentPath(n)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var offset int64
+		if n == cp.Segment {
+			offset = cp.Offset
+		}
+		if err := r.replay(n, offset, i == len(segments)-1); err != nil {
+			return err
+		}
+		active = n
+	}
+
+	file, err := os.OpenFile(r.segmentPath(active), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file, r.segment, r.size, r.base = file, active, stat.Size(), stat.Size()
+	return nil
+}
+
+// replay applies the records of the segment starting at the offset. The torn
+// record left by a crash in the middle of a write is cut off the last segment.
+func (r *Repository) replay(segment int, offset int64, last bool) error {
+	file, err := os.Open(r.segmentPath(segment))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	pos := offset
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return nil
+			}
+			return r.cut(segment, pos, last)
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return r.cut(segment, pos, last)
+		}
+		r.apply(rec)
+		pos += int64(len(line))
+	}
+}
+
+func (r *Repository) cut(segment int, offset int64, last bool) error {
+	if !last {
+		return fmt.Errorf("journal: segment %d is corrupted at offset %d", segment, offset)
+	}
+	return os.Truncate(r.segmentPath(segment), offset)
+}
+
+func (r *Repository) apply(rec record) {
+	if len(rec.Group) > 0 {
+		for _, x := range rec.Group {
+			r.apply(x)
+		}
+		return
+	}
+	if rec.Deleted {
+		delete(r.items, rec.ID)
+		return
+	}
+
+	seq, _ := strconv.ParseUint(rec.ID, 10, 64)
+	if seq > r.lastID {
+		r.lastID = seq
+	}
+	r.items[rec.ID] = &item{record: rec, seq: seq}
+}
+
+// append writes the records to the active segment, syncs it and applies them.
+func (r *Repository) append(recs ...record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	if _, err := r.file.Write(buf.Bytes()); err != nil {
+		r.file.Truncate(r.size)
+		return err
+	}
+	if err := r.file.Sync(); err != nil {
+		r.file.Truncate(r.size)
+		return err
+	}
+	r.size += int64(buf.Len())
+
+	for _, rec := range recs {
+		r.apply(rec)
+	}
+
+	// the records are durable already, a failed compaction is retried on the next append
+	if err := r.compact(); err != nil {
+		log.Println("outbox journal compaction not ok,", err.Error())
+	}
+	return nil
+}
+
+// compact starts a new segment holding only the live items once the active one
+// has grown by SegmentSize, or moves the checkpoint to the end when nothing is
+// left to deliver. The growth is counted from the snapshot the segment started
+// with and is at least its size, so a backlog larger than SegmentSize is not
+// rewritten on every append.
+func (r *Repository) compact() error {
+	switch {
+	case r.size-r.base >= max(r.journal.SegmentSize, r.base):
+		return r.rotate()
+	case len(r.items) == 0:
+		if err := r.writeCheckpoint(checkpoint{Segment: r.segment, Offset: r.size, LastID: r.lastID}); err != nil {
+			return err
+		}
+		return r.removeBefore(r.segment)
+	default:
+		return nil
+	}
+}
+
+func (r *Repository) rotate() error {
+	next := r.segment + 1
+	path := r.segmentPath(next)
+
+	var buf bytes.Buffer
+	for _, it := range r.sorted() {
+		data, err := json.Marshal(it.record)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(buf.Bytes())
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = r.writeCheckpoint(checkpoint{Segment: next, LastID: r.lastID})
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+
+	r.file.Close()
+	r.file, r.segment, r.size, r.base = file, next, int64(buf.Len()), int64(buf.Len())
+
+	return r.removeBefore(next)
+}
+
+func (r *Repository) readCheckpoint() (cp checkpoint, err error) {
+	data, err := os.ReadFile(filepath.Join(r.journal.Dir, checkpointName))
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+
+	err = json.Unmarshal(data, &cp)
+	return cp, err
+}
+
+// writeCheckpoint replaces the checkpoint file atomically.
+func (r *Repository) writeCheckpoint(cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.journal.Dir, checkpointName)
+	tmp := path + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(r.journal.Dir)
+}
+
+func (r *Repository) removeBefore(segment int) error {
+	segments, err := r.listSegments()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range segments {
+		if n >= segment {
+			break
+		}
+		if err := os.Remove(r.segmentPath(n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) listSegments() ([]int, error) {
+	entries, err := os.ReadDir(r.journal.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			segments = append(segments, n)
+		}
+	}
+	slices.Sort(segments)
+
+	return segments, nil
+}
+
+func (r *Repository) segmentPath(segment int) string {
+	return filepath.Join(r.journal.Dir, fmt.Sprintf("%010d%s", segment, segmentExt))
+}
+
+// sorted returns the live items in the order they were added.
+func (r *Repository) sorted() []*item {
+	items := make([]*item, 0, len(r.items))
+	for _, it := range r.items {
+		items = append(items, it)
+	}
+	slices.SortFunc(items, func(a, b *item) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return items
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return file.Sync()
+}
@@ -0,0 +1,341 @@
+package journal
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+)
+
+// Repository is the outbox kept in the journal, it honours the same contract
+// as the database outbox.
+type Repository struct {
+	config  outbox.Config
+	journal Config
+
+	mx      sync.Mutex
+	items   map[entities.OutboxID]*item
+	lastID  uint64
+	file    *os.File
+	segment int
+	size    int64
+	// base is the size of the active segment when it was started or reopened,
+	// the segment is compacted once it grows by SegmentSize beyond it.
+	base int64
+}
+
+// New opens the journal in the configured directory and replays it.
+func New(config outbox.Config, journal Config) (*Repository, error) {
+	r := &Repository{
+		config:  config,
+		journal: journal,
+		items:   make(map[entities.OutboxID]*item),
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Repository) Close() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return r.file.Close()
+}
+
+func (r *Repository) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	recs := make([]record, 0, len(items))
+	for _, x := range items {
+		r.lastID++
+		recs = append(recs, record{
+			ID:          strconv.FormatUint(r.lastID, 10),
+			Destination: x.Destination,
+			Segment:     cmp.Or(x.Segment, segment),
+			Payload:     x.Payload,
+			CreatedAt:   now,
+		})
+	}
+
+	return r.append(recs...)
+}
+
+func (r *Repository) OutboxGetNext(
+	ctx context.Context, destination models.OutboxDestination, segment string, limit int,
+) (resp []entities.Outbox, err error) {
+	_, span := tracing.Start(ctx, "outbox.Fetch",
+		attribute.String("outbox.destination", string(destination)),
+		attribute.String("outbox.segment", segment),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("outbox.items", len(resp)))
+		tracing.End(span, err)
+	}()
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	lockUntil := now.Add(r.config.LockTimeout)
+	for _, it := range r.sorted() {
+		if len(resp) >= limit {
+			break
+		}
+		if it.Destination != string(destination) || it.Segment != segment || !it.DeadAt.IsZero() ||
+			it.lockUntil.After(now) || it.NextAttemptAt.After(now) {
+			continue
+		}
+
+		it.lockUntil = lockUntil
+		resp = append(resp, entities.Outbox{
+			ID:          it.ID,
+			Destination: it.Destination,
+			Segment:     it.Segment,
+			Payload:     it.Payload,
+			LockUntil:   &lockUntil,
+			Attempts:    it.Attempts,
+			LastError:   it.LastError,
+		})
+	}
+
+	return resp, nil
+}
+
+// OutboxCommit deletes the delivered items and schedules the failed ones for redelivery
+// with the exponential backoff, moving them to the dead letters after MaxAttempts failures
+// or at once when the failure is permanent.
+func (r *Repository) OutboxCommit(
+	ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string,
+) (err error) {
+	_, span := tracing.Start(ctx, "outbox.Commit",
+		attribute.String("outbox.segment", segment),
+		attribute.Int("outbox.completed", len(okIds)),
+		attribute.Int("outbox.failed", len(failed)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if len(okIds) == 0 && len(failed) == 0 {
+		return nil
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	recs := make([]record, 0, len(okIds)+len(failed))
+	for _, id := range okIds {
+		if it, ok := r.items[id]; ok && it.DeadAt.IsZero() {
+			recs = append(recs, record{ID: id, Deleted: true})
+		}
+	}
+	for _, x := range failed {
+		it, ok := r.items[x.ID]
+		if !ok || !it.DeadAt.IsZero() {
+			continue
+		}
+
+		rec := it.record
+		rec.LastError = &x.Error
+		rec.NextAttemptAt = now.Add(r.retryDelay(rec.Attempts))
+		rec.Attempts++
+		if x.Permanent || r.config.MaxAttempts > 0 && rec.Segment == segment && rec.Attempts >= r.config.MaxAttempts {
+			rec.NextAttemptAt, rec.DeadAt = time.Time{}, now
+		}
+		recs = append(recs, rec)
+	}
+
+	return r.append(recs...)
+}
+
+// OutboxFanout adds the items and deletes the completed ones they were made of
+// in one record, a torn write loses both of them.
+func (r *Repository) OutboxFanout(
+	ctx context.Context, items []entities.Outbox, okIds []entities.OutboxID, segment string,
+) (err error) {
+	_, span := tracing.Start(ctx, "outbox.Fanout",
+		attribute.String("outbox.segment", segment),
+		attribute.Int("outbox.completed", len(okIds)),
+		attribute.Int("outbox.added", len(items)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if len(items) == 0 && len(okIds) == 0 {
+		return nil
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	lastID := r.lastID
+	group := make([]record, 0, len(items)+len(okIds))
+	for _, x := range items {
+		lastID++
+		group = append(group, record{
+			ID:          strconv.FormatUint(lastID, 10),
+			Destination: x.Destination,
+			Segment:     cmp.Or(x.Segment, segment),
+			Payload:     x.Payload,
+			CreatedAt:   now,
+		})
+	}
+	for _, id := range okIds {
+		if it, ok := r.items[id]; ok && it.DeadAt.IsZero() {
+			group = append(group, record{ID: id, Deleted: true})
+		}
+	}
+	if len(group) == 0 {
+		return nil
+	}
+
+	return r.append(record{Group: group})
+}
+
+func (r *Repository) OutboxBacklog(ctx context.Context) (resp []entities.OutboxBacklog, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	index := make(map[[2]string]int)
+	for _, it := range r.sorted() {
+		if !it.DeadAt.IsZero() {
+			continue
+		}
+
+		key := [2]string{it.Destination, it.Segment}
+		i, ok := index[key]
+		if !ok {
+			i = len(resp)
+			index[key] = i
+			resp = append(resp, entities.OutboxBacklog{Destination: it.Destination, Segment: it.Segment})
+		}
+		resp[i].Count++
+	}
+
+	return resp, nil
+}
+
+// DeadLetterList returns the latest dead letters of the destination, or of any when it is empty.
+func (r *Repository) DeadLetterList(ctx context.Context, destination string, limit int) (resp []entities.DeadLetter, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	dead := r.dead(nil, destination)
+	slices.SortStableFunc(dead, func(a, b *item) int {
+		return b.DeadAt.Compare(a.DeadAt)
+	})
+
+	dead = dead[:min(len(dead), max(limit, 0))]
+
+	resp = make([]entities.DeadLetter, 0, len(dead))
+	for _, it := range dead {
+		resp = append(resp, entities.DeadLetter{
+			ID:          it.ID,
+			Destination: it.Destination,
+			Segment:     it.Segment,
+			Payload:     it.Payload,
+			Attempts:    it.Attempts,
+			LastError:   it.LastError,
+			CreatedAt:   it.DeadAt,
+		})
+	}
+
+	return resp, nil
+}
+
+// DeadLetterRequeue moves the dead letters back to the outbox, the empty ids
+// and destination match any. It returns the ids of the requeued items.
+func (r *Repository) DeadLetterRequeue(
+	ctx context.Context, ids []entities.OutboxID, destination string,
+) (requeued []entities.OutboxID, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	dead := r.dead(ids, destination)
+	recs := make([]record, 0, len(dead))
+	requeued = make([]entities.OutboxID, 0, len(dead))
+	for _, it := range dead {
+		recs = append(recs, record{
+			ID:          it.ID,
+			Destination: it.Destination,
+			Segment:     it.Segment,
+			Payload:     it.Payload,
+			CreatedAt:   time.Now(),
+		})
+		requeued = append(requeued, it.ID)
+	}
+
+	if err := r.append(recs...); err != nil {
+		return nil, err
+	}
+
+	return requeued, nil
+}
+
+// DeadLetterPurge deletes the dead letters, the empty ids and destination match any.
+// It returns the ids of the deleted items.
+func (r *Repository) DeadLetterPurge(
+	ctx context.Context, ids []entities.OutboxID, destination string,
+) (purged []entities.OutboxID, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	dead := r.dead(ids, destination)
+	recs := make([]record, 0, len(dead))
+	purged = make([]entities.OutboxID, 0, len(dead))
+	for _, it := range dead {
+		recs = append(recs, record{ID: it.ID, Deleted: true})
+		purged = append(purged, it.ID)
+	}
+
+	if err := r.append(recs...); err != nil {
+		return nil, err
+	}
+
+	return purged, nil
+}
+
+// dead returns the dead letters matching the ids and the destination, the empty ones match any.
+func (r *Repository) dead(ids []entities.OutboxID, destination string) []*item {
+	var dead []*item
+	for _, it := range r.sorted() {
+		if it.DeadAt.IsZero() ||
+			(destination != "" && it.Destination != destination) ||
+			(len(ids) > 0 && !slices.Contains(ids, it.ID)) {
+			continue
+		}
+		dead = append(dead, it)
+	}
+	return dead
+}
+
+// retryDelay doubles the RetryDelay with every failed attempt up to MaxRetryDelay.
+func (r *Repository) retryDelay(attempts int) time.Duration {
+	delay := r.config.RetryDelay
+	for range attempts {
+		if delay >= r.config.MaxRetryDelay {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, r.config.MaxRetryDelay)
+}
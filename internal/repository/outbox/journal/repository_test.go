@@ -0,0 +1,297 @@
+package journal_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/outbox/journal"
+)
+
+const testSegment = "test"
+
+func newRepository(t *testing.T, dir string, config outbox.Config) *journal.Repository {
+	t.Helper()
+
+	r, err := journal.New(config, journal.Config{Dir: dir, SegmentSize: 1 << 20})
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+
+	return r
+}
+
+func newOutboxes(n int) []entities.Outbox {
+	items := make([]entities.Outbox, 0, n)
+	for i := range n {
+		payload, _ := json.Marshal(map[string]int{"n": i})
+		items = append(items, entities.Outbox{
+			Destination: string(models.AuditOutboxDestination),
+			Segment:     testSegment,
+			Payload:     payload,
+		})
+	}
+	return items
+}
+
+func ids(items []entities.Outbox) []entities.OutboxID {
+	ids := make([]entities.OutboxID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func TestRepository_GetNextCommit(t *testing.T) {
+	ctx := context.Background()
+	r := newRepository(t, t.TempDir(), outbox.Config{LockTimeout: time.Minute})
+
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(3), testSegment))
+
+	got, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"1", "2"}, ids(got))
+
+	locked, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"3"}, ids(locked))
+
+	other, err := r.OutboxGetNext(ctx, models.NotifyOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+
+	require.NoError(t, r.OutboxCommit(ctx, []entities.OutboxID{"1", "2", "3"}, nil, testSegment))
+
+	backlog, err := r.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, backlog)
+}
+
+func TestRepository_Reopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	r := newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(3), testSegment))
+	require.NoError(t, r.OutboxCommit(ctx, []entities.OutboxID{"2"}, nil, testSegment))
+	require.NoError(t, r.Close())
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+
+	got, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"1", "3"}, ids(got))
+	assert.JSONEq(t, `{"n":0}`, string(got[0].Payload))
+
+	require.NoError(t, r.OutboxCommit(ctx, ids(got), nil, testSegment))
+	require.NoError(t, r.Close())
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(1), testSegment))
+
+	got, err = r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"4"}, ids(got))
+}
+
+func TestRepository_Fanout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	r := newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(2), testSegment))
+
+	fanned := newOutboxes(2)
+	for i := range fanned {
+		fanned[i].Destination = string(models.AuditSinkDestination("file"))
+	}
+	require.NoError(t, r.OutboxFanout(ctx, fanned, []entities.OutboxID{"1", "2"}, testSegment))
+	require.NoError(t, r.Close())
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	backlog, err := r.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxBacklog{
+		{Destination: string(models.AuditSinkDestination("file")), Segment: testSegment, Count: 2},
+	}, backlog)
+
+	got, err := r.OutboxGetNext(ctx, models.AuditSinkDestination("file"), testSegment, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"3", "4"}, ids(got))
+}
+
+func TestRepository_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := outbox.Config{LockTimeout: time.Minute, MaxAttempts: 2}
+
+	r := newRepository(t, dir, config)
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(2), testSegment))
+
+	for range config.MaxAttempts {
+		got, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+
+		require.NoError(t, r.OutboxCommit(ctx, nil, []entities.OutboxFailure{
+			{ID: "1", Error: "unreachable"},
+			{ID: "2", Error: "unreachable"},
+		}, testSegment))
+	}
+
+	got, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	require.NoError(t, r.Close())
+	r = newRepository(t, dir, config)
+
+	dead, err := r.DeadLetterList(ctx, string(models.AuditOutboxDestination), 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 2)
+	assert.Equal(t, config.MaxAttempts, dead[0].Attempts)
+	assert.Equal(t, "unreachable", *dead[0].LastError)
+
+	requeued, err := r.DeadLetterRequeue(ctx, []entities.OutboxID{"1"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"1"}, requeued)
+
+	purged, err := r.DeadLetterPurge(ctx, nil, string(models.AuditOutboxDestination))
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"2"}, purged)
+
+	got, err = r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "1", got[0].ID)
+	assert.Zero(t, got[0].Attempts)
+}
+
+func TestRepository_PermanentFailure(t *testing.T) {
+	ctx := context.Background()
+	r := newRepository(t, t.TempDir(), outbox.Config{LockTimeout: time.Minute})
+
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(2), testSegment))
+	require.NoError(t, r.OutboxCommit(ctx, nil, []entities.OutboxFailure{
+		{ID: "1", Error: "status_code=400", Permanent: true},
+		{ID: "2", Error: "status_code=503"},
+	}, testSegment))
+
+	dead, err := r.DeadLetterList(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "1", dead[0].ID)
+	assert.Equal(t, 1, dead[0].Attempts)
+
+	backlog, err := r.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+	assert.EqualValues(t, 1, backlog[0].Count)
+}
+
+func TestRepository_RetryDelay(t *testing.T) {
+	ctx := context.Background()
+	r := newRepository(t, t.TempDir(), outbox.Config{RetryDelay: time.Hour, MaxRetryDelay: time.Hour})
+
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(1), testSegment))
+	require.NoError(t, r.OutboxCommit(ctx, nil, []entities.OutboxFailure{{ID: "1", Error: "timeout"}}, testSegment))
+
+	got, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	backlog, err := r.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxBacklog{
+		{Destination: string(models.AuditOutboxDestination), Segment: testSegment, Count: 1},
+	}, backlog)
+}
+
+func TestRepository_Rotate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	r, err := journal.New(outbox.Config{LockTimeout: time.Minute}, journal.Config{Dir: dir, SegmentSize: 512})
+	require.NoError(t, err)
+
+	for range 20 {
+		require.NoError(t, r.OutboxAdd(ctx, newOutboxes(1), testSegment))
+	}
+	require.NoError(t, r.OutboxCommit(ctx, []entities.OutboxID{"1", "2", "3"}, nil, testSegment))
+	require.NoError(t, r.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	backlog, err := r.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+	assert.EqualValues(t, 17, backlog[0].Count)
+}
+
+func TestRepository_RotateBacklog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	r, err := journal.New(outbox.Config{LockTimeout: time.Minute}, journal.Config{Dir: dir, SegmentSize: 512})
+	require.NoError(t, err)
+
+	// the backlog outgrows the segment size, it is not rewritten on every append
+	for range 100 {
+		require.NoError(t, r.OutboxAdd(ctx, newOutboxes(1), testSegment))
+	}
+	require.NoError(t, r.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	segment, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(segments[0]), ".seg"))
+	require.NoError(t, err)
+	assert.Less(t, segment, 10)
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	backlog, err := r.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+	assert.EqualValues(t, 100, backlog[0].Count)
+}
+
+func TestRepository_TornRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	r := newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(2), testSegment))
+	require.NoError(t, r.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	file, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"id":"3","destination":"fi`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	require.NoError(t, r.OutboxAdd(ctx, newOutboxes(1), testSegment))
+	require.NoError(t, r.Close())
+
+	r = newRepository(t, dir, outbox.Config{LockTimeout: time.Minute})
+	got, err := r.OutboxGetNext(ctx, models.AuditOutboxDestination, testSegment, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.OutboxID{"1", "2", "3"}, ids(got))
+}
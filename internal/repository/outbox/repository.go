@@ -6,35 +6,64 @@ import (
 	"log"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/config/db"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 )
 
 var ErrUnavailable = errors.New("db unavailable")
 
 type Repository struct {
+	config  Config
 	conn    *db.PGConnect
 	isAlive bool
 }
 
-func New(conn *db.PGConnect) *Repository {
+func New(config Config, conn *db.PGConnect) *Repository {
 	return &Repository{
+		config:  config,
 		conn:    conn,
 		isAlive: checkAlive(conn),
 	}
 }
 
+func (r *Repository) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) (err error) {
+	if !r.isAlive {
+		return ErrUnavailable
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	return r.conn.QueryNoResult(ctx,
+		"select outbox.outbox_add_new(_items => $1, _segment => $2)",
+		items, segment,
+	)
+}
+
 func (r *Repository) OutboxGetNext(
 	ctx context.Context, destination models.OutboxDestination, segment string, limit int,
 ) (resp []entities.Outbox, err error) {
+	ctx, span := tracing.Start(ctx, "outbox.Fetch",
+		attribute.String("outbox.destination", string(destination)),
+		attribute.String("outbox.segment", segment),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("outbox.items", len(resp)))
+		tracing.End(span, err)
+	}()
+
 	if !r.isAlive {
 		return nil, ErrUnavailable
 	}
 	err = r.conn.QueryWithOneResultJSON(ctx,
 		&resp,
-		"select outbox.outbox_get_next(_destination => $1, _segment => $2, _limit => $3)",
-		destination, segment, limit,
+		"select outbox.outbox_get_next(_destination => $1, _segment => $2, _limit => $3, _lock_seconds => $4)",
+		destination, segment, limit, r.config.LockTimeout.Seconds(),
 	)
 	if err != nil {
 		return nil, err
@@ -43,21 +72,143 @@ func (r *Repository) OutboxGetNext(
 	return resp, nil
 }
 
-func (r *Repository) OutboxCommit(ctx context.Context, okIds []entities.OutboxID, failedIds []entities.OutboxID, segment string) (err error) {
+// OutboxCommit deletes the delivered items and schedules the failed ones for redelivery
+// with the exponential backoff, moving them to the dead letters after MaxAttempts failures
+// or at once when the failure is permanent.
+func (r *Repository) OutboxCommit(
+	ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "outbox.Commit",
+		attribute.String("outbox.segment", segment),
+		attribute.Int("outbox.completed", len(okIds)),
+		attribute.Int("outbox.failed", len(failed)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	if !r.isAlive {
 		return ErrUnavailable
 	}
 
-	if len(okIds) == 0 && len(failedIds) == 0 {
+	if len(okIds) == 0 && len(failed) == 0 {
 		return nil
 	}
 
 	return r.conn.QueryNoResult(ctx,
-		"select outbox.outbox_commit(_ok_ids => $1, _failed_ids => $2, _segment => $3)",
-		okIds, failedIds, segment,
+		`select outbox.outbox_commit(
+			_ok_ids => $1, _failed => $2, _segment => $3,
+			_max_attempts => $4, _retry_seconds => $5, _max_retry_seconds => $6
+		)`,
+		okIds, failed, segment,
+		r.config.MaxAttempts, r.config.RetryDelay.Seconds(), r.config.MaxRetryDelay.Seconds(),
 	)
 }
 
+// OutboxFanout adds the items and deletes the completed ones they were made of
+// in one transaction.
+func (r *Repository) OutboxFanout(
+	ctx context.Context, items []entities.Outbox, okIds []entities.OutboxID, segment string,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "outbox.Fanout",
+		attribute.String("outbox.segment", segment),
+		attribute.Int("outbox.completed", len(okIds)),
+		attribute.Int("outbox.added", len(items)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if !r.isAlive {
+		return ErrUnavailable
+	}
+
+	if len(items) == 0 && len(okIds) == 0 {
+		return nil
+	}
+
+	return r.conn.QueryNoResult(ctx,
+		"select outbox.outbox_fanout(_items => $1, _done_ids => $2, _segment => $3)",
+		items, okIds, segment,
+	)
+}
+
+func (r *Repository) OutboxBacklog(ctx context.Context) (resp []entities.OutboxBacklog, err error) {
+	if !r.isAlive {
+		return nil, ErrUnavailable
+	}
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&resp,
+		"select outbox.outbox_backlog()",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// DeadLetterList returns the latest dead letters of the destination, or of any when it is empty.
+func (r *Repository) DeadLetterList(ctx context.Context, destination string, limit int) (resp []entities.DeadLetter, err error) {
+	if !r.isAlive {
+		return nil, ErrUnavailable
+	}
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&resp,
+		"select outbox.dead_letter_list(_destination => nullif($1, ''), _limit => $2)",
+		destination, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// DeadLetterRequeue moves the dead letters back to the outbox, the empty ids
+// and destination match any. It returns the ids of the requeued items.
+func (r *Repository) DeadLetterRequeue(
+	ctx context.Context, ids []entities.OutboxID, destination string,
+) (requeued []entities.OutboxID, err error) {
+	if !r.isAlive {
+		return nil, ErrUnavailable
+	}
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&requeued,
+		"select outbox.dead_letter_requeue(_ids => $1, _destination => nullif($2, ''))",
+		nullIDs(ids), destination,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return requeued, nil
+}
+
+// DeadLetterPurge deletes the dead letters, the empty ids and destination match any.
+// It returns the ids of the deleted items.
+func (r *Repository) DeadLetterPurge(
+	ctx context.Context, ids []entities.OutboxID, destination string,
+) (purged []entities.OutboxID, err error) {
+	if !r.isAlive {
+		return nil, ErrUnavailable
+	}
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&purged,
+		"select outbox.dead_letter_purge(_ids => $1, _destination => nullif($2, ''))",
+		nullIDs(ids), destination,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return purged, nil
+}
+
+// nullIDs passes the empty id list as null, which matches any item.
+func nullIDs(ids []entities.OutboxID) any {
+	if len(ids) == 0 {
+		return nil
+	}
+	return ids
+}
+
 func checkAlive(conn *db.PGConnect) bool {
 	if conn == nil {
 		return false
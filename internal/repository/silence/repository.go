@@ -0,0 +1,116 @@
+package silence
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/config/db"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+// Repository keeps the silences in the database, or in memory
+// while the database is unavailable.
+type Repository struct {
+	conn    *db.PGConnect
+	isAlive bool
+
+	mx       sync.Mutex
+	lastID   int
+	silences map[entities.SilenceID]entities.Silence
+}
+
+func New(conn *db.PGConnect) *Repository {
+	return &Repository{
+		conn:     conn,
+		isAlive:  checkAlive(conn),
+		silences: make(map[entities.SilenceID]entities.Silence),
+	}
+}
+
+func (r *Repository) SilenceAdd(ctx context.Context, item entities.Silence) (resp entities.Silence, err error) {
+	if !r.isAlive {
+		r.mx.Lock()
+		defer r.mx.Unlock()
+
+		r.lastID++
+		item.ID = strconv.Itoa(r.lastID)
+		r.silences[item.ID] = item
+		return item, nil
+	}
+
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&resp,
+		"select notify.silences_add(_item => $1)",
+		item,
+	)
+	return resp, err
+}
+
+func (r *Repository) SilenceList(ctx context.Context, ts time.Time) (resp []entities.Silence, err error) {
+	if !r.isAlive {
+		r.mx.Lock()
+		defer r.mx.Unlock()
+
+		for _, item := range r.silences {
+			if item.EndsAt.After(ts) {
+				resp = append(resp, item)
+			}
+		}
+		slices.SortFunc(resp, func(a, b entities.Silence) int {
+			return cmp.Or(
+				a.StartsAt.Compare(b.StartsAt),
+				cmp.Compare(len(a.ID), len(b.ID)),
+				strings.Compare(a.ID, b.ID),
+			)
+		})
+		return resp, nil
+	}
+
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&resp,
+		"select notify.silences_list(_ts => $1)",
+		ts,
+	)
+	return resp, err
+}
+
+func (r *Repository) SilenceDelete(ctx context.Context, id entities.SilenceID) (ok bool, err error) {
+	if !r.isAlive {
+		r.mx.Lock()
+		defer r.mx.Unlock()
+
+		_, ok = r.silences[id]
+		delete(r.silences, id)
+		return ok, nil
+	}
+
+	var deleted []entities.SilenceID
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&deleted,
+		"select notify.silences_delete(_id => $1)",
+		id,
+	)
+	return len(deleted) > 0, err
+}
+
+func checkAlive(conn *db.PGConnect) bool {
+	if conn == nil {
+		return false
+	}
+
+	initCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := conn.Ping(initCtx); err != nil {
+		log.Println("db ping not ok,", err.Error())
+		return false
+	}
+
+	return true
+}
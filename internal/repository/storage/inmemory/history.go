@@ -0,0 +1,102 @@
+package inmemory
+
+import (
+	"context"
+	"iter"
+	"math"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+// maxHistorySamples bounds the per-metric history kept in memory.
+const maxHistorySamples = 10000
+
+type sample struct {
+	TS    time.Time
+	Value float64
+}
+
+// samples is the history of a metric, a ring buffer keeping the latest
+// maxHistorySamples, so that an append at the cap costs O(1).
+type samples struct {
+	items []sample
+	start int
+}
+
+func (s *samples) push(x sample) {
+	if len(s.items) < maxHistorySamples {
+		s.items = append(s.items, x)
+		return
+	}
+	s.items[s.start] = x
+	s.start = (s.start + 1) % len(s.items)
+}
+
+// all yields the samples from the oldest one.
+func (s *samples) all() iter.Seq[sample] {
+	return func(yield func(sample) bool) {
+		if s == nil {
+			return
+		}
+		for i := range s.items {
+			if !yield(s.items[(s.start+i)%len(s.items)]) {
+				return
+			}
+		}
+	}
+}
+
+func historyKey(metricType, metricName string, labels pkg.Labels) string {
+	return metricType + ":" + labels.Series(metricName)
+}
+
+func (r *Repository) record(metricType, metricName string, labels pkg.Labels, ts time.Time, value float64) {
+	key := historyKey(metricType, metricName, labels)
+
+	h, ok := r.history[key]
+	if !ok {
+		h = &samples{}
+		r.history[key] = h
+	}
+	h.push(sample{TS: ts, Value: value})
+}
+
+func (r *Repository) History(
+	ctx context.Context, metricType, metricName string, labels pkg.Labels, from, to time.Time, step time.Duration,
+) ([]entities.HistoryPoint, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	points := make([]entities.HistoryPoint, 0)
+
+	for s := range r.history[historyKey(metricType, metricName, labels)].all() {
+		if s.TS.Before(from) || !s.TS.Before(to) {
+			continue
+		}
+
+		bucket := time.Unix(0, s.TS.UnixNano()-s.TS.UnixNano()%int64(step)).UTC()
+
+		if n := len(points); n > 0 && points[n-1].TS.Equal(bucket) {
+			p := &points[n-1]
+			p.Min = math.Min(p.Min, s.Value)
+			p.Max = math.Max(p.Max, s.Value)
+			p.Avg += (s.Value - p.Avg) / float64(p.Count+1)
+			p.Last = s.Value
+			p.Count++
+			continue
+		}
+
+		points = append(points, entities.HistoryPoint{
+			TS:    bucket,
+			Min:   s.Value,
+			Max:   s.Value,
+			Avg:   s.Value,
+			Last:  s.Value,
+			Count: 1,
+		})
+	}
+
+	return points, nil
+}
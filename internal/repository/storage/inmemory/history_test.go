@@ -0,0 +1,26 @@
+package inmemory
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamples_Push(t *testing.T) {
+	var s samples
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range maxHistorySamples + 3 {
+		s.push(sample{TS: ts.Add(time.Duration(i) * time.Second), Value: float64(i)})
+	}
+
+	got := slices.Collect(s.all())
+	assert.Len(t, got, maxHistorySamples)
+	assert.Equal(t, 3.0, got[0].Value)
+	assert.Equal(t, float64(maxHistorySamples+2), got[len(got)-1].Value)
+	assert.True(t, slices.IsSortedFunc(got, func(a, b sample) int { return a.TS.Compare(b.TS) }))
+
+	var missing *samples
+	assert.Empty(t, slices.Collect(missing.all()))
+}
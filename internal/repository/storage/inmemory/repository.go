@@ -2,10 +2,15 @@ package inmemory
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
 	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 type Encoder interface {
@@ -16,149 +21,299 @@ type Encoder interface {
 type Repository struct {
 	mtx        sync.RWMutex
 	collection map[string]*Item
+	history    map[string]*samples
 	encoder    Encoder
 }
 
 func New(encoder Encoder) *Repository {
 	return &Repository{
 		collection: make(map[string]*Item),
+		history:    make(map[string]*samples),
 		encoder:    encoder,
 	}
 }
 
-func (r *Repository) AddUpdateBatch(ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem) (ok bool, err error) {
+// AddUpdateBatch applies the batch all or nothing and returns the changes of the series.
+func (r *Repository) AddUpdateBatch(
+	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+) (changes []models.MetricChange, ok bool, err error) {
+	return r.AddUpdateBatchCommit(ctx, counters, gauges, histograms, nil)
+}
+
+// AddUpdateBatchCommit applies the batch like AddUpdateBatch, commit gets the
+// changes before they are applied and the batch is dropped when it fails.
+func (r *Repository) AddUpdateBatchCommit(
+	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	commit func(changes []models.MetricChange) error,
+) (changes []models.MetricChange, ok bool, err error) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	var intZero int64
 	for _, counter := range counters {
-		name := counter.MetricName
-		if _, ok := r.collection[name]; !ok {
-			r.collection[name] = &Item{Name: name, IntValue: &intZero}
+		if !r.checkVersion(counter.Labels.Series(counter.MetricName), counter.IfVersion) {
+			return nil, false, entities.ErrVersionConflict
+		}
+	}
+	for _, gauge := range gauges {
+		if !r.checkVersion(gauge.Labels.Series(gauge.MetricName), gauge.IfVersion) {
+			return nil, false, entities.ErrVersionConflict
+		}
+	}
+
+	// created are the series new to the collection, they have no previous
+	// value and are added only once the whole batch is accepted
+	created := make(map[string]*Item)
+	lookup := func(key string, create func() *Item) *Item {
+		if x, ok := r.collection[key]; ok {
+			return x
+		}
+		if _, ok := created[key]; !ok {
+			created[key] = create()
 		}
+		return created[key]
+	}
 
-		x := r.collection[name]
+	for _, counter := range counters {
+		x := lookup(counter.Labels.Series(counter.MetricName), func() *Item {
+			return &Item{Name: counter.MetricName, Labels: counter.Labels, IntValue: pkg.ToPtr[int64](0)}
+		})
 		if !x.hasIntValue() {
-			return false, nil
+			return nil, false, nil
 		}
 	}
-
-	var floatZero float64
 	for _, gauge := range gauges {
-		name := gauge.MetricName
-		if _, ok := r.collection[name]; !ok {
-			r.collection[name] = &Item{Name: name, FloatValue: &floatZero}
+		x := lookup(gauge.Labels.Series(gauge.MetricName), func() *Item {
+			return &Item{Name: gauge.MetricName, Labels: gauge.Labels, FloatValue: pkg.ToPtr[float64](0)}
+		})
+		if !x.hasFloatValue() {
+			return nil, false, nil
+		}
+	}
+	for _, histogram := range histograms {
+		x := lookup(histogram.Labels.Series(histogram.MetricName), func() *Item {
+			return &Item{Name: histogram.MetricName, Labels: histogram.Labels, HistValue: emptyHistogram(histogram)}
+		})
+		if !x.hasHistValue() || !slices.Equal(x.HistValue.Bounds, histogram.Bounds) {
+			return nil, false, nil
 		}
+	}
 
-		x := r.collection[name]
-		if !x.hasFloatValue() {
-			return false, nil
+	// the batch is applied to the copies of the items, they replace the
+	// stored ones once the changes are committed
+	staged := make(map[string]*Item)
+	stage := func(key string) *Item {
+		if x, ok := staged[key]; ok {
+			return x
+		}
+		x, ok := created[key]
+		if !ok {
+			x = r.collection[key].clone()
 		}
+		staged[key] = x
+		return x
 	}
+	records := make([]func(), 0, len(counters)+len(gauges))
 
+	changes = make([]models.MetricChange, 0, len(counters)+len(gauges)+len(histograms))
 	for _, counter := range counters {
-		r.collection[counter.MetricName].add(counter.MetricValue)
+		key := counter.Labels.Series(counter.MetricName)
+		x := stage(key)
+		previous := *x.IntValue
+
+		x.add(counter.Op, counter.MetricValue)
+		x.touch(counter.UpdatedAt)
+		value := float64(*x.IntValue)
+		records = append(records, func() {
+			r.record(counter.MetricType, counter.MetricName, counter.Labels, counter.UpdatedAt, value)
+		})
+
+		changes = append(changes, newChange(x, pkg.MetricTypeCounter, counter.Op,
+			counter.MetricValue, changeValue(created[key] == nil, previous), *x.IntValue))
+		delete(created, key)
 	}
 	for _, gauge := range gauges {
-		r.collection[gauge.MetricName].update(gauge.MetricValue)
+		key := gauge.Labels.Series(gauge.MetricName)
+		x := stage(key)
+		previous := *x.FloatValue
+
+		x.update(gauge.Op, gauge.MetricValue)
+		x.touch(gauge.UpdatedAt)
+		value := *x.FloatValue
+		records = append(records, func() {
+			r.record(gauge.MetricType, gauge.MetricName, gauge.Labels, gauge.UpdatedAt, value)
+		})
+
+		changes = append(changes, newChange(x, pkg.MetricTypeGauge, gauge.Op,
+			gauge.MetricValue, changeValue(created[key] == nil, previous), *x.FloatValue))
+		delete(created, key)
+	}
+	for _, histogram := range histograms {
+		key := histogram.Labels.Series(histogram.MetricName)
+		x := stage(key)
+		previous := newHistogramValue(*x.HistValue)
+
+		x.merge(toHistogram(histogram))
+		x.touch(histogram.UpdatedAt)
+
+		changes = append(changes, newChange(x, pkg.MetricTypeHistogram, "",
+			histogramValue{Sum: histogram.Sum, Count: histogram.Count},
+			changeValue(created[key] == nil, previous), newHistogramValue(*x.HistValue)))
+		delete(created, key)
 	}
 
-	return true, nil
-}
+	if commit != nil {
+		if err := commit(changes); err != nil {
+			return nil, false, err
+		}
+	}
+	for key, x := range staged {
+		r.collection[key] = x
+	}
+	for _, record := range records {
+		record()
+	}
 
-func (r *Repository) Add(ctx context.Context, item entities.CounterItem) (bool, error) {
-	r.mtx.Lock()
-	defer r.mtx.Unlock()
+	return changes, true, nil
+}
 
-	var zero int64
-	name := item.MetricName
-	if _, ok := r.collection[name]; !ok {
-		r.collection[name] = &Item{Name: name, IntValue: &zero}
+// checkVersion reports whether the series is stored with the expected version,
+// a missing series has version 0 and a nil version matches any.
+func (r *Repository) checkVersion(key string, version *int64) bool {
+	if version == nil {
+		return true
 	}
 
-	x := r.collection[name]
-	if !x.hasIntValue() {
-		return false, nil
+	var current int64
+	if x, ok := r.collection[key]; ok {
+		current = x.Version
 	}
+	return current == *version
+}
 
-	r.collection[name].add(item.MetricValue)
-	return true, nil
+// Delete removes the matching metrics together with their history.
+func (r *Repository) Delete(ctx context.Context, filter entities.MetricFilter) ([]entities.MetricKey, error) {
+	return r.DeleteCommit(ctx, filter, nil)
 }
 
-func (r *Repository) Update(ctx context.Context, item entities.GaugeItem) (bool, error) {
+// DeleteCommit removes the metrics like Delete, commit gets the matching
+// series before they are removed and nothing is removed when it fails.
+func (r *Repository) DeleteCommit(
+	ctx context.Context, filter entities.MetricFilter, commit func(deleted []entities.MetricKey) error,
+) ([]entities.MetricKey, error) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	var zero float64
-	name := item.MetricName
-	if _, ok := r.collection[name]; !ok {
-		r.collection[name] = &Item{Name: name, FloatValue: &zero}
+	deleted := make([]entities.MetricKey, 0)
+	for _, item := range r.collection {
+		if !item.match(filter) {
+			continue
+		}
+
+		deleted = append(deleted, entities.MetricKey{
+			MetricType: item.metricType(),
+			MetricName: item.Name,
+			Labels:     item.Labels,
+		})
 	}
 
-	x := r.collection[name]
-	if !x.hasFloatValue() {
-		return false, nil
+	slices.SortFunc(deleted, func(a, b entities.MetricKey) int {
+		return strings.Compare(a.Labels.Series(a.MetricName), b.Labels.Series(b.MetricName))
+	})
+
+	if commit != nil && len(deleted) > 0 {
+		if err := commit(deleted); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range deleted {
+		delete(r.collection, key.Labels.Series(key.MetricName))
+		delete(r.history, historyKey(key.MetricType, key.MetricName, key.Labels))
 	}
 
-	r.collection[name].update(item.MetricValue)
-	return true, nil
+	return deleted, nil
 }
 
-func (r *Repository) GetCounter(ctx context.Context, name string) (*entities.CounterItem, bool, error) {
+func (r *Repository) GetCounter(ctx context.Context, name string, labels pkg.Labels) (*entities.CounterItem, bool, error) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
 
-	item, ok := r.collection[name]
+	item, ok := r.collection[labels.Series(name)]
 	if !ok || !item.hasIntValue() {
 		return nil, false, nil
 	}
 
 	return &entities.CounterItem{
 		MetricName:  item.Name,
+		Labels:      item.Labels,
 		MetricValue: *item.IntValue,
+		Version:     item.Version,
 	}, true, nil
 }
 
-func (r *Repository) GetGauge(ctx context.Context, name string) (*entities.GaugeItem, bool, error) {
+func (r *Repository) GetGauge(ctx context.Context, name string, labels pkg.Labels) (*entities.GaugeItem, bool, error) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
 
-	item, ok := r.collection[name]
+	item, ok := r.collection[labels.Series(name)]
 	if !ok || !item.hasFloatValue() {
 		return nil, false, nil
 	}
 
 	return &entities.GaugeItem{
 		MetricName:  item.Name,
+		Labels:      item.Labels,
 		MetricValue: *item.FloatValue,
+		Version:     item.Version,
 	}, true, nil
 }
 
+func (r *Repository) GetHistogram(ctx context.Context, name string, labels pkg.Labels) (*entities.HistogramItem, bool, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	item, ok := r.collection[labels.Series(name)]
+	if !ok || !item.hasHistValue() {
+		return nil, false, nil
+	}
+
+	x := fromHistogram(item.Name, item.Labels, *item.HistValue)
+	x.Version = item.Version
+	return &x, true, nil
+}
+
 func (r *Repository) List(ctx context.Context) (listMetricService.MetricData, error) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
 
 	counters := make([]entities.CounterItem, 0, len(r.collection))
 	gauges := make([]entities.GaugeItem, 0, len(r.collection))
+	histograms := make([]entities.HistogramItem, 0)
 
-	for name, item := range r.collection {
+	for _, item := range r.collection {
 		if item.hasIntValue() {
 			counters = append(counters, entities.CounterItem{
-				MetricName:  name,
+				MetricName:  item.Name,
+				Labels:      item.Labels,
 				MetricValue: *item.IntValue,
 			})
 		}
 
 		if item.hasFloatValue() {
 			gauges = append(gauges, entities.GaugeItem{
-				MetricName:  name,
+				MetricName:  item.Name,
+				Labels:      item.Labels,
 				MetricValue: *item.FloatValue,
 			})
 		}
+
+		if item.hasHistValue() {
+			histograms = append(histograms, fromHistogram(item.Name, item.Labels, *item.HistValue))
+		}
 	}
 	return listMetricService.MetricData{
-		Counters: counters,
-		Gauges:   gauges,
+		Counters:   counters,
+		Gauges:     gauges,
+		Histograms: histograms,
 	}, nil
 }
 
@@ -177,7 +332,13 @@ func (r *Repository) Load(b []byte) error {
 		if err := item.validate(); err != nil {
 			return err
 		}
-		collection[item.Name] = &item
+		if item.UpdatedAt.IsZero() {
+			item.touch(time.Time{})
+		}
+		if item.Version == 0 {
+			item.Version = 1
+		}
+		collection[item.key()] = &item
 	}
 
 	r.collection = collection
@@ -0,0 +1,35 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/encode"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+func TestRepository_AddUpdateBatchRejected(t *testing.T) {
+	r := New(encode.New())
+
+	_, ok, err := r.AddUpdateBatch(context.Background(), []entities.CounterItem{
+		{MetricType: pkg.MetricTypeCounter, MetricName: "requests", MetricValue: 1},
+	}, nil, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the gauge conflicts with the stored counter, the new counter is not added either
+	_, ok, err = r.AddUpdateBatch(context.Background(), []entities.CounterItem{
+		{MetricType: pkg.MetricTypeCounter, MetricName: "errors", MetricValue: 1},
+	}, []entities.GaugeItem{
+		{MetricType: pkg.MetricTypeGauge, MetricName: "requests", MetricValue: 1},
+	}, nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.Len(t, r.collection, 1)
+	assert.NotContains(t, r.collection, "errors")
+}
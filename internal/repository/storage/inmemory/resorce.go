@@ -1,11 +1,25 @@
 package inmemory
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
 
 type Item struct {
-	Name       string   `json:"name"`
-	IntValue   *int64   `json:"int_value,omitempty"`
-	FloatValue *float64 `json:"float_value,omitempty"`
+	Name       string         `json:"name"`
+	Labels     pkg.Labels     `json:"labels,omitempty"`
+	IntValue   *int64         `json:"int_value,omitempty"`
+	FloatValue *float64       `json:"float_value,omitempty"`
+	HistValue  *pkg.Histogram `json:"hist_value,omitempty"`
+	Version    int64          `json:"version,omitempty"`
+	UpdatedAt  time.Time      `json:"updated_at,omitempty"`
 }
 
 var (
@@ -14,6 +28,21 @@ var (
 	errUndefinedValue = errors.New("value is undefined")
 )
 
+// clone copies the item together with its histogram, which merge changes in place.
+func (x *Item) clone() *Item {
+	c := *x
+	if x.HistValue != nil {
+		h := *x.HistValue
+		h.Bounds, h.Counts = slices.Clone(h.Bounds), slices.Clone(h.Counts)
+		c.HistValue = &h
+	}
+	return &c
+}
+
+func (x Item) key() string {
+	return x.Labels.Series(x.Name)
+}
+
 func (x Item) hasIntValue() bool {
 	return x.IntValue != nil
 }
@@ -22,24 +51,146 @@ func (x Item) hasFloatValue() bool {
 	return x.FloatValue != nil
 }
 
-func (x *Item) add(value int64) {
-	value += *x.IntValue
+func (x Item) hasHistValue() bool {
+	return x.HistValue != nil
+}
+
+func (x Item) metricType() string {
+	switch {
+	case x.hasIntValue():
+		return pkg.MetricTypeCounter
+	case x.hasFloatValue():
+		return pkg.MetricTypeGauge
+	case x.hasHistValue():
+		return pkg.MetricTypeHistogram
+	}
+	return ""
+}
+
+func (x Item) match(filter entities.MetricFilter) bool {
+	if filter.MetricType != "" && filter.MetricType != x.metricType() {
+		return false
+	}
+	if filter.MetricName != "" && (filter.MetricName != x.Name || !filter.Labels.Equal(x.Labels)) {
+		return false
+	}
+	if filter.Prefix != "" && !strings.HasPrefix(x.Name, filter.Prefix) {
+		return false
+	}
+	if !filter.UpdatedBefore.IsZero() && !x.UpdatedAt.Before(filter.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// touch sets the time of the last update and bumps the version, the items
+// written without a time are taken as updated now.
+func (x *Item) touch(ts time.Time) {
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	x.UpdatedAt = ts
+	x.Version++
+}
+
+func (x *Item) add(op string, value int64) {
+	value = pkg.ApplyCounterOp(op, *x.IntValue, value)
 	x.IntValue = &value
 }
 
-func (x *Item) update(value float64) {
+func (x *Item) update(op string, value float64) {
+	if x.UpdatedAt.IsZero() && (op == pkg.OpMax || op == pkg.OpMin) {
+		// a new gauge has no value to compare with yet
+		op = pkg.OpSet
+	}
+	value = pkg.ApplyGaugeOp(op, *x.FloatValue, value)
 	x.FloatValue = &value
 }
 
+func (x *Item) merge(value pkg.Histogram) bool {
+	return x.HistValue.Merge(value)
+}
+
 func (x Item) validate() error {
 	if x.Name == "" {
 		return errEmptyName
 	}
-	if x.hasIntValue() && x.hasFloatValue() {
+
+	values := 0
+	for _, has := range []bool{x.hasIntValue(), x.hasFloatValue(), x.hasHistValue()} {
+		if has {
+			values++
+		}
+	}
+	if values > 1 {
 		return errUndefinedValue
 	}
-	if !x.hasIntValue() && !x.hasFloatValue() {
+	if values == 0 {
 		return errEmptyValue
 	}
+	if x.hasHistValue() && !x.HistValue.Valid() {
+		return errUndefinedValue
+	}
 	return nil
 }
+
+func emptyHistogram(item entities.HistogramItem) *pkg.Histogram {
+	h := pkg.NewHistogram(item.Bounds)
+	return &h
+}
+
+func toHistogram(item entities.HistogramItem) pkg.Histogram {
+	return pkg.Histogram{
+		Bounds: item.Bounds,
+		Counts: item.Counts,
+		Sum:    item.Sum,
+		Count:  item.Count,
+	}
+}
+
+func fromHistogram(name string, labels pkg.Labels, h pkg.Histogram) entities.HistogramItem {
+	return entities.HistogramItem{
+		MetricType: pkg.MetricTypeHistogram,
+		MetricName: name,
+		Labels:     labels,
+		Bounds:     slices.Clone(h.Bounds),
+		Counts:     slices.Clone(h.Counts),
+		Sum:        h.Sum,
+		Count:      h.Count,
+	}
+}
+
+// histogramValue is the value of the histogram in the audit changes.
+type histogramValue struct {
+	Sum   float64 `json:"sum"`
+	Count uint64  `json:"count"`
+}
+
+func newHistogramValue(h pkg.Histogram) histogramValue {
+	return histogramValue{Sum: h.Sum, Count: h.Count}
+}
+
+// changeValue is the previous value of the change, null when the series is new.
+func changeValue(ok bool, value any) json.RawMessage {
+	if !ok {
+		return json.RawMessage("null")
+	}
+	return pkg.MustJSON(value)
+}
+
+func newChange(x *Item, metricType, op string, submitted any, previous json.RawMessage, result any) models.MetricChange {
+	if op == "" && metricType != pkg.MetricTypeHistogram {
+		op = pkg.DefaultOp(metricType)
+	}
+
+	return models.MetricChange{
+		Type:      metricType,
+		Name:      x.Name,
+		Labels:    x.Labels,
+		Op:        op,
+		Submitted: pkg.MustJSON(submitted),
+		Previous:  previous,
+		Result:    pkg.MustJSON(result),
+		Version:   x.Version,
+	}
+}
@@ -2,94 +2,148 @@ package pg
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"time"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/config/db"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/inmemory"
 	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
+// errCodeVersionConflict is raised by the upsert functions when the stored
+// version differs from the expected one.
+const errCodeVersionConflict = "MV409"
+
+// errCodeBoundsMismatch is raised by the histogram upsert when the stored
+// bucket bounds differ from the submitted ones.
+const errCodeBoundsMismatch = "MH400"
+
+// OutboxRepository keeps the outbox items of the batches while the database is unavailable.
+type OutboxRepository interface {
+	OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) (err error)
+}
+
 type Repository struct {
 	conn     *db.PGConnect
 	isAlive  bool
 	inmemory *inmemory.Repository
+	outbox   OutboxRepository
 }
 
-func New(conn *db.PGConnect, inmemory *inmemory.Repository) *Repository {
+func New(conn *db.PGConnect, inmemory *inmemory.Repository, outbox OutboxRepository) *Repository {
 	return &Repository{
 		conn:     conn,
 		isAlive:  checkAlive(conn),
 		inmemory: inmemory,
+		outbox:   outbox,
 	}
 }
 
 func (r *Repository) AddUpdateBatch(
-	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, outboxes []entities.Outbox, outboxSegment string,
+	ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	outboxes []entities.Outbox, outboxSegment string,
 ) (ok bool, err error) {
 	if !r.isAlive {
-		return r.inmemory.AddUpdateBatch(ctx, counters, gauges)
+		// the outbox items are added first, the metrics are not updated without them
+		_, ok, err := r.inmemory.AddUpdateBatchCommit(ctx, counters, gauges, histograms,
+			func(changes []models.MetricChange) error {
+				if len(outboxes) == 0 {
+					return nil
+				}
+				return r.outbox.OutboxAdd(ctx, withChanges(outboxes, changes), outboxSegment)
+			},
+		)
+		return ok, err
 	}
 
 	var updatedNames []string
-	count := len(counters) + len(gauges)
+	count := len(counters) + len(gauges) + len(histograms)
 
 	err = r.conn.QueryWithOneResultJSON(ctx,
 		&updatedNames,
-		"select metric.metrics_upsert(_counter_items => $1, _gauge_items => $2, _outbox_items => $3, _outbox_segment => $4)",
-		counters, gauges, outboxes, outboxSegment,
+		"select metric.metrics_upsert(_counter_items => $1, _gauge_items => $2, _histogram_items => $3, _outbox_items => $4, _outbox_segment => $5)",
+		counters, gauges, histograms, outboxes, outboxSegment,
 	)
+	// the whole batch is rolled back, the same way the in-memory storage rejects it
+	if db.PgErrorCode(err) == errCodeBoundsMismatch {
+		return false, nil
+	}
 
-	return len(updatedNames) == count, err
+	return len(updatedNames) == count, versionConflict(err)
 }
 
-func (r *Repository) Add(ctx context.Context, item entities.CounterItem) (ok bool, err error) {
+// Delete removes the matching metrics and adds the outbox items in the same
+// transaction, the audit events get the deleted series in their metrics.
+func (r *Repository) Delete(
+	ctx context.Context, filter entities.MetricFilter, outboxes []entities.Outbox, outboxSegment string,
+) (deleted []entities.MetricKey, err error) {
 	if !r.isAlive {
-		return r.inmemory.Add(ctx, item)
+		// the outbox items are added first, the metrics are not deleted without them
+		return r.inmemory.DeleteCommit(ctx, filter, func(deleted []entities.MetricKey) error {
+			if len(outboxes) == 0 {
+				return nil
+			}
+			return r.outbox.OutboxAdd(ctx, withDeleted(outboxes, deleted), outboxSegment)
+		})
 	}
 
-	var updatedNames []string
+	var updatedBefore *time.Time
+	if !filter.UpdatedBefore.IsZero() {
+		updatedBefore = &filter.UpdatedBefore
+	}
 
-	err = r.conn.QueryWithOneResultJSON(
-		ctx,
-		&updatedNames,
-		"select metric.counters_upsert(_items => $1)",
-		[]entities.CounterItem{item},
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&deleted,
+		`select metric.metrics_delete(
+			_metric_type => $1, _metric_name => $2, _labels => $3, _prefix => $4, _updated_before => $5,
+			_outbox_items => $6, _outbox_segment => $7
+		)`,
+		nullIfEmpty(filter.MetricType), nullIfEmpty(filter.MetricName), filter.Labels, nullIfEmpty(filter.Prefix), updatedBefore,
+		outboxes, outboxSegment,
 	)
-
-	return len(updatedNames) > 0, err
+	return deleted, err
 }
 
-func (r *Repository) Update(ctx context.Context, item entities.GaugeItem) (ok bool, err error) {
+func (r *Repository) GetCounter(ctx context.Context, name string, labels pkg.Labels) (*entities.CounterItem, bool, error) {
 	if !r.isAlive {
-		return r.inmemory.Update(ctx, item)
+		return r.inmemory.GetCounter(ctx, name, labels)
 	}
 
-	var updatedNames []string
+	var items []entities.CounterItem
 
-	err = r.conn.QueryWithOneResultJSON(
+	err := r.conn.QueryWithOneResultJSON(
 		ctx,
-		&updatedNames,
-		"select metric.gauges_upsert(_items => $1)",
-		[]entities.GaugeItem{item},
+		&items,
+		"select metric.counters_get(_metric_name => $1, _labels => $2)",
+		name, labels,
 	)
+	if err != nil {
+		return nil, false, err
+	}
 
-	return len(updatedNames) > 0, err
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+
+	return &items[0], true, nil
 }
 
-func (r *Repository) GetCounter(ctx context.Context, name string) (*entities.CounterItem, bool, error) {
+func (r *Repository) GetGauge(ctx context.Context, name string, labels pkg.Labels) (*entities.GaugeItem, bool, error) {
 	if !r.isAlive {
-		return r.inmemory.GetCounter(ctx, name)
+		return r.inmemory.GetGauge(ctx, name, labels)
 	}
 
-	var items []entities.CounterItem
+	var items []entities.GaugeItem
 
 	err := r.conn.QueryWithOneResultJSON(
 		ctx,
 		&items,
-		"select metric.counters_list_by_metric_names(_metric_names => $1)",
-		[]string{name},
+		"select metric.gauges_get(_metric_name => $1, _labels => $2)",
+		name, labels,
 	)
 	if err != nil {
 		return nil, false, err
@@ -102,18 +156,18 @@ func (r *Repository) GetCounter(ctx context.Context, name string) (*entities.Cou
 	return &items[0], true, nil
 }
 
-func (r *Repository) GetGauge(ctx context.Context, name string) (*entities.GaugeItem, bool, error) {
+func (r *Repository) GetHistogram(ctx context.Context, name string, labels pkg.Labels) (*entities.HistogramItem, bool, error) {
 	if !r.isAlive {
-		return r.inmemory.GetGauge(ctx, name)
+		return r.inmemory.GetHistogram(ctx, name, labels)
 	}
 
-	var items []entities.GaugeItem
+	var items []entities.HistogramItem
 
 	err := r.conn.QueryWithOneResultJSON(
 		ctx,
 		&items,
-		"select metric.gauges_list_by_metric_names(_metric_names => $1)",
-		[]string{name},
+		"select metric.histograms_get(_metric_name => $1, _labels => $2)",
+		name, labels,
 	)
 	if err != nil {
 		return nil, false, err
@@ -139,6 +193,75 @@ func (r *Repository) List(ctx context.Context) (resp listMetricService.MetricDat
 	return resp, err
 }
 
+func (r *Repository) History(
+	ctx context.Context, metricType, metricName string, labels pkg.Labels, from, to time.Time, step time.Duration,
+) (resp []entities.HistoryPoint, err error) {
+	if !r.isAlive {
+		return r.inmemory.History(ctx, metricType, metricName, labels, from, to, step)
+	}
+
+	err = r.conn.QueryWithOneResultJSON(
+		ctx,
+		&resp,
+		"select metric.samples_downsample(_metric_type => $1, _metric_name => $2, _labels => $3, _from => $4, _to => $5, _step => make_interval(secs => $6))",
+		metricType, metricName, labels, from, to, step.Seconds(),
+	)
+	return resp, err
+}
+
+// DropHistory drops the daily partitions of the samples which end before the
+// time and returns their names. The in-memory history is capped already.
+func (r *Repository) DropHistory(ctx context.Context, before time.Time) (dropped []string, err error) {
+	if !r.isAlive {
+		return nil, nil
+	}
+
+	err = r.conn.QueryWithOneResultJSON(ctx,
+		&dropped,
+		"select metric.samples_drop_expired(_before => $1)",
+		before,
+	)
+	return dropped, err
+}
+
+// withChanges puts the changes of the batch into its audit events the same way
+// metric.metrics_upsert does.
+func withChanges(outboxes []entities.Outbox, changes []models.MetricChange) []entities.Outbox {
+	resp := make([]entities.Outbox, 0, len(outboxes))
+	for _, item := range outboxes {
+		if item.Destination == string(models.AuditOutboxDestination) {
+			var payload map[string]json.RawMessage
+			if err := json.Unmarshal(item.Payload, &payload); err == nil {
+				payload["changes"] = pkg.MustJSON(changes)
+				item.Payload = pkg.MustJSON(payload)
+			}
+		}
+		resp = append(resp, item)
+	}
+	return resp
+}
+
+// withDeleted fills the metrics of the audit events in, the way metrics_delete does.
+func withDeleted(outboxes []entities.Outbox, deleted []entities.MetricKey) []entities.Outbox {
+	series := make([]string, 0, len(deleted))
+	for _, key := range deleted {
+		series = append(series, key.Labels.Series(key.MetricName))
+	}
+
+	resp := make([]entities.Outbox, 0, len(outboxes))
+	for _, item := range outboxes {
+		if item.Destination == string(models.AuditOutboxDestination) {
+			var payload map[string]json.RawMessage
+			if err := json.Unmarshal(item.Payload, &payload); err == nil {
+				payload["metrics"] = pkg.MustJSON(series)
+				item.Payload = pkg.MustJSON(payload)
+			}
+		}
+		resp = append(resp, item)
+	}
+	return resp
+}
+
 func checkAlive(conn *db.PGConnect) bool {
 	if conn == nil {
 		return false
@@ -154,3 +277,18 @@ func checkAlive(conn *db.PGConnect) bool {
 
 	return true
 }
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// versionConflict replaces the conflict raised by the database with entities.ErrVersionConflict.
+func versionConflict(err error) error {
+	if db.PgErrorCode(err) == errCodeVersionConflict {
+		return entities.ErrVersionConflict
+	}
+	return err
+}
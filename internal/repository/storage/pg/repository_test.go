@@ -0,0 +1,108 @@
+package pg_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/encode"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/inmemory"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/pg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type outboxRepositoryMock struct {
+	items []entities.Outbox
+	err   error
+}
+
+func (m *outboxRepositoryMock) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.items = append(m.items, items...)
+	return nil
+}
+
+func TestRepository_AddUpdateBatchChanges(t *testing.T) {
+	outboxRepo := &outboxRepositoryMock{}
+	repo := pg.New(nil, inmemory.New(encode.New()), outboxRepo)
+
+	update := func(delta int64, value float64) {
+		ok, err := repo.AddUpdateBatch(context.Background(),
+			[]entities.CounterItem{{MetricType: pkg.MetricTypeCounter, MetricName: "PollCount", MetricValue: delta}},
+			[]entities.GaugeItem{{MetricType: pkg.MetricTypeGauge, MetricName: "Alloc", Op: pkg.OpMax, MetricValue: value}},
+			nil,
+			[]entities.Outbox{
+				{Destination: string(models.AuditOutboxDestination), Payload: pkg.MustJSON(models.UpdateEvent{Principal: "hmac"})},
+				{Destination: string(models.NotifyOutboxDestination), Payload: json.RawMessage(`{}`)},
+			},
+			"",
+		)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	update(2, 5)
+	update(3, 4)
+
+	require.Len(t, outboxRepo.items, 4)
+	assert.JSONEq(t, `{}`, string(outboxRepo.items[1].Payload))
+
+	var first, second models.UpdateEvent
+	require.NoError(t, json.Unmarshal(outboxRepo.items[0].Payload, &first))
+	require.NoError(t, json.Unmarshal(outboxRepo.items[2].Payload, &second))
+
+	assert.Equal(t, "hmac", second.Principal)
+	require.Len(t, first.Changes, 2)
+	require.Len(t, second.Changes, 2)
+
+	assert.Equal(t, "PollCount", first.Changes[0].Name)
+	assert.Equal(t, "inc", first.Changes[0].Op)
+	assert.JSONEq(t, `null`, string(first.Changes[0].Previous))
+	assert.JSONEq(t, `2`, string(first.Changes[0].Result))
+
+	assert.JSONEq(t, `3`, string(second.Changes[0].Submitted))
+	assert.JSONEq(t, `2`, string(second.Changes[0].Previous))
+	assert.JSONEq(t, `5`, string(second.Changes[0].Result))
+	assert.EqualValues(t, 2, second.Changes[0].Version)
+
+	assert.Equal(t, "Alloc", second.Changes[1].Name)
+	assert.JSONEq(t, `4`, string(second.Changes[1].Submitted))
+	assert.JSONEq(t, `5`, string(second.Changes[1].Previous))
+	assert.JSONEq(t, `5`, string(second.Changes[1].Result))
+}
+
+func TestRepository_OutboxFailure(t *testing.T) {
+	outboxRepo := &outboxRepositoryMock{}
+	storage := inmemory.New(encode.New())
+	repo := pg.New(nil, storage, outboxRepo)
+
+	outboxes := []entities.Outbox{{Destination: string(models.AuditOutboxDestination), Payload: pkg.MustJSON(models.UpdateEvent{})}}
+	counters := []entities.CounterItem{{MetricType: pkg.MetricTypeCounter, MetricName: "PollCount", MetricValue: 2}}
+
+	ok, err := repo.AddUpdateBatch(context.Background(), counters, nil, nil, outboxes, "")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	outboxRepo.err = errors.New("journal is full")
+
+	// neither the update nor the delete is applied without its audit event
+	_, err = repo.AddUpdateBatch(context.Background(), counters, nil, nil, outboxes, "")
+	require.Error(t, err)
+
+	_, err = repo.Delete(context.Background(), entities.MetricFilter{MetricName: "PollCount"}, outboxes, "")
+	require.Error(t, err)
+
+	item, ok, err := storage.GetCounter(context.Background(), "PollCount", nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(2), item.MetricValue)
+	assert.EqualValues(t, 1, item.Version)
+}
@@ -0,0 +1,56 @@
+package self
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
+)
+
+// Repository exposes the operational metrics of the server recorded
+// into the registry the way the metric storages expose the stored ones.
+type Repository struct {
+	registry *selfmetrics.Registry
+}
+
+func New(registry *selfmetrics.Registry) *Repository {
+	return &Repository{
+		registry: registry,
+	}
+}
+
+func (r *Repository) List(ctx context.Context) (resp listMetricService.MetricData, err error) {
+	snapshot := r.registry.Snapshot()
+
+	for _, x := range snapshot.Counters {
+		resp.Counters = append(resp.Counters, entities.CounterItem{
+			MetricType:  pkg.MetricTypeCounter,
+			MetricName:  x.Name,
+			Labels:      x.Labels,
+			MetricValue: x.Value,
+		})
+	}
+	for _, x := range snapshot.Gauges {
+		resp.Gauges = append(resp.Gauges, entities.GaugeItem{
+			MetricType:  pkg.MetricTypeGauge,
+			MetricName:  x.Name,
+			Labels:      x.Labels,
+			MetricValue: x.Value,
+		})
+	}
+	for _, x := range snapshot.Histograms {
+		resp.Histograms = append(resp.Histograms, entities.HistogramItem{
+			MetricType: pkg.MetricTypeHistogram,
+			MetricName: x.Name,
+			Labels:     x.Labels,
+			Bounds:     x.Value.Bounds,
+			Counts:     x.Value.Counts,
+			Sum:        x.Value.Sum,
+			Count:      x.Value.Count,
+		})
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,5 @@
+package v0
+
+type Config struct {
+	Rules []string `env:"RULES" envSeparator:";" json:"rules"`
+}
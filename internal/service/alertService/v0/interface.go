@@ -0,0 +1,16 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+)
+
+type MetricRepository interface {
+	List(ctx context.Context) (resp listMetricService.MetricData, err error)
+}
+
+type OutboxRepository interface {
+	OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) (err error)
+}
@@ -0,0 +1,105 @@
+package v0
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+// ruleRe matches `name > 1`, `name{host=a} <= 0.5 for 2m` and `rate(name) == 0 for 5m`.
+var ruleRe = regexp.MustCompile(
+	`^(?:rate\(\s*([^\s(){}<>=!]+)\s*(\{[^}]*\})?\s*\)|([^\s(){}<>=!]+)\s*(\{[^}]*\})?)` +
+		`\s*(>=|<=|==|!=|>|<)\s*(\S+)(?:\s+for\s+(\S+))?$`,
+)
+
+type rule struct {
+	expr      string
+	name      string
+	labels    pkg.Labels
+	rate      bool
+	op        string
+	threshold float64
+	duration  time.Duration
+}
+
+func parseRule(expr string) (rule, error) {
+	expr = strings.TrimSpace(expr)
+
+	m := ruleRe.FindStringSubmatch(expr)
+	if m == nil {
+		return rule{}, fmt.Errorf("invalid rule %q", expr)
+	}
+
+	r := rule{expr: expr, name: m[3], op: m[5]}
+	selector := m[4]
+	if m[1] != "" {
+		r.name, selector, r.rate = m[1], m[2], true
+	}
+
+	labels, err := pkg.ParseLabels(strings.TrimSuffix(strings.TrimPrefix(selector, "{"), "}"))
+	if err != nil {
+		return rule{}, fmt.Errorf("invalid rule %q: %w", expr, err)
+	}
+	r.labels = labels
+
+	if r.threshold, err = strconv.ParseFloat(m[6], 64); err != nil {
+		return rule{}, fmt.Errorf("invalid rule %q: threshold %q", expr, m[6])
+	}
+
+	if m[7] != "" {
+		if r.duration, err = time.ParseDuration(m[7]); err != nil || r.duration < 0 {
+			return rule{}, fmt.Errorf("invalid rule %q: duration %q", expr, m[7])
+		}
+	}
+
+	return r, nil
+}
+
+func (r rule) match(name string, labels pkg.Labels) bool {
+	return r.name == name && labels.Match(r.labels)
+}
+
+func (r rule) holds(value float64) bool {
+	switch r.op {
+	case ">":
+		return value > r.threshold
+	case ">=":
+		return value >= r.threshold
+	case "<":
+		return value < r.threshold
+	case "<=":
+		return value <= r.threshold
+	case "==":
+		return value == r.threshold
+	case "!=":
+		return value != r.threshold
+	}
+	return false
+}
+
+// series is a single stored metric the rules are evaluated against.
+type series struct {
+	metricType string
+	name       string
+	labels     pkg.Labels
+	value      float64
+}
+
+func (s series) key() string {
+	return s.metricType + ":" + s.labels.Series(s.name)
+}
+
+type sample struct {
+	value float64
+	ts    time.Time
+}
+
+type alert struct {
+	series      series
+	activeSince time.Time
+	firing      bool
+}
@@ -0,0 +1,217 @@
+package v0
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const (
+	segment = ""
+
+	// maxUnsent bounds the events kept while the outbox is unavailable.
+	maxUnsent = 1000
+)
+
+type Service struct {
+	rules            []rule
+	metricRepository MetricRepository
+	outboxRepo       OutboxRepository
+	now              func() time.Time
+
+	mx      sync.Mutex
+	samples map[string]sample
+	alerts  []map[string]*alert
+	unsent  []models.AlertEvent
+}
+
+func New(config Config, metricRepo MetricRepository, outboxRepo OutboxRepository) *Service {
+	rules := make([]rule, 0, len(config.Rules))
+	for _, expr := range config.Rules {
+		r, err := parseRule(expr)
+		if err != nil {
+			log.Printf("alert rule not ok, %s\n", err.Error())
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	alerts := make([]map[string]*alert, len(rules))
+	for i := range alerts {
+		alerts[i] = make(map[string]*alert)
+	}
+
+	return &Service{
+		rules:            rules,
+		metricRepository: metricRepo,
+		outboxRepo:       outboxRepo,
+		now:              time.Now,
+		samples:          make(map[string]sample),
+		alerts:           alerts,
+	}
+}
+
+// Do evaluates the rules against the stored counters and gauges
+// and sends the firing and resolved events to the auditors and the notifier.
+func (srv *Service) Do(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "alertService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	if len(srv.rules) == 0 {
+		return nil
+	}
+
+	data, err := srv.metricRepository.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	srv.mx.Lock()
+	defer srv.mx.Unlock()
+
+	ts := srv.now()
+
+	current := make([]series, 0, len(data.Counters)+len(data.Gauges))
+	for _, item := range data.Counters {
+		current = append(current, series{
+			metricType: pkg.MetricTypeCounter, name: item.MetricName, labels: item.Labels, value: float64(item.MetricValue),
+		})
+	}
+	for _, item := range data.Gauges {
+		current = append(current, series{
+			metricType: pkg.MetricTypeGauge, name: item.MetricName, labels: item.Labels, value: item.MetricValue,
+		})
+	}
+
+	events := srv.unsent
+	for i, r := range srv.rules {
+		events = append(events, srv.evaluate(ts, r, srv.alerts[i], current)...)
+	}
+
+	samples := make(map[string]sample, len(current))
+	for _, s := range current {
+		samples[s.key()] = sample{value: s.value, ts: ts}
+	}
+	srv.samples = samples
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	outboxes := make([]entities.Outbox, 0, 2*len(events))
+	for _, event := range events {
+		payload := pkg.MustJSON(event)
+		outboxes = append(outboxes,
+			entities.Outbox{Destination: string(models.AuditOutboxDestination), Segment: segment, Payload: payload},
+			entities.Outbox{Destination: string(models.NotifyOutboxDestination), Segment: segment, Payload: payload},
+		)
+	}
+
+	if err := srv.outboxRepo.OutboxAdd(ctx, outboxes, segment); err != nil {
+		if len(events) > maxUnsent {
+			events = events[len(events)-maxUnsent:]
+		}
+		srv.unsent = events
+		return fmt.Errorf("alert events not sent: %w", err)
+	}
+	srv.unsent = nil
+
+	return nil
+}
+
+func (srv *Service) evaluate(ts time.Time, r rule, alerts map[string]*alert, current []series) []models.AlertEvent {
+	var events []models.AlertEvent
+
+	seen := make(map[string]struct{})
+	for _, s := range current {
+		if !r.match(s.name, s.labels) {
+			continue
+		}
+
+		key := s.key()
+		seen[key] = struct{}{}
+
+		value, ok := srv.value(ts, r, s)
+		if !ok {
+			continue
+		}
+
+		a, active := alerts[key]
+		if !r.holds(value) {
+			if active {
+				if a.firing {
+					events = append(events, newEvent(ts, models.AlertResolved, r, a, value))
+				}
+				delete(alerts, key)
+			}
+			continue
+		}
+
+		if !active {
+			a = &alert{activeSince: ts}
+			alerts[key] = a
+		}
+		a.series = s
+
+		if !a.firing && ts.Sub(a.activeSince) >= r.duration {
+			a.firing = true
+			events = append(events, newEvent(ts, models.AlertFiring, r, a, value))
+		}
+	}
+
+	// the series is gone, so is the alert
+	for key, a := range alerts {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if a.firing {
+			events = append(events, newEvent(ts, models.AlertResolved, r, a, a.series.value))
+		}
+		delete(alerts, key)
+	}
+
+	return events
+}
+
+// value returns the value the rule compares, it is unknown for the first
+// sample of a rate.
+func (srv *Service) value(ts time.Time, r rule, s series) (float64, bool) {
+	if !r.rate {
+		return s.value, true
+	}
+
+	prev, ok := srv.samples[s.key()]
+	if !ok || !ts.After(prev.ts) {
+		return 0, false
+	}
+
+	delta := s.value - prev.value
+	if delta < 0 {
+		// the counter was reset
+		delta = s.value
+	}
+
+	return delta / ts.Sub(prev.ts).Seconds(), true
+}
+
+func newEvent(ts time.Time, status models.AlertStatus, r rule, a *alert, value float64) models.AlertEvent {
+	return models.AlertEvent{
+		TS:          ts,
+		Action:      models.AuditActionAlert,
+		Status:      status,
+		Rule:        r.expr,
+		MetricType:  a.series.metricType,
+		Metric:      a.series.labels.Series(a.series.name),
+		MetricName:  a.series.name,
+		Labels:      a.series.labels,
+		Value:       value,
+		ActiveSince: a.activeSince,
+	}
+}
@@ -0,0 +1,152 @@
+package v0
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type metricRepositoryMock struct {
+	data listMetricService.MetricData
+}
+
+func (m *metricRepositoryMock) List(ctx context.Context) (listMetricService.MetricData, error) {
+	return m.data, nil
+}
+
+type outboxRepositoryMock struct {
+	err   error
+	items []entities.Outbox
+}
+
+func (m *outboxRepositoryMock) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.items = append(m.items, items...)
+	return nil
+}
+
+func (m *outboxRepositoryMock) events(t *testing.T) []models.AlertEvent {
+	var events []models.AlertEvent
+	for _, item := range m.items {
+		if item.Destination != string(models.AuditOutboxDestination) {
+			continue
+		}
+		var event models.AlertEvent
+		require.NoError(t, json.Unmarshal(item.Payload, &event))
+		events = append(events, event)
+	}
+	m.items = nil
+	return events
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    rule
+		wantErr bool
+	}{
+		{
+			name: "threshold with duration",
+			expr: "HeapAlloc > 1e9 for 2m",
+			want: rule{expr: "HeapAlloc > 1e9 for 2m", name: "HeapAlloc", op: ">", threshold: 1e9, duration: 2 * time.Minute},
+		},
+		{
+			name: "rate",
+			expr: " rate(PollCount) == 0 for 5m ",
+			want: rule{expr: "rate(PollCount) == 0 for 5m", name: "PollCount", rate: true, op: "==", duration: 5 * time.Minute},
+		},
+		{
+			name: "labels",
+			expr: "latency{host=a}<=0.5",
+			want: rule{expr: "latency{host=a}<=0.5", name: "latency", labels: pkg.Labels{"host": "a"}, op: "<=", threshold: 0.5},
+		},
+		{name: "no operator", expr: "HeapAlloc 1", wantErr: true},
+		{name: "invalid threshold", expr: "HeapAlloc > many", wantErr: true},
+		{name: "invalid duration", expr: "HeapAlloc > 1 for ever", wantErr: true},
+		{name: "invalid labels", expr: "HeapAlloc{__name__=a} > 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRule(tt.expr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_Do(t *testing.T) {
+	metricRepo := &metricRepositoryMock{}
+	outboxRepo := &outboxRepositoryMock{}
+
+	srv := New(Config{Rules: []string{"HeapAlloc > 100 for 1m", "rate(PollCount) == 0"}}, metricRepo, outboxRepo)
+
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.now = func() time.Time { return ts }
+
+	step := func(heapAlloc float64, pollCount int64, after time.Duration) []models.AlertEvent {
+		ts = ts.Add(after)
+		metricRepo.data = listMetricService.MetricData{
+			Counters: []entities.CounterItem{{MetricName: "PollCount", MetricValue: pollCount}},
+			Gauges:   []entities.GaugeItem{{MetricName: "HeapAlloc", MetricValue: heapAlloc}},
+		}
+		require.NoError(t, srv.Do(context.Background()))
+		return outboxRepo.events(t)
+	}
+
+	assert.Empty(t, step(200, 1, 0), "pending alert and no rate yet")
+	assert.Empty(t, step(200, 2, 30*time.Second), "pending alert")
+
+	events := step(200, 2, 30*time.Second)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.AlertFiring, events[0].Status)
+	assert.Equal(t, "HeapAlloc", events[0].Metric)
+	assert.Equal(t, float64(200), events[0].Value)
+	assert.Equal(t, ts.Add(-time.Minute), events[0].ActiveSince.UTC())
+	assert.Equal(t, models.AlertFiring, events[1].Status)
+	assert.Equal(t, "rate(PollCount) == 0", events[1].Rule)
+
+	assert.Empty(t, step(300, 2, time.Second), "already firing")
+
+	events = step(50, 3, time.Second)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.AlertResolved, events[0].Status)
+	assert.Equal(t, "HeapAlloc > 100 for 1m", events[0].Rule)
+	assert.Equal(t, models.AlertResolved, events[1].Status)
+	assert.Equal(t, float64(1), events[1].Value)
+}
+
+func TestService_Do_Unsent(t *testing.T) {
+	metricRepo := &metricRepositoryMock{data: listMetricService.MetricData{
+		Gauges: []entities.GaugeItem{{MetricName: "HeapAlloc", MetricValue: 200}},
+	}}
+	outboxRepo := &outboxRepositoryMock{err: errors.New("db unavailable")}
+
+	srv := New(Config{Rules: []string{"HeapAlloc > 100"}}, metricRepo, outboxRepo)
+
+	assert.Error(t, srv.Do(context.Background()))
+
+	outboxRepo.err = nil
+	require.NoError(t, srv.Do(context.Background()))
+
+	events := outboxRepo.events(t)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.AlertFiring, events[0].Status)
+}
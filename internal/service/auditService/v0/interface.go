@@ -0,0 +1,21 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+)
+
+type OutboxRepository interface {
+	OutboxFanout(ctx context.Context, items []entities.Outbox, okIds []entities.OutboxID, segment string) (err error)
+	OutboxGetNext(ctx context.Context, destination models.OutboxDestination, segment string, limit int) (resp []entities.Outbox, err error)
+	OutboxCommit(ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string) (err error)
+}
+
+type SinkRepository interface {
+	SinkOpened(sink string) bool
+	SinkWriteBatch(ctx context.Context, sink string, payloads [][]byte) []error
+	SinkClassify(sink string, err error) backoff.ErrorClassification
+}
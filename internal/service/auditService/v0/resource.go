@@ -0,0 +1,64 @@
+package v0
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+)
+
+const defaultBatchSize = 100
+
+type sink struct {
+	name      string
+	filter    models.AuditFilter
+	batchSize int
+}
+
+func newSink(s models.AuditSink) (sink, error) {
+	if s.Name == "" {
+		return sink{}, errors.New("sink name is not set")
+	}
+	if s.BatchSize < 0 {
+		return sink{}, errors.New("sink batch size is negative")
+	}
+
+	return sink{
+		name:      s.Name,
+		filter:    s.Filter,
+		batchSize: cmp.Or(s.BatchSize, defaultBatchSize),
+	}, nil
+}
+
+// envelope holds the fields of the audit events the filters match against.
+type envelope struct {
+	Action     string   `json:"action"`
+	Metrics    []string `json:"metrics"`
+	MetricName string   `json:"metric_name"`
+}
+
+// match reports whether the event passes the filter, the events written
+// before the actions were introduced are updates.
+func match(filter models.AuditFilter, event envelope) bool {
+	if len(filter.Actions) > 0 && !slices.Contains(filter.Actions, cmp.Or(event.Action, models.AuditActionUpdate)) {
+		return false
+	}
+	if len(filter.Metrics) == 0 {
+		return true
+	}
+
+	names := event.Metrics
+	if event.MetricName != "" {
+		names = append(slices.Clip(names), event.MetricName)
+	}
+	for _, name := range names {
+		for _, prefix := range filter.Metrics {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
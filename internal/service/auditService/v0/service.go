@@ -0,0 +1,186 @@
+package v0
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
+)
+
+type Service struct {
+	sinks      []sink
+	outboxRepo OutboxRepository
+	sinkRepo   SinkRepository
+}
+
+func New(sinks []models.AuditSink, outboxRepo OutboxRepository, sinkRepo SinkRepository) *Service {
+	parsed := make([]sink, 0, len(sinks))
+	names := make(map[string]struct{}, len(sinks))
+	for _, s := range sinks {
+		if _, ok := names[s.Name]; ok {
+			log.Printf("audit sink not ok, duplicate name %q\n", s.Name)
+			continue
+		}
+		snk, err := newSink(s)
+		if err != nil {
+			log.Printf("audit sink not ok, %s\n", err.Error())
+			continue
+		}
+		if !sinkRepo.SinkOpened(s.Name) {
+			// the events routed to it could never be delivered
+			log.Printf("audit sink not ok, %q is not opened\n", s.Name)
+			continue
+		}
+		names[s.Name] = struct{}{}
+		parsed = append(parsed, snk)
+	}
+
+	return &Service{
+		sinks:      parsed,
+		outboxRepo: outboxRepo,
+		sinkRepo:   sinkRepo,
+	}
+}
+
+// Sinks returns the names of the sinks the events are delivered to.
+func (srv *Service) Sinks() []string {
+	names := make([]string, 0, len(srv.sinks))
+	for _, s := range srv.sinks {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Do fans the audit events of the outbox segment out to every sink whose filter
+// matches them, the events are dropped when no sink is configured.
+func (srv *Service) Do(ctx context.Context, segment string) (err error) {
+	ctx, span := tracing.Start(ctx, "auditService.Do", attribute.String("outbox.segment", segment))
+	defer func() { tracing.End(span, err) }()
+
+	items, err := srv.outboxRepo.OutboxGetNext(ctx, models.AuditOutboxDestination, segment, defaultBatchSize)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	completed := make([]entities.OutboxID, 0, len(items))
+	outboxes := make([]entities.Outbox, 0, len(items)*len(srv.sinks))
+	for _, item := range items {
+		completed = append(completed, item.ID)
+
+		var event envelope
+		if err := json.Unmarshal(item.Payload, &event); err != nil {
+			log.Printf("outbox payload not ok {dest=%v, id=%v, err=%v}\n", models.AuditOutboxDestination, item.ID, err.Error())
+			continue
+		}
+		for _, s := range srv.sinks {
+			if !match(s.filter, event) {
+				continue
+			}
+			outboxes = append(outboxes, entities.Outbox{
+				Destination: string(models.AuditSinkDestination(s.name)),
+				Segment:     segment,
+				Payload:     item.Payload,
+			})
+		}
+	}
+
+	// the events are queued for the sinks and leave the audit outbox together,
+	// so a crash in between does not deliver them twice
+	return srv.outboxRepo.OutboxFanout(ctx, outboxes, completed, segment)
+}
+
+// Deliver writes the next events of the outbox segment queued for the sink.
+func (srv *Service) Deliver(ctx context.Context, name string, segment string) (err error) {
+	ctx, span := tracing.Start(ctx, "auditService.Deliver",
+		attribute.String("audit.sink", name),
+		attribute.String("outbox.segment", segment),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	var s *sink
+	for i := range srv.sinks {
+		if srv.sinks[i].name == name {
+			s = &srv.sinks[i]
+			break
+		}
+	}
+	if s == nil {
+		return fmt.Errorf("unknown sink %q", name)
+	}
+
+	destination := models.AuditSinkDestination(name)
+
+	items, err := srv.outboxRepo.OutboxGetNext(ctx, destination, segment, s.batchSize)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	payloads := make([][]byte, 0, len(items))
+	for _, item := range items {
+		payloads = append(payloads, item.Payload)
+	}
+	errs := srv.deliver(ctx, name, payloads)
+
+	completed, failed := make([]entities.OutboxID, 0, len(items)), make([]entities.OutboxFailure, 0, len(items))
+	for i, item := range items {
+		if err := errs[i]; err != nil {
+			// the permanent failures are dead-lettered at once, redelivery cannot fix them
+			permanent := srv.sinkRepo.SinkClassify(name, err) == backoff.NonRetriable
+			log.Printf("outbox failure {dest=%v, id=%v, permanent=%v, err=%v}\n", destination, item.ID, permanent, err.Error())
+			failed = append(failed, entities.OutboxFailure{ID: item.ID, Error: err.Error(), Permanent: permanent})
+		} else {
+			completed = append(completed, item.ID)
+		}
+	}
+
+	if err := srv.outboxRepo.OutboxCommit(ctx, completed, failed, segment); err != nil {
+		log.Printf("outbox commit failure {dest=%v, err=%v}\n", destination, err.Error())
+	}
+
+	return nil
+}
+
+// deliver writes the events to the sink at once, so that the sinks which
+// support it batch them, and returns the error of every event.
+func (srv *Service) deliver(ctx context.Context, name string, payloads [][]byte) []error {
+	ctx, span := tracing.Start(ctx, "auditService.deliver",
+		attribute.String("audit.sink", name),
+		attribute.Int("audit.events", len(payloads)),
+	)
+
+	var first error
+	errs := srv.sinkRepo.SinkWriteBatch(ctx, name, payloads)
+	for _, err := range errs {
+		selfmetrics.Inc("audit_deliveries_total", pkg.Labels{
+			"destination": name,
+			"result":      deliveryResult(err),
+		})
+		first = cmp.Or(first, err)
+	}
+
+	tracing.End(span, first)
+	return errs
+}
+
+func deliveryResult(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "ok"
+}
@@ -0,0 +1,173 @@
+package v0
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/backoff"
+)
+
+type outboxRepositoryMock struct {
+	pending   map[models.OutboxDestination][]entities.Outbox
+	limit     int
+	added     []entities.Outbox
+	completed []entities.OutboxID
+	failed    []entities.OutboxFailure
+}
+
+func (m *outboxRepositoryMock) OutboxFanout(
+	ctx context.Context, items []entities.Outbox, okIds []entities.OutboxID, segment string,
+) error {
+	m.added = append(m.added, items...)
+	m.completed = append(m.completed, okIds...)
+	return nil
+}
+
+func (m *outboxRepositoryMock) OutboxGetNext(
+	ctx context.Context, destination models.OutboxDestination, segment string, limit int,
+) ([]entities.Outbox, error) {
+	items := m.pending[destination]
+	delete(m.pending, destination)
+	m.limit = limit
+	return items, nil
+}
+
+func (m *outboxRepositoryMock) OutboxCommit(
+	ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string,
+) error {
+	m.completed = append(m.completed, okIds...)
+	m.failed = append(m.failed, failed...)
+	return nil
+}
+
+type sinkRepositoryMock struct {
+	written   map[string][]string
+	err       error
+	permanent bool
+	closed    []string
+}
+
+func (m *sinkRepositoryMock) SinkOpened(sink string) bool {
+	return !slices.Contains(m.closed, sink)
+}
+
+func (m *sinkRepositoryMock) SinkWriteBatch(ctx context.Context, sink string, payloads [][]byte) []error {
+	errs := make([]error, len(payloads))
+	for i, payload := range payloads {
+		if m.err != nil {
+			errs[i] = m.err
+			continue
+		}
+		if m.written == nil {
+			m.written = make(map[string][]string)
+		}
+		m.written[sink] = append(m.written[sink], string(payload))
+	}
+	return errs
+}
+
+func (m *sinkRepositoryMock) SinkClassify(sink string, err error) backoff.ErrorClassification {
+	if m.permanent {
+		return backoff.NonRetriable
+	}
+	return backoff.Retriable
+}
+
+func TestService_Do(t *testing.T) {
+	update := pkg.MustJSON(models.UpdateEvent{Action: models.AuditActionUpdate, Metrics: []string{"HeapAlloc"}})
+	legacy := pkg.MustJSON(models.UpdateEvent{Metrics: []string{"PollCount"}})
+	deleted := pkg.MustJSON(models.DeleteEvent{Action: models.AuditActionDelete, Metrics: []string{"HeapInuse"}})
+	alert := pkg.MustJSON(models.AlertEvent{Action: models.AuditActionAlert, MetricName: "HeapAlloc"})
+
+	outboxRepo := &outboxRepositoryMock{pending: map[models.OutboxDestination][]entities.Outbox{
+		models.AuditOutboxDestination: {
+			{ID: "1", Payload: update},
+			{ID: "2", Payload: legacy},
+			{ID: "3", Payload: deleted},
+			{ID: "4", Payload: alert},
+			{ID: "5", Payload: []byte("not json")},
+		},
+	}}
+
+	srv := New([]models.AuditSink{
+		{Name: "all", Type: models.StdoutSinkType},
+		{Name: "updates", Type: models.FileSinkType, Filter: models.AuditFilter{Actions: []string{"update"}}},
+		{Name: "heap", Type: models.HTTPSinkType, Filter: models.AuditFilter{Metrics: []string{"Heap"}}},
+		{Name: "heap", Type: models.StdoutSinkType},
+		{Type: models.StdoutSinkType},
+		{Name: "unopened", Type: models.StdoutSinkType},
+	}, outboxRepo, &sinkRepositoryMock{closed: []string{"unopened"}})
+	assert.Equal(t, []string{"all", "updates", "heap"}, srv.Sinks())
+
+	require.NoError(t, srv.Do(context.Background(), "1"))
+
+	got := make(map[string][]string)
+	for _, item := range outboxRepo.added {
+		assert.Equal(t, "1", item.Segment)
+		got[item.Destination] = append(got[item.Destination], string(item.Payload))
+	}
+	assert.Equal(t, map[string][]string{
+		"audit:all":     {string(update), string(legacy), string(deleted), string(alert)},
+		"audit:updates": {string(update), string(legacy)},
+		"audit:heap":    {string(update), string(deleted), string(alert)},
+	}, got)
+	assert.Equal(t, []entities.OutboxID{"1", "2", "3", "4", "5"}, outboxRepo.completed)
+}
+
+func TestService_Deliver(t *testing.T) {
+	sinks := []models.AuditSink{
+		{Name: "file", Type: models.FileSinkType, BatchSize: 10},
+		{Name: "remote", Type: models.HTTPSinkType},
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		outboxRepo := &outboxRepositoryMock{pending: map[models.OutboxDestination][]entities.Outbox{
+			models.AuditSinkDestination("file"): {{ID: "1", Payload: []byte(`{"n":1}`)}, {ID: "2", Payload: []byte(`{"n":2}`)}},
+		}}
+		sinkRepo := &sinkRepositoryMock{}
+
+		require.NoError(t, New(sinks, outboxRepo, sinkRepo).Deliver(context.Background(), "file", ""))
+		assert.Equal(t, 10, outboxRepo.limit)
+		assert.Equal(t, []string{`{"n":1}`, `{"n":2}`}, sinkRepo.written["file"])
+		assert.Equal(t, []entities.OutboxID{"1", "2"}, outboxRepo.completed)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		outboxRepo := &outboxRepositoryMock{pending: map[models.OutboxDestination][]entities.Outbox{
+			models.AuditSinkDestination("remote"): {{ID: "1", Payload: []byte(`{}`)}},
+		}}
+		sinkRepo := &sinkRepositoryMock{err: errors.New("connection refused")}
+
+		require.NoError(t, New(sinks, outboxRepo, sinkRepo).Deliver(context.Background(), "remote", ""))
+		assert.Equal(t, defaultBatchSize, outboxRepo.limit)
+		assert.Empty(t, outboxRepo.completed)
+		assert.Equal(t, []entities.OutboxFailure{{ID: "1", Error: "connection refused"}}, outboxRepo.failed)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		outboxRepo := &outboxRepositoryMock{pending: map[models.OutboxDestination][]entities.Outbox{
+			models.AuditSinkDestination("remote"): {{ID: "1", Payload: []byte(`{}`)}, {ID: "2", Payload: []byte(`{}`)}},
+		}}
+		sinkRepo := &sinkRepositoryMock{err: errors.New("status_code=400"), permanent: true}
+
+		require.NoError(t, New(sinks, outboxRepo, sinkRepo).Deliver(context.Background(), "remote", ""))
+		assert.Empty(t, outboxRepo.completed)
+		assert.Equal(t, []entities.OutboxFailure{
+			{ID: "1", Error: "status_code=400", Permanent: true},
+			{ID: "2", Error: "status_code=400", Permanent: true},
+		}, outboxRepo.failed)
+	})
+
+	t.Run("unknown sink", func(t *testing.T) {
+		err := New(sinks, &outboxRepositoryMock{}, &sinkRepositoryMock{}).Deliver(context.Background(), "syslog", "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,13 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+type DeadLetterRepository interface {
+	DeadLetterList(ctx context.Context, destination string, limit int) (resp []entities.DeadLetter, err error)
+	DeadLetterRequeue(ctx context.Context, ids []entities.OutboxID, destination string) (requeued []entities.OutboxID, err error)
+	DeadLetterPurge(ctx context.Context, ids []entities.OutboxID, destination string) (purged []entities.OutboxID, err error)
+}
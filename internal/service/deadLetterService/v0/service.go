@@ -0,0 +1,157 @@
+package v0
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+var (
+	errInvalidDestination *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid destination")
+	errInvalidLimit       *pkg.Error = pkg.ErrBadRequest.SetInfof("limit must be between 1 and %d", maxLimit)
+	errInvalidAll         *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid all")
+	errNoSelection        *pkg.Error = pkg.ErrBadRequest.SetInfo("either id or all=true is required")
+)
+
+var allowDestination = map[models.OutboxDestination]struct{}{
+	models.AuditOutboxDestination:   {},
+	models.NotifyOutboxDestination:  {},
+	models.WebhookOutboxDestination: {},
+}
+
+type Service struct {
+	deadLetterRepository DeadLetterRepository
+}
+
+func New(deadLetterRepo DeadLetterRepository) *Service {
+	return &Service{
+		deadLetterRepository: deadLetterRepo,
+	}
+}
+
+// List returns the latest dead letters of the destination, or of any when it is empty.
+func (srv *Service) List(ctx context.Context, destination, limitParam string) (letters []models.DeadLetter, err error) {
+	ctx, span := tracing.Start(ctx, "deadLetterService.List")
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkDestination(destination); err != nil {
+		return nil, err
+	}
+
+	limit := defaultLimit
+	if limitParam != "" {
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 1 || limit > maxLimit {
+			return nil, errInvalidLimit
+		}
+	}
+
+	items, err := srv.deadLetterRepository.DeadLetterList(ctx, destination, limit)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	letters = make([]models.DeadLetter, 0, len(items))
+	for _, item := range items {
+		letters = append(letters, toModel(item))
+	}
+
+	return letters, nil
+}
+
+// Requeue moves the dead letters back to the outbox for another round of deliveries,
+// either the given ids or, with allParam "true", all of them. The empty destination
+// matches any. It returns the ids of the requeued items.
+func (srv *Service) Requeue(ctx context.Context, destination string, ids []string, allParam string) (requeued []string, err error) {
+	ctx, span := tracing.Start(ctx, "deadLetterService.Requeue")
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkDestination(destination); err != nil {
+		return nil, err
+	}
+	if err := checkSelection(ids, allParam); err != nil {
+		return nil, err
+	}
+
+	requeued, err = srv.deadLetterRepository.DeadLetterRequeue(ctx, ids, destination)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	return requeued, nil
+}
+
+// Purge deletes the dead letters, either the given ids or, with allParam "true",
+// all of them. The empty destination matches any. It returns the ids of the deleted items.
+func (srv *Service) Purge(ctx context.Context, destination string, ids []string, allParam string) (purged []string, err error) {
+	ctx, span := tracing.Start(ctx, "deadLetterService.Purge")
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkDestination(destination); err != nil {
+		return nil, err
+	}
+	if err := checkSelection(ids, allParam); err != nil {
+		return nil, err
+	}
+
+	purged, err = srv.deadLetterRepository.DeadLetterPurge(ctx, ids, destination)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	return purged, nil
+}
+
+func checkDestination(destination string) error {
+	if destination == "" {
+		return nil
+	}
+	if _, ok := allowDestination[models.OutboxDestination(destination)]; ok {
+		return nil
+	}
+	// every audit sink has its own destination
+	if sink, ok := strings.CutPrefix(destination, string(models.AuditSinkDestination(""))); ok && sink != "" {
+		return nil
+	}
+	return errInvalidDestination
+}
+
+// checkSelection keeps a bare request from matching every dead letter,
+// all of them are selected explicitly.
+func checkSelection(ids []string, allParam string) error {
+	all := false
+	if allParam != "" {
+		var err error
+		if all, err = strconv.ParseBool(allParam); err != nil {
+			return errInvalidAll
+		}
+	}
+	if all == (len(ids) > 0) {
+		return errNoSelection
+	}
+	return nil
+}
+
+func toModel(item entities.DeadLetter) models.DeadLetter {
+	letter := models.DeadLetter{
+		ID:          item.ID,
+		Destination: item.Destination,
+		Segment:     item.Segment,
+		Payload:     item.Payload,
+		Attempts:    item.Attempts,
+		CreatedAt:   item.CreatedAt,
+	}
+	if item.LastError != nil {
+		letter.LastError = *item.LastError
+	}
+	return letter
+}
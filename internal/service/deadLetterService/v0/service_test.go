@@ -0,0 +1,134 @@
+package v0
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type deadLetterRepositoryMock struct {
+	limit       int
+	destination string
+	ids         []entities.OutboxID
+}
+
+func (m *deadLetterRepositoryMock) DeadLetterList(ctx context.Context, destination string, limit int) ([]entities.DeadLetter, error) {
+	m.destination, m.limit = destination, limit
+	return []entities.DeadLetter{
+		{ID: "1", Destination: "audit:remote", Attempts: 10, LastError: pkg.ToPtr("connection refused")},
+		{ID: "2", Destination: "audit:remote", Attempts: 10},
+	}, nil
+}
+
+func (m *deadLetterRepositoryMock) DeadLetterRequeue(
+	ctx context.Context, ids []entities.OutboxID, destination string,
+) ([]entities.OutboxID, error) {
+	m.destination, m.ids = destination, ids
+	return ids, nil
+}
+
+func (m *deadLetterRepositoryMock) DeadLetterPurge(
+	ctx context.Context, ids []entities.OutboxID, destination string,
+) ([]entities.OutboxID, error) {
+	m.destination, m.ids = destination, ids
+	return ids, nil
+}
+
+func TestService_List(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		limit       string
+		wantLimit   int
+		wantErr     bool
+	}{
+		{name: "default limit", wantLimit: defaultLimit},
+		{name: "destination and limit", destination: "audit:remote", limit: "10", wantLimit: 10},
+		{name: "unknown destination", destination: "unknown", wantErr: true},
+		{name: "unnamed sink", destination: "audit:", wantErr: true},
+		{name: "zero limit", limit: "0", wantErr: true},
+		{name: "too large limit", limit: "1001", wantErr: true},
+		{name: "invalid limit", limit: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &deadLetterRepositoryMock{}
+
+			letters, err := New(repo).List(context.Background(), tt.destination, tt.limit)
+			if tt.wantErr {
+				assert.Equal(t, http.StatusBadRequest, httpStatus(err))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLimit, repo.limit)
+			assert.Equal(t, tt.destination, repo.destination)
+			require.Len(t, letters, 2)
+			assert.Equal(t, "connection refused", letters[0].LastError)
+			assert.Empty(t, letters[1].LastError)
+		})
+	}
+}
+
+func TestService_Requeue(t *testing.T) {
+	repo := &deadLetterRepositoryMock{}
+	srv := New(repo)
+
+	requeued, err := srv.Requeue(context.Background(), "webhook", []string{"1", "2"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, requeued)
+	assert.Equal(t, "webhook", repo.destination)
+
+	_, err = srv.Requeue(context.Background(), "unknown", []string{"1"}, "")
+	assert.Equal(t, http.StatusBadRequest, httpStatus(err))
+}
+
+func TestService_Purge(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		all     string
+		wantIDs []string
+		wantErr bool
+	}{
+		{name: "ids", ids: []string{"1", "2"}, wantIDs: []string{"1", "2"}},
+		{name: "all", all: "true", wantIDs: nil},
+		{name: "no selection", wantErr: true},
+		{name: "all false", all: "false", wantErr: true},
+		{name: "ids and all", ids: []string{"1"}, all: "true", wantErr: true},
+		{name: "invalid all", all: "yes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &deadLetterRepositoryMock{ids: []string{"untouched"}}
+
+			purged, err := New(repo).Purge(context.Background(), "", tt.ids, tt.all)
+			if tt.wantErr {
+				assert.Equal(t, http.StatusBadRequest, httpStatus(err))
+				assert.Equal(t, []string{"untouched"}, repo.ids)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIDs, purged)
+			assert.Equal(t, tt.wantIDs, repo.ids)
+		})
+	}
+}
+
+func httpStatus(err error) int {
+	var errE *pkg.Error
+	if !errors.As(err, &errE) {
+		return 0
+	}
+	return errE.HTTPStatus()
+}
@@ -0,0 +1,13 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+type MetricRepository interface {
+	Delete(
+		ctx context.Context, filter entities.MetricFilter, outboxes []entities.Outbox, outboxSegment string,
+	) (deleted []entities.MetricKey, err error)
+}
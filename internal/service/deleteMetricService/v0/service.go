@@ -0,0 +1,121 @@
+package v0
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/principal"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
+)
+
+const segment = ""
+
+var (
+	errInvalidMetricType *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
+	errEmptyPrefix       *pkg.Error = pkg.ErrBadRequest.SetInfo("prefix is required")
+)
+
+type Service struct {
+	metricRepository MetricRepository
+}
+
+func New(metricRepo MetricRepository) *Service {
+	return &Service{
+		metricRepository: metricRepo,
+	}
+}
+
+// Do deletes a single series.
+func (srv *Service) Do(
+	ctx context.Context, ipAddress, metricType, metricName string, labels pkg.Labels,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "deleteMetricService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	if !validType(metricType) {
+		return errInvalidMetricType
+	}
+
+	deleted, err := srv.delete(ctx, entities.MetricFilter{
+		MetricType: metricType,
+		MetricName: metricName,
+		Labels:     labels,
+	}, models.DeleteReasonRequest, ipAddress)
+	if err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return pkg.ErrNotFound.SetCode(pkg.CodeMetricNotFound).SetInfof("`%s` not found", labels.Series(metricName))
+	}
+
+	return nil
+}
+
+// DoPrefix deletes every series whose name starts with the prefix,
+// the empty metric type matches any type.
+func (srv *Service) DoPrefix(
+	ctx context.Context, ipAddress, metricType, prefix string,
+) (deleted []string, err error) {
+	ctx, span := tracing.Start(ctx, "deleteMetricService.DoPrefix")
+	defer func() { tracing.End(span, err) }()
+
+	if prefix == "" {
+		return nil, errEmptyPrefix
+	}
+	if metricType != "" && !validType(metricType) {
+		return nil, errInvalidMetricType
+	}
+
+	return srv.delete(ctx, entities.MetricFilter{
+		MetricType: metricType,
+		Prefix:     prefix,
+	}, models.DeleteReasonRequest, ipAddress)
+}
+
+// Expire deletes the series not updated since before.
+func (srv *Service) Expire(ctx context.Context, before time.Time) (deleted []string, err error) {
+	return srv.delete(ctx, entities.MetricFilter{
+		UpdatedBefore: before,
+	}, models.DeleteReasonRetention, "")
+}
+
+func (srv *Service) delete(
+	ctx context.Context, filter entities.MetricFilter, reason, ipAddress string,
+) ([]string, error) {
+	// the repository fills the deleted series in and adds the event along with the delete
+	payload := pkg.MustJSON(models.DeleteEvent{
+		TS:        time.Now(),
+		Action:    models.AuditActionDelete,
+		Reason:    reason,
+		IPAddress: ipAddress,
+		RequestID: requestid.FromContext(ctx),
+		Principal: principal.FromContext(ctx),
+	})
+	outboxes := []entities.Outbox{
+		{Destination: string(models.AuditOutboxDestination), Segment: segment, Payload: payload},
+	}
+
+	items, err := srv.metricRepository.Delete(ctx, filter, outboxes, segment)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	deleted := make([]string, 0, len(items))
+	for _, item := range items {
+		deleted = append(deleted, item.Labels.Series(item.MetricName))
+	}
+
+	return deleted, nil
+}
+
+func validType(metricType string) bool {
+	switch metricType {
+	case pkg.MetricTypeCounter, pkg.MetricTypeGauge, pkg.MetricTypeHistogram:
+		return true
+	}
+	return false
+}
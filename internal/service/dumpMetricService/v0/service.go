@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
 )
 
 type Service struct {
@@ -25,6 +29,7 @@ func (srv *Service) ReadDump() error {
 	if !srv.config.ReadDumpEnable {
 		return nil
 	}
+	defer selfmetrics.ObserveSince("dump_duration_seconds", pkg.Labels{"op": "read"}, time.Now())
 
 	file, err := os.ReadFile(srv.fname)
 	if err != nil {
@@ -46,6 +51,8 @@ func (srv *Service) WriteDump() error {
 	srv.mtx.Lock()
 	defer srv.mtx.Unlock()
 
+	defer selfmetrics.ObserveSince("dump_duration_seconds", pkg.Labels{"op": "write"}, time.Now())
+
 	data, err := srv.metricRepository.Save()
 	if err != nil {
 		return fmt.Errorf("dump repo not ok, %w", err)
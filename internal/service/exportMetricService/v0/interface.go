@@ -0,0 +1,11 @@
+package v0
+
+import (
+	"context"
+
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+)
+
+type MetricRepository interface {
+	List(ctx context.Context) (resp listMetricService.MetricData, err error)
+}
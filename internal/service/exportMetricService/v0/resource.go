@@ -0,0 +1,109 @@
+package v0
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	acceptOpenMetrics = "application/openmetrics-text"
+	counterSuffix     = "_total"
+	bucketSuffix      = "_bucket"
+	sumSuffix         = "_sum"
+	countSuffix       = "_count"
+	bucketLabel       = "le"
+)
+
+type family struct {
+	name       string
+	origin     string
+	metricType string
+	samples    []sample
+}
+
+// sample is a single exposition line. Lines of one series, like the buckets
+// of a histogram, share the series key.
+type sample struct {
+	series string
+	suffix string
+	labels string
+	value  string
+}
+
+// sanitizeName maps an arbitrary metric id onto [a-zA-Z_:][a-zA-Z0-9_:]*.
+func sanitizeName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// formatLabels renders the label set as `{a="1",b="2"}`, or an empty string
+// when there are no labels.
+func formatLabels(labels pkg.Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range labels.Names() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sanitizeName(name))
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func isOpenMetrics(accept string) bool {
+	return strings.Contains(accept, acceptOpenMetrics)
+}
@@ -0,0 +1,112 @@
+package v0
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid", in: "HeapAlloc", want: "HeapAlloc"},
+		{name: "colon kept", in: "job:rate", want: "job:rate"},
+		{name: "invalid chars", in: "cpu.usage-1 %", want: "cpu_usage_1__"},
+		{name: "leading digit", in: "1st", want: "_1st"},
+		{name: "unicode", in: "мера", want: "____"},
+		{name: "empty", in: "", want: "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeName(tt.in))
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels pkg.Labels
+		want   string
+	}{
+		{name: "empty", labels: nil, want: ""},
+		{name: "sorted", labels: pkg.Labels{"host": "a", "dc": "b"}, want: `{dc="b",host="a"}`},
+		{name: "escaped", labels: pkg.Labels{"path": "C:\\tmp\n\"x\""}, want: `{path="C:\\tmp\n\"x\""}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatLabels(tt.labels))
+		})
+	}
+}
+
+func TestHistogramSamples(t *testing.T) {
+	item := entities.HistogramItem{
+		MetricName: "latency",
+		Labels:     pkg.Labels{"host": "a"},
+		Bounds:     []float64{0.5, 1},
+		Counts:     []uint64{1, 2, 1},
+		Sum:        3.25,
+		Count:      4,
+	}
+
+	var lines []string
+	for _, s := range histogramSamples(item) {
+		assert.Equal(t, `{host="a"}`, s.series)
+		lines = append(lines, "latency"+s.suffix+s.labels+" "+s.value)
+	}
+
+	assert.Equal(t, []string{
+		`latency_bucket{host="a",le="0.5"} 1`,
+		`latency_bucket{host="a",le="1"} 3`,
+		`latency_bucket{host="a",le="+Inf"} 4`,
+		`latency_sum{host="a"} 3.25`,
+		`latency_count{host="a"} 4`,
+	}, lines)
+	assert.Len(t, item.Labels, 1)
+}
+
+func TestMergeFamilies_Collision(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	gauge := func(origin, series, value string) family {
+		return family{
+			name:       sanitizeName(origin),
+			origin:     origin,
+			metricType: pkg.MetricTypeGauge,
+			samples:    []sample{{series: series, labels: series, value: value}},
+		}
+	}
+
+	merged := mergeFamilies([]family{
+		gauge("cpu.usage", "", "1"),
+		gauge("cpu_usage", "", "2"),
+		gauge("cpu_usage", `{host="a"}`, "3"),
+		{name: "cpu_usage", origin: "cpu-usage", metricType: pkg.MetricTypeCounter, samples: []sample{{value: "4"}}},
+	})
+
+	assert.Equal(t, []family{{
+		name:       "cpu_usage",
+		origin:     "cpu.usage",
+		metricType: pkg.MetricTypeGauge,
+		samples: []sample{
+			{value: "1"},
+			{series: `{host="a"}`, labels: `{host="a"}`, value: "3"},
+		},
+	}}, merged)
+	assert.Contains(t, buf.String(), "{name=cpu_usage, kept=cpu.usage, dropped=cpu_usage}")
+	assert.Contains(t, buf.String(), "{name=cpu_usage, kept=cpu.usage, dropped=cpu-usage}")
+}
@@ -0,0 +1,163 @@
+package v0
+
+import (
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type Service struct {
+	metricRepository MetricRepository
+}
+
+func New(metricRepo MetricRepository) *Service {
+	return &Service{
+		metricRepository: metricRepo,
+	}
+}
+
+// Do renders every stored metric in the Prometheus text exposition format,
+// or in OpenMetrics when the accept header asks for it.
+func (srv *Service) Do(ctx context.Context, accept string) (body string, contentType string, err error) {
+	ctx, span := tracing.Start(ctx, "exportMetricService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	resp, err := srv.metricRepository.List(ctx)
+	if err != nil {
+		return "", "", pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	openMetrics := isOpenMetrics(accept)
+
+	families := make([]family, 0, len(resp.Counters)+len(resp.Gauges)+len(resp.Histograms))
+	for _, item := range resp.Counters {
+		name, suffix := sanitizeName(item.MetricName), ""
+		if openMetrics {
+			name, suffix = strings.TrimSuffix(name, counterSuffix), counterSuffix
+		}
+
+		labels := formatLabels(item.Labels)
+		families = append(families, family{
+			name:       name,
+			origin:     item.MetricName,
+			metricType: pkg.MetricTypeCounter,
+			samples: []sample{
+				{series: labels, suffix: suffix, labels: labels, value: strconv.FormatInt(item.MetricValue, 10)},
+			},
+		})
+	}
+	for _, item := range resp.Gauges {
+		labels := formatLabels(item.Labels)
+		families = append(families, family{
+			name:       sanitizeName(item.MetricName),
+			origin:     item.MetricName,
+			metricType: pkg.MetricTypeGauge,
+			samples: []sample{
+				{series: labels, labels: labels, value: formatFloat(item.MetricValue)},
+			},
+		})
+	}
+	for _, item := range resp.Histograms {
+		families = append(families, family{
+			name:       sanitizeName(item.MetricName),
+			origin:     item.MetricName,
+			metricType: pkg.MetricTypeHistogram,
+			samples:    histogramSamples(item),
+		})
+	}
+
+	slices.SortStableFunc(families, func(a, b family) int {
+		return strings.Compare(a.name, b.name)
+	})
+	families = mergeFamilies(families)
+
+	var b strings.Builder
+	for _, f := range families {
+		b.WriteString("# HELP " + f.name + " " + escapeHelp(f.origin) + "\n")
+		b.WriteString("# TYPE " + f.name + " " + f.metricType + "\n")
+		for _, s := range f.samples {
+			b.WriteString(f.name + s.suffix + s.labels + " " + s.value + "\n")
+		}
+	}
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+		return b.String(), ContentTypeOpenMetrics, nil
+	}
+
+	return b.String(), ContentTypeText, nil
+}
+
+// mergeFamilies joins the series of equally named families. Series whose
+// sanitized name or labels collide with an earlier one, e.g. cpu.usage and
+// cpu_usage, are dropped and logged.
+func mergeFamilies(families []family) []family {
+	merged := make([]family, 0, len(families))
+
+	for _, f := range families {
+		n := len(merged)
+		if n == 0 || merged[n-1].name != f.name {
+			merged = append(merged, f)
+			continue
+		}
+
+		last := &merged[n-1]
+		// every family holds a single series before the merge
+		if last.metricType != f.metricType ||
+			slices.ContainsFunc(last.samples, func(x sample) bool { return x.series == f.samples[0].series }) {
+			log.Printf("metric export collision {name=%v, kept=%v, dropped=%v}\n",
+				f.name, last.origin, f.origin+f.samples[0].series)
+			continue
+		}
+		last.samples = append(last.samples, f.samples...)
+	}
+
+	for _, f := range merged {
+		slices.SortStableFunc(f.samples, func(a, b sample) int {
+			return strings.Compare(a.series, b.series)
+		})
+	}
+
+	return merged
+}
+
+// histogramSamples renders the cumulative buckets, the sum and the count.
+func histogramSamples(item entities.HistogramItem) []sample {
+	series := formatLabels(item.Labels)
+	samples := make([]sample, 0, len(item.Counts)+2)
+
+	var cumulative uint64
+	for i, count := range item.Counts {
+		cumulative += count
+
+		le := "+Inf"
+		if i < len(item.Bounds) {
+			le = formatFloat(item.Bounds[i])
+		}
+
+		labels := maps.Clone(item.Labels)
+		if labels == nil {
+			labels = make(pkg.Labels, 1)
+		}
+		labels[bucketLabel] = le
+
+		samples = append(samples, sample{
+			series: series,
+			suffix: bucketSuffix,
+			labels: formatLabels(labels),
+			value:  strconv.FormatUint(cumulative, 10),
+		})
+	}
+
+	return append(samples,
+		sample{series: series, suffix: sumSuffix, labels: series, value: formatFloat(item.Sum)},
+		sample{series: series, suffix: countSuffix, labels: series, value: strconv.FormatUint(item.Count, 10)},
+	)
+}
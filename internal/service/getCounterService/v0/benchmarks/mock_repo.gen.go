@@ -11,6 +11,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 
 	entities "github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	pkg "github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 // MockMetricRepository is a mock of MetricRepository interface.
@@ -37,9 +38,9 @@ func (m *MockMetricRepository) EXPECT() *MockMetricRepositoryMockRecorder {
 }
 
 // GetCounter mocks base method.
-func (m *MockMetricRepository) GetCounter(ctx context.Context, name string) (*entities.CounterItem, bool, error) {
+func (m *MockMetricRepository) GetCounter(ctx context.Context, name string, labels pkg.Labels) (*entities.CounterItem, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCounter", ctx, name)
+	ret := m.ctrl.Call(m, "GetCounter", ctx, name, labels)
 	ret0, _ := ret[0].(*entities.CounterItem)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
@@ -47,7 +48,7 @@ func (m *MockMetricRepository) GetCounter(ctx context.Context, name string) (*en
 }
 
 // GetCounter indicates an expected call of GetCounter.
-func (mr *MockMetricRepositoryMockRecorder) GetCounter(ctx, name interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) GetCounter(ctx, name, labels interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCounter", reflect.TypeOf((*MockMetricRepository)(nil).GetCounter), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCounter", reflect.TypeOf((*MockMetricRepository)(nil).GetCounter), ctx, name, labels)
 }
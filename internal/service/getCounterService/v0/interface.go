@@ -4,8 +4,9 @@ import (
 	"context"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 type MetricRepository interface {
-	GetCounter(ctx context.Context, name string) (item *entities.CounterItem, ok bool, err error)
+	GetCounter(ctx context.Context, name string, labels pkg.Labels) (item *entities.CounterItem, ok bool, err error)
 }
@@ -6,41 +6,75 @@ import (
 
 	getCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getCounterService/v0"
 	getGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getGaugeService/v0"
+	getHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getHistogramService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
+var (
+	errInvalidQuantile *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid quantile")
+)
+
+// defaultQuantile is reported for histograms when no quantile is requested.
+const defaultQuantile = "0.5"
+
 type Service struct {
-	getCounterService *getCounterService.Service
-	getGaugeService   *getGaugeService.Service
+	getCounterService   *getCounterService.Service
+	getGaugeService     *getGaugeService.Service
+	getHistogramService *getHistogramService.Service
 }
 
 func New(
 	getCounterService *getCounterService.Service,
 	getGaugeService *getGaugeService.Service,
+	getHistogramService *getHistogramService.Service,
 ) *Service {
 	return &Service{
-		getCounterService: getCounterService,
-		getGaugeService:   getGaugeService,
+		getCounterService:   getCounterService,
+		getGaugeService:     getGaugeService,
+		getHistogramService: getHistogramService,
 	}
 }
 
-func (srv *Service) Do(ctx context.Context, metricType, metricName string) (value string, err error) {
+// Do returns the metric value as text, for histograms it is the estimate
+// of the requested quantile.
+func (srv *Service) Do(
+	ctx context.Context, metricType, metricName string, labels pkg.Labels, quantile string,
+) (value string, err error) {
+	ctx, span := tracing.Start(ctx, "getFlatService.Do")
+	defer func() { tracing.End(span, err) }()
+
 	switch metricType {
 	case pkg.MetricTypeCounter:
-		if valueInt, err := srv.getCounterService.Do(ctx, metricName); err != nil {
+		if valueInt, err := srv.getCounterService.Do(ctx, metricName, labels); err != nil {
 			return "", err
 		} else {
 			return strconv.FormatInt(*valueInt, 10), nil
 		}
 
 	case pkg.MetricTypeGauge:
-		if valueFloat, err := srv.getGaugeService.Do(ctx, metricName); err != nil {
+		if valueFloat, err := srv.getGaugeService.Do(ctx, metricName, labels); err != nil {
 			return "", err
 		} else {
 			return strconv.FormatFloat(*valueFloat, 'f', -1, 64), nil
 		}
 
+	case pkg.MetricTypeHistogram:
+		if quantile == "" {
+			quantile = defaultQuantile
+		}
+		q, err := strconv.ParseFloat(quantile, 64)
+		if err != nil || q < 0 || q > 1 {
+			return "", errInvalidQuantile
+		}
+
+		histogram, err := srv.getHistogramService.Do(ctx, metricName, labels)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(histogram.Quantile(q), 'f', -1, 64), nil
+
 	default:
-		return "", pkg.ErrBadRequest.SetInfo("invalid metric type")
+		return "", pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
 	}
 }
@@ -11,6 +11,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 
 	entities "github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	pkg "github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 // MockMetricRepository is a mock of MetricRepository interface.
@@ -37,9 +38,9 @@ func (m *MockMetricRepository) EXPECT() *MockMetricRepositoryMockRecorder {
 }
 
 // GetGauge mocks base method.
-func (m *MockMetricRepository) GetGauge(ctx context.Context, name string) (*entities.GaugeItem, bool, error) {
+func (m *MockMetricRepository) GetGauge(ctx context.Context, name string, labels pkg.Labels) (*entities.GaugeItem, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetGauge", ctx, name)
+	ret := m.ctrl.Call(m, "GetGauge", ctx, name, labels)
 	ret0, _ := ret[0].(*entities.GaugeItem)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
@@ -47,7 +48,7 @@ func (m *MockMetricRepository) GetGauge(ctx context.Context, name string) (*enti
 }
 
 // GetGauge indicates an expected call of GetGauge.
-func (mr *MockMetricRepositoryMockRecorder) GetGauge(ctx, name interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) GetGauge(ctx, name, labels interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGauge", reflect.TypeOf((*MockMetricRepository)(nil).GetGauge), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGauge", reflect.TypeOf((*MockMetricRepository)(nil).GetGauge), ctx, name, labels)
 }
@@ -4,8 +4,9 @@ import (
 	"context"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 type MetricRepository interface {
-	GetGauge(ctx context.Context, name string) (item *entities.GaugeItem, ok bool, err error)
+	GetGauge(ctx context.Context, name string, labels pkg.Labels) (item *entities.GaugeItem, ok bool, err error)
 }
@@ -3,6 +3,8 @@ package v0
 import (
 	"context"
 
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
@@ -19,14 +21,32 @@ func New(metricRepo MetricRepository) *Service {
 func (srv *Service) Do(
 	ctx context.Context,
 	metricName string,
-) (*float64, error) {
-	item, ok, err := srv.metricRepository.GetGauge(ctx, metricName)
+	labels pkg.Labels,
+) (value *float64, err error) {
+	ctx, span := tracing.Start(ctx, "getGaugeService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	item, err := srv.Get(ctx, metricName, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item.MetricValue, nil
+}
+
+// Get returns the stored gauge together with its version.
+func (srv *Service) Get(
+	ctx context.Context,
+	metricName string,
+	labels pkg.Labels,
+) (*entities.GaugeItem, error) {
+	item, ok, err := srv.metricRepository.GetGauge(ctx, metricName, labels)
 	if err != nil {
 		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
 	}
 	if !ok {
-		return nil, pkg.ErrNotFound.SetInfof("`%s` not found", metricName)
+		return nil, pkg.ErrNotFound.SetCode(pkg.CodeMetricNotFound).SetInfof("`%s` not found", labels.Series(metricName))
 	}
 
-	return &item.MetricValue, nil
+	return item, nil
 }
@@ -0,0 +1,12 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type MetricRepository interface {
+	GetHistogram(ctx context.Context, name string, labels pkg.Labels) (item *entities.HistogramItem, ok bool, err error)
+}
@@ -0,0 +1,57 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type Service struct {
+	metricRepository MetricRepository
+}
+
+func New(metricRepo MetricRepository) *Service {
+	return &Service{
+		metricRepository: metricRepo,
+	}
+}
+
+func (srv *Service) Do(
+	ctx context.Context,
+	metricName string,
+	labels pkg.Labels,
+) (histogram *pkg.Histogram, err error) {
+	ctx, span := tracing.Start(ctx, "getHistogramService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	item, err := srv.Get(ctx, metricName, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkg.Histogram{
+		Bounds: item.Bounds,
+		Counts: item.Counts,
+		Sum:    item.Sum,
+		Count:  item.Count,
+	}, nil
+}
+
+// Get returns the stored histogram together with its version.
+func (srv *Service) Get(
+	ctx context.Context,
+	metricName string,
+	labels pkg.Labels,
+) (*entities.HistogramItem, error) {
+	item, ok, err := srv.metricRepository.GetHistogram(ctx, metricName, labels)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+	if !ok {
+		return nil, pkg.ErrNotFound.SetCode(pkg.CodeMetricNotFound).SetInfof("`%s` not found", labels.Series(metricName))
+	}
+
+	return item, nil
+}
@@ -38,7 +38,7 @@ func BenchmarkGetService(b *testing.B) {
 	}
 
 	mockCounterRepo := cb.NewMockMetricRepository(ctrl)
-	mockCounterRepo.EXPECT().GetCounter(context.Background(), gomock.Eq("counter")).AnyTimes().Return(
+	mockCounterRepo.EXPECT().GetCounter(context.Background(), gomock.Eq("counter"), gomock.Nil()).AnyTimes().Return(
 		&entities.CounterItem{
 			MetricType:  pkg.MetricTypeCounter,
 			MetricName:  "counter",
@@ -48,7 +48,7 @@ func BenchmarkGetService(b *testing.B) {
 		}, true, nil)
 
 	mockGaugeRepo := gb.NewMockMetricRepository(ctrl)
-	mockGaugeRepo.EXPECT().GetGauge(context.Background(), gomock.Eq("gauge")).AnyTimes().Return(
+	mockGaugeRepo.EXPECT().GetGauge(context.Background(), gomock.Eq("gauge"), gomock.Nil()).AnyTimes().Return(
 		&entities.GaugeItem{
 			MetricType:  pkg.MetricTypeGauge,
 			MetricName:  "gauge",
@@ -57,9 +57,9 @@ func BenchmarkGetService(b *testing.B) {
 			UpdatedAt:   ts,
 		}, true, nil)
 
-	call := func(ctx context.Context, _, _ string) (*models.Metric, error) {
-		srv := getService.New(getCounterService.New(mockCounterRepo), getGaugeService.New(mockGaugeRepo))
-		return srv.Do(ctx, metric.MType, metric.ID)
+	call := func(ctx context.Context, _, _ string, _ pkg.Labels) (*models.Metric, error) {
+		srv := getService.New(getCounterService.New(mockCounterRepo), getGaugeService.New(mockGaugeRepo), nil)
+		return srv.Do(ctx, metric.MType, metric.ID, metric.Labels)
 	}
 	h := handler.DoGetJSONResponse(call)
 
@@ -2,51 +2,75 @@ package v0
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
 	getCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getCounterService/v0"
 	getGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getGaugeService/v0"
+	getHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/getHistogramService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 var (
-	errInvalidMetricType *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric type")
+	errInvalidMetricType *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
 )
 
+// reportedQuantiles are estimated for every histogram returned by Do.
+var reportedQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
 type Service struct {
-	getCounterService *getCounterService.Service
-	getGaugeService   *getGaugeService.Service
+	getCounterService   *getCounterService.Service
+	getGaugeService     *getGaugeService.Service
+	getHistogramService *getHistogramService.Service
 }
 
 func New(
 	getCounterService *getCounterService.Service,
 	getGaugeService *getGaugeService.Service,
+	getHistogramService *getHistogramService.Service,
 ) *Service {
 	return &Service{
-		getCounterService: getCounterService,
-		getGaugeService:   getGaugeService,
+		getCounterService:   getCounterService,
+		getGaugeService:     getGaugeService,
+		getHistogramService: getHistogramService,
 	}
 }
 
-func (srv *Service) Do(ctx context.Context, metricType, metricName string) (metric *models.Metric, err error) {
+func (srv *Service) Do(
+	ctx context.Context, metricType, metricName string, labels pkg.Labels,
+) (metric *models.Metric, err error) {
+	ctx, span := tracing.Start(ctx, "getService.Do")
+	defer func() { tracing.End(span, err) }()
+
 	resp := models.Metric{
-		ID:    metricName,
-		MType: metricType,
+		ID:     metricName,
+		MType:  metricType,
+		Labels: labels,
 	}
 
 	switch metricType {
 	case pkg.MetricTypeCounter:
-		if valueInt, err := srv.getCounterService.Do(ctx, metricName); err != nil {
+		if item, err := srv.getCounterService.Get(ctx, metricName, labels); err != nil {
 			return nil, err
 		} else {
-			resp.Delta = valueInt
+			resp.Delta, resp.Version = &item.MetricValue, version(item.Version)
 		}
 
 	case pkg.MetricTypeGauge:
-		if valueFloat, err := srv.getGaugeService.Do(ctx, metricName); err != nil {
+		if item, err := srv.getGaugeService.Get(ctx, metricName, labels); err != nil {
 			return nil, err
 		} else {
-			resp.Value = valueFloat
+			resp.Value, resp.Version = &item.MetricValue, version(item.Version)
+		}
+
+	case pkg.MetricTypeHistogram:
+		if item, err := srv.getHistogramService.Get(ctx, metricName, labels); err != nil {
+			return nil, err
+		} else {
+			histogram := pkg.Histogram{Bounds: item.Bounds, Counts: item.Counts, Sum: item.Sum, Count: item.Count}
+			resp.Histogram, resp.Version = &histogram, version(item.Version)
+			resp.Quantiles = quantiles(histogram)
 		}
 
 	default:
@@ -55,3 +79,23 @@ func (srv *Service) Do(ctx context.Context, metricType, metricName string) (metr
 
 	return &resp, nil
 }
+
+// version returns nil for storages that do not track versions.
+func version(v int64) *int64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+func quantiles(histogram pkg.Histogram) map[string]float64 {
+	if histogram.Count == 0 {
+		return nil
+	}
+
+	res := make(map[string]float64, len(reportedQuantiles))
+	for _, q := range reportedQuantiles {
+		res[strconv.FormatFloat(q, 'f', -1, 64)] = histogram.Quantile(q)
+	}
+	return res
+}
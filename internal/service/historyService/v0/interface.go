@@ -0,0 +1,14 @@
+package v0
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type MetricRepository interface {
+	History(ctx context.Context, metricType, metricName string, labels pkg.Labels, from, to time.Time, step time.Duration,
+	) (points []entities.HistoryPoint, err error)
+}
@@ -0,0 +1,109 @@
+package v0
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const (
+	defaultRange = time.Hour
+	defaultStep  = time.Minute
+	maxPoints    = 10000
+)
+
+var (
+	errInvalidMetricType *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
+	errInvalidFrom       *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid from")
+	errInvalidTo         *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid to")
+	errInvalidStep       *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid step")
+	errInvalidRange      *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid range")
+)
+
+type Service struct {
+	metricRepository MetricRepository
+}
+
+func New(metricRepo MetricRepository) *Service {
+	return &Service{
+		metricRepository: metricRepo,
+	}
+}
+
+func (srv *Service) Do(
+	ctx context.Context, metricType, metricName string, labels pkg.Labels, fromParam, toParam, stepParam string,
+) (history *models.History, err error) {
+	ctx, span := tracing.Start(ctx, "historyService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	switch metricType {
+	case pkg.MetricTypeCounter, pkg.MetricTypeGauge:
+	default:
+		return nil, errInvalidMetricType
+	}
+
+	to := time.Now()
+	if toParam != "" {
+		if to, err = parseTime(toParam); err != nil {
+			return nil, errInvalidTo
+		}
+	}
+
+	from := to.Add(-defaultRange)
+	if fromParam != "" {
+		if from, err = parseTime(fromParam); err != nil {
+			return nil, errInvalidFrom
+		}
+	}
+
+	step := defaultStep
+	if stepParam != "" {
+		if step, err = time.ParseDuration(stepParam); err != nil || step < time.Second {
+			return nil, errInvalidStep
+		}
+	}
+
+	if !from.Before(to) || to.Sub(from)/step > maxPoints {
+		return nil, errInvalidRange
+	}
+
+	points, err := srv.metricRepository.History(ctx, metricType, metricName, labels, from, to, step)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	resp := models.History{
+		ID:     metricName,
+		MType:  metricType,
+		Labels: labels,
+		From:   from,
+		To:     to,
+		Step:   step.String(),
+		Points: make([]models.HistoryPoint, 0, len(points)),
+	}
+
+	for _, p := range points {
+		resp.Points = append(resp.Points, models.HistoryPoint{
+			TS:    p.TS,
+			Min:   p.Min,
+			Max:   p.Max,
+			Avg:   p.Avg,
+			Last:  p.Last,
+			Count: p.Count,
+		})
+	}
+
+	return &resp, nil
+}
+
+// parseTime accepts either RFC 3339 or unix seconds.
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
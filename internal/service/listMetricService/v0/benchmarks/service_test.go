@@ -43,9 +43,9 @@ func BenchmarkListMetricService(b *testing.B) {
 		},
 	}, nil)
 
-	call := func(ctx context.Context, _ string) (index string, err error) {
+	call := func(ctx context.Context, _ string, _ pkg.Labels, _ string) (index string, err error) {
 		srv := v0.New(mockRepo)
-		return srv.Do(ctx, "")
+		return srv.Do(ctx, "", nil, "")
 	}
 	h := handler.DoListMetricResponse(call)
 
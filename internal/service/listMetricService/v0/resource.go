@@ -1,33 +1,125 @@
 package v0
 
-import "github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
 
 type MetricItem struct {
-	Name  string `json:"name"`
-	Value any    `json:"value"`
+	Name   string     `json:"name"`
+	Labels pkg.Labels `json:"labels,omitempty"`
+	Value  any        `json:"value"`
+}
+
+// MetricGroup holds the metrics sharing the same value of the group_by label.
+type MetricGroup struct {
+	Group string       `json:"group"`
+	Items []MetricItem `json:"items"`
 }
 
 type MetricData struct {
-	Counters []entities.CounterItem `json:"counters"`
-	Gauges   []entities.GaugeItem   `json:"gauges"`
+	Counters   []entities.CounterItem   `json:"counters"`
+	Gauges     []entities.GaugeItem     `json:"gauges"`
+	Histograms []entities.HistogramItem `json:"histograms"`
 }
 
 func (m MetricData) convertToModel() []MetricItem {
-	res := make([]MetricItem, 0, len(m.Counters)+len(m.Gauges))
+	res := make([]MetricItem, 0, len(m.Counters)+len(m.Gauges)+len(m.Histograms))
 
 	for _, item := range m.Counters {
 		res = append(res, MetricItem{
-			Name:  item.MetricName,
-			Value: item.MetricValue,
+			Name:   item.Labels.Series(item.MetricName),
+			Labels: item.Labels,
+			Value:  item.MetricValue,
 		})
 	}
 
 	for _, item := range m.Gauges {
 		res = append(res, MetricItem{
-			Name:  item.MetricName,
-			Value: item.MetricValue,
+			Name:   item.Labels.Series(item.MetricName),
+			Labels: item.Labels,
+			Value:  item.MetricValue,
+		})
+	}
+
+	for _, item := range m.Histograms {
+		res = append(res, MetricItem{
+			Name:   item.Labels.Series(item.MetricName),
+			Labels: item.Labels,
+			Value:  fmt.Sprintf("count=%d sum=%s", item.Count, strconv.FormatFloat(item.Sum, 'f', -1, 64)),
 		})
 	}
 
 	return res
 }
+
+func (m MetricData) convertToMetrics() []models.Metric {
+	res := make([]models.Metric, 0, len(m.Counters)+len(m.Gauges)+len(m.Histograms))
+
+	for _, item := range m.Counters {
+		res = append(res, models.Metric{
+			ID:     item.MetricName,
+			MType:  pkg.MetricTypeCounter,
+			Labels: item.Labels,
+			Delta:  pkg.ToPtr(item.MetricValue),
+		})
+	}
+
+	for _, item := range m.Gauges {
+		res = append(res, models.Metric{
+			ID:     item.MetricName,
+			MType:  pkg.MetricTypeGauge,
+			Labels: item.Labels,
+			Value:  pkg.ToPtr(item.MetricValue),
+		})
+	}
+
+	for _, item := range m.Histograms {
+		res = append(res, models.Metric{
+			ID:     item.MetricName,
+			MType:  pkg.MetricTypeHistogram,
+			Labels: item.Labels,
+			Histogram: &pkg.Histogram{
+				Bounds: item.Bounds,
+				Counts: item.Counts,
+				Sum:    item.Sum,
+				Count:  item.Count,
+			},
+		})
+	}
+
+	return res
+}
+
+func groupItems(list []MetricItem, groupBy string) []MetricGroup {
+	if groupBy == "" {
+		return []MetricGroup{{Items: list}}
+	}
+
+	groups := make([]MetricGroup, 0)
+	index := make(map[string]int)
+
+	for _, item := range list {
+		group := groupBy + "=" + strconv.Quote(item.Labels[groupBy])
+
+		i, ok := index[group]
+		if !ok {
+			i = len(groups)
+			index[group] = i
+			groups = append(groups, MetricGroup{Group: group})
+		}
+		groups[i].Items = append(groups[i].Items, item)
+	}
+
+	slices.SortFunc(groups, func(a, b MetricGroup) int {
+		return strings.Compare(a.Group, b.Group)
+	})
+
+	return groups
+}
@@ -7,6 +7,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
@@ -20,13 +22,20 @@ func New(metricRepo MetricRepository) *Service {
 	}
 }
 
-func (srv *Service) Do(ctx context.Context, html string) (index string, err error) {
+func (srv *Service) Do(
+	ctx context.Context, html string, filter pkg.Labels, groupBy string,
+) (index string, err error) {
+	ctx, span := tracing.Start(ctx, "listMetricService.Do")
+	defer func() { tracing.End(span, err) }()
+
 	resp, err := srv.metricRepository.List(ctx)
 	if err != nil {
 		return "", pkg.ErrInternalServer.SetInfo(err.Error())
 	}
 
-	list := resp.convertToModel()
+	list := pkg.SliceFilter(resp.convertToModel(), func(item MetricItem) bool {
+		return item.Labels.Match(filter)
+	})
 
 	slices.SortFunc(list, func(a, b MetricItem) int {
 		return strings.Compare(a.Name, b.Name)
@@ -36,10 +45,27 @@ func (srv *Service) Do(ctx context.Context, html string) (index string, err erro
 
 	buffer := bytes.Buffer{}
 
-	err = tmpl.Execute(&buffer, list)
+	err = tmpl.Execute(&buffer, groupItems(list, groupBy))
 	if err != nil {
 		return "", err
 	}
 
 	return buffer.String(), nil
 }
+
+func (srv *Service) List(ctx context.Context, filter pkg.Labels) (metrics []models.Metric, err error) {
+	resp, err := srv.metricRepository.List(ctx)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	list := pkg.SliceFilter(resp.convertToMetrics(), func(metric models.Metric) bool {
+		return metric.Labels.Match(filter)
+	})
+
+	slices.SortFunc(list, func(a, b models.Metric) int {
+		return strings.Compare(a.Labels.Series(a.ID), b.Labels.Series(b.ID))
+	})
+
+	return list, nil
+}
@@ -8,10 +8,7 @@ import (
 )
 
 type OutboxRepository interface {
+	OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) (err error)
 	OutboxGetNext(ctx context.Context, destination models.OutboxDestination, segment string, limit int) (resp []entities.Outbox, err error)
-	OutboxCommit(ctx context.Context, okIds []entities.OutboxID, failedIds []entities.OutboxID, segment string) (err error)
-}
-
-type FileRepository interface {
-	FileAppend(ctx context.Context, line []byte) error
+	OutboxCommit(ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string) (err error)
 }
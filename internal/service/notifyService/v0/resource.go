@@ -0,0 +1,168 @@
+package v0
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const defaultRepeatInterval = 4 * time.Hour
+
+const textTemplate = `[{{ upper .Status }}{{ if .Firing }}:{{ len .Firing }}{{ end }}] {{ .Rule }}` +
+	`{{ range .Alerts }}
+- {{ .Metric }} = {{ .Value }} ({{ .Status }}, active since {{ .ActiveSince.Format "2006-01-02T15:04:05Z07:00" }}){{ end }}`
+
+var formatTemplates = map[string]string{
+	models.SlackFormat:    `{"text":{{ json .Text }}}`,
+	models.TelegramFormat: `{"chat_id":{{ json .ChatID }},"text":{{ json .Text }}}`,
+	models.JSONFormat:     `{{ json . }}`,
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	},
+	"upper": func(v any) string {
+		return strings.ToUpper(fmt.Sprint(v))
+	},
+	"join": strings.Join,
+}
+
+var summary = template.Must(template.New("text").Funcs(templateFuncs).Parse(textTemplate))
+
+// templateData is what the receiver templates are executed with.
+type templateData struct {
+	Receiver    string              `json:"receiver"`
+	Status      models.AlertStatus  `json:"status"`
+	Rule        string              `json:"rule"`
+	GroupLabels pkg.Labels          `json:"group_labels,omitempty"`
+	ChatID      string              `json:"-"`
+	Text        string              `json:"text"`
+	Alerts      []models.AlertEvent `json:"alerts"`
+	Firing      []models.AlertEvent `json:"-"`
+	Resolved    []models.AlertEvent `json:"-"`
+}
+
+type receiver struct {
+	models.Receiver
+	body   *template.Template
+	repeat time.Duration
+}
+
+func newReceiver(r models.Receiver) (receiver, error) {
+	if r.Name == "" {
+		return receiver{}, fmt.Errorf("receiver has no name")
+	}
+
+	text := r.Template
+	if text == "" {
+		format := r.Format
+		if format == "" {
+			format = models.JSONFormat
+		}
+
+		var ok bool
+		if text, ok = formatTemplates[format]; !ok {
+			return receiver{}, fmt.Errorf("receiver %q: unknown format %q", r.Name, r.Format)
+		}
+	}
+
+	body, err := template.New(r.Name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return receiver{}, fmt.Errorf("receiver %q: %w", r.Name, err)
+	}
+
+	repeat := defaultRepeatInterval
+	if r.RepeatInterval != "" {
+		if repeat, err = time.ParseDuration(r.RepeatInterval); err != nil || repeat <= 0 {
+			return receiver{}, fmt.Errorf("receiver %q: invalid repeat interval %q", r.Name, r.RepeatInterval)
+		}
+	}
+
+	return receiver{Receiver: r, body: body, repeat: repeat}, nil
+}
+
+func (r receiver) render(data templateData) (string, error) {
+	data.Receiver, data.ChatID = r.Name, r.ChatID
+
+	var b strings.Builder
+	if err := summary.Execute(&b, data); err != nil {
+		return "", err
+	}
+	data.Text = b.String()
+
+	b.Reset()
+	if err := r.body.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// groupKey is the rule with the values of the group_by labels.
+func (r receiver) groupKey(event models.AlertEvent) (string, pkg.Labels) {
+	var labels pkg.Labels
+	for _, name := range r.GroupBy {
+		if labels == nil {
+			labels = make(pkg.Labels, len(r.GroupBy))
+		}
+		labels[name] = event.Labels[name]
+	}
+	return event.Rule + labels.String(), labels
+}
+
+func alertKey(event models.AlertEvent) string {
+	return event.Rule + "|" + event.MetricType + "|" + event.Metric
+}
+
+type group struct {
+	labels pkg.Labels
+	rule   string
+	alerts []models.AlertEvent
+}
+
+// fingerprint identifies the firing alerts of the group, a change of them
+// is notified immediately.
+func (g group) fingerprint() string {
+	parts := make([]string, 0, len(g.alerts))
+	for _, alert := range g.alerts {
+		if alert.Status == models.AlertFiring {
+			parts = append(parts, alertKey(alert))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (g group) data() templateData {
+	data := templateData{
+		Status:      models.AlertResolved,
+		Rule:        g.rule,
+		GroupLabels: g.labels,
+		Alerts:      g.alerts,
+	}
+	for _, alert := range g.alerts {
+		if alert.Status == models.AlertFiring {
+			data.Status = models.AlertFiring
+			data.Firing = append(data.Firing, alert)
+		} else {
+			data.Resolved = append(data.Resolved, alert)
+		}
+	}
+	return data
+}
+
+type groupState struct {
+	fingerprint string
+	firing      bool
+	sentAt      time.Time
+}
@@ -0,0 +1,203 @@
+package v0
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	silenceService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/silenceService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const segment = ""
+
+type Service struct {
+	receivers      []receiver
+	outboxRepo     OutboxRepository
+	silenceService *silenceService.Service
+	now            func() time.Time
+
+	mx     sync.Mutex
+	alerts map[string]models.AlertEvent
+	groups map[string]groupState
+}
+
+func New(receivers []models.Receiver, outboxRepo OutboxRepository, silenceService *silenceService.Service) *Service {
+	parsed := make([]receiver, 0, len(receivers))
+	for _, r := range receivers {
+		rcv, err := newReceiver(r)
+		if err != nil {
+			log.Printf("receiver not ok, %s\n", err.Error())
+			continue
+		}
+		parsed = append(parsed, rcv)
+	}
+
+	return &Service{
+		receivers:      parsed,
+		outboxRepo:     outboxRepo,
+		silenceService: silenceService,
+		now:            time.Now,
+		alerts:         make(map[string]models.AlertEvent),
+		groups:         make(map[string]groupState),
+	}
+}
+
+// Do collects the alert events and queues the notifications of the groups
+// that changed or are due to repeat.
+func (srv *Service) Do(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "notifyService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	items, err := srv.outboxRepo.OutboxGetNext(ctx, models.NotifyOutboxDestination, segment, 100)
+	if err != nil {
+		return err
+	}
+
+	srv.mx.Lock()
+	defer srv.mx.Unlock()
+
+	alerts := maps.Clone(srv.alerts)
+	completed := make([]entities.OutboxID, 0, len(items))
+	for _, item := range items {
+		completed = append(completed, item.ID)
+
+		var event models.AlertEvent
+		if err := json.Unmarshal(item.Payload, &event); err != nil {
+			log.Printf("outbox payload not ok {dest=%v, id=%v, err=%v}\n", models.NotifyOutboxDestination, item.ID, err.Error())
+			continue
+		}
+		observe(alerts, event)
+	}
+
+	if len(srv.receivers) == 0 {
+		srv.alerts = make(map[string]models.AlertEvent)
+		return srv.commit(ctx, completed)
+	}
+
+	ts := srv.now()
+
+	silences, err := srv.silenceService.Active(ctx, ts)
+	if err != nil {
+		return err
+	}
+
+	notifications, groups := srv.dispatch(ts, alerts, silences)
+
+	outboxes := make([]entities.Outbox, 0, len(notifications))
+	for _, notification := range notifications {
+		outboxes = append(outboxes, entities.Outbox{
+			Destination: string(models.WebhookOutboxDestination),
+			Segment:     segment,
+			Payload:     pkg.MustJSON(notification),
+		})
+	}
+
+	if err := srv.outboxRepo.OutboxAdd(ctx, outboxes, segment); err != nil {
+		return err
+	}
+
+	// every resolved alert has been notified
+	maps.DeleteFunc(alerts, func(_ string, event models.AlertEvent) bool {
+		return event.Status == models.AlertResolved
+	})
+	srv.alerts, srv.groups = alerts, groups
+
+	return srv.commit(ctx, completed)
+}
+
+func (srv *Service) commit(ctx context.Context, completed []entities.OutboxID) error {
+	if err := srv.outboxRepo.OutboxCommit(ctx, completed, nil, segment); err != nil {
+		log.Printf("outbox commit failure {dest=%v, err=%v}\n", models.NotifyOutboxDestination, err.Error())
+	}
+	return nil
+}
+
+func observe(alerts map[string]models.AlertEvent, event models.AlertEvent) {
+	key := alertKey(event)
+
+	switch event.Status {
+	case models.AlertFiring:
+		alerts[key] = event
+	case models.AlertResolved:
+		// nothing was notified about an unknown alert
+		if _, ok := alerts[key]; ok {
+			alerts[key] = event
+		}
+	}
+}
+
+func (srv *Service) dispatch(
+	ts time.Time, alerts map[string]models.AlertEvent, silences []models.Silence,
+) ([]models.Notification, map[string]groupState) {
+	keys := slices.Sorted(maps.Keys(alerts))
+
+	var notifications []models.Notification
+	states := make(map[string]groupState, len(srv.groups))
+
+	for _, r := range srv.receivers {
+		groups := make(map[string]*group)
+		for _, key := range keys {
+			event := alerts[key]
+			if !event.Labels.Match(r.Match) || muted(silences, event, ts) {
+				continue
+			}
+
+			groupKey, labels := r.groupKey(event)
+			g, ok := groups[groupKey]
+			if !ok {
+				g = &group{labels: labels, rule: event.Rule}
+				groups[groupKey] = g
+			}
+			g.alerts = append(g.alerts, event)
+		}
+
+		for _, groupKey := range slices.Sorted(maps.Keys(groups)) {
+			g := groups[groupKey]
+			stateKey := r.Name + "|" + groupKey
+
+			state, notified := srv.groups[stateKey]
+			fingerprint := g.fingerprint()
+			data := g.data()
+			firing := data.Status == models.AlertFiring
+
+			switch {
+			case !firing && !state.firing:
+				// resolved before anything was notified
+				continue
+			case fingerprint == state.fingerprint && ts.Sub(state.sentAt) < r.repeat:
+				states[stateKey] = state
+				continue
+			}
+
+			body, err := r.render(data)
+			if err != nil {
+				log.Printf("notification not ok {receiver=%v, group=%v, err=%v}\n", r.Name, groupKey, err.Error())
+				if notified {
+					states[stateKey] = state
+				}
+				continue
+			}
+
+			notifications = append(notifications, models.Notification{Receiver: r.Name, Body: body})
+			if firing {
+				states[stateKey] = groupState{fingerprint: fingerprint, firing: true, sentAt: ts}
+			}
+		}
+	}
+
+	return notifications, states
+}
+
+func muted(silences []models.Silence, event models.AlertEvent, ts time.Time) bool {
+	return slices.ContainsFunc(silences, func(s models.Silence) bool {
+		return s.Mutes(event, ts)
+	})
+}
@@ -0,0 +1,128 @@
+package v0
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/silence"
+	silenceService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/silenceService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type outboxRepositoryMock struct {
+	pending []entities.Outbox
+	added   []entities.Outbox
+}
+
+func (m *outboxRepositoryMock) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) error {
+	m.added = append(m.added, items...)
+	return nil
+}
+
+func (m *outboxRepositoryMock) OutboxGetNext(
+	ctx context.Context, destination models.OutboxDestination, segment string, limit int,
+) ([]entities.Outbox, error) {
+	items := m.pending
+	m.pending = nil
+	return items, nil
+}
+
+func (m *outboxRepositoryMock) OutboxCommit(
+	ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string,
+) error {
+	return nil
+}
+
+func (m *outboxRepositoryMock) push(events ...models.AlertEvent) {
+	for _, event := range events {
+		m.pending = append(m.pending, entities.Outbox{
+			Destination: string(models.NotifyOutboxDestination),
+			Payload:     pkg.MustJSON(event),
+		})
+	}
+}
+
+func (m *outboxRepositoryMock) notifications(t *testing.T) []models.Notification {
+	var notifications []models.Notification
+	for _, item := range m.added {
+		require.Equal(t, string(models.WebhookOutboxDestination), item.Destination)
+
+		var notification models.Notification
+		require.NoError(t, json.Unmarshal(item.Payload, &notification))
+		notifications = append(notifications, notification)
+	}
+	m.added = nil
+	return notifications
+}
+
+func event(status models.AlertStatus, host string) models.AlertEvent {
+	labels := pkg.Labels{"host": host}
+	return models.AlertEvent{
+		Status:      status,
+		Rule:        "HeapAlloc > 100",
+		MetricType:  pkg.MetricTypeGauge,
+		Metric:      labels.Series("HeapAlloc"),
+		MetricName:  "HeapAlloc",
+		Labels:      labels,
+		Value:       200,
+		ActiveSince: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestService_Do(t *testing.T) {
+	outboxRepo := &outboxRepositoryMock{}
+	silences := silenceService.New(silence.New(nil))
+
+	srv := New([]models.Receiver{
+		{Name: "slack", Format: models.SlackFormat, RepeatInterval: "1h"},
+		{Name: "custom", Template: `{{ .Status }}:{{ len .Alerts }}`, GroupBy: []string{"host"}, Match: pkg.Labels{"host": "a"}},
+		{Name: "broken", Format: "unknown"},
+	}, outboxRepo, silences)
+
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.now = func() time.Time { return ts }
+
+	do := func(after time.Duration, events ...models.AlertEvent) []models.Notification {
+		ts = ts.Add(after)
+		outboxRepo.push(events...)
+		require.NoError(t, srv.Do(context.Background()))
+		return outboxRepo.notifications(t)
+	}
+
+	notifications := do(0, event(models.AlertFiring, "a"), event(models.AlertFiring, "b"))
+	require.Len(t, notifications, 2)
+	assert.Equal(t, "slack", notifications[0].Receiver)
+	assert.JSONEq(t, `{"text":"[FIRING:2] HeapAlloc > 100\n`+
+		`- HeapAlloc{host=\"a\"} = 200 (firing, active since 2026-01-01T00:00:00Z)\n`+
+		`- HeapAlloc{host=\"b\"} = 200 (firing, active since 2026-01-01T00:00:00Z)"}`, notifications[0].Body)
+	assert.Equal(t, models.Notification{Receiver: "custom", Body: "firing:1"}, notifications[1])
+
+	assert.Empty(t, do(time.Minute, event(models.AlertFiring, "a")), "deduplicated")
+
+	notifications = do(time.Hour)
+	require.Len(t, notifications, 1, "repeated")
+	assert.Equal(t, "slack", notifications[0].Receiver)
+
+	_, err := silences.Add(context.Background(), models.Silence{
+		Matchers: pkg.Labels{"host": "b"}, StartsAt: ts, EndsAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	notifications = do(time.Minute)
+	require.Len(t, notifications, 1, "silenced alert left the group")
+	assert.Contains(t, notifications[0].Body, "[FIRING:1]")
+
+	notifications = do(time.Minute, event(models.AlertResolved, "a"), event(models.AlertResolved, "c"))
+	require.Len(t, notifications, 2)
+	assert.Contains(t, notifications[0].Body, "[RESOLVED] HeapAlloc > 100")
+	assert.Equal(t, models.Notification{Receiver: "custom", Body: "resolved:1"}, notifications[1])
+
+	assert.Empty(t, do(time.Hour), "resolved alerts are notified once")
+}
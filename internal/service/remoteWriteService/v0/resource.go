@@ -0,0 +1,90 @@
+package v0
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/api/prompb"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const labelName = "__name__"
+
+// suffixes of the series produced by classic histograms and summaries.
+var cumulativeSuffixes = []string{"_bucket", "_sum", "_count"}
+
+// candidate is a series of the write request with a supported type.
+type candidate struct {
+	id      string
+	name    string
+	labels  pkg.Labels
+	mtype   string
+	samples []*prompb.Sample
+}
+
+// stage is the counter baseline of a series before the request moved it.
+type stage struct {
+	prev  float64
+	seen  bool
+	value float64
+}
+
+// rejected reports whether the storage refused the metrics themselves,
+// as opposed to failing to store them.
+func rejected(err error) bool {
+	var e *pkg.Error
+	return errors.As(err, &e) && e.HTTPStatus() < http.StatusInternalServerError
+}
+
+// seriesLabels splits the series labels into the metric name
+// and the remaining label set.
+func seriesLabels(labels []*prompb.Label) (name string, rest pkg.Labels) {
+	for _, label := range labels {
+		if label.GetName() == labelName {
+			name = label.GetValue()
+			continue
+		}
+		if rest == nil {
+			rest = make(pkg.Labels, len(labels))
+		}
+		rest[label.GetName()] = label.GetValue()
+	}
+
+	return name, rest
+}
+
+// metricType resolves the storage type of a series from the write request
+// metadata; ok is false for families the receiver cannot represent.
+func metricType(name string, metadata map[string]prompb.MetricMetadata_MetricType) (mtype string, ok bool) {
+	family, found := metadata[name]
+	if !found {
+		for _, suffix := range cumulativeSuffixes {
+			if base, cut := strings.CutSuffix(name, suffix); cut {
+				if family, found = metadata[base]; found {
+					break
+				}
+			}
+		}
+	}
+	if !found && strings.HasSuffix(name, "_total") {
+		family, found = prompb.MetricMetadata_COUNTER, true
+	}
+
+	switch family {
+	case prompb.MetricMetadata_COUNTER,
+		prompb.MetricMetadata_HISTOGRAM:
+		return pkg.MetricTypeCounter, true
+	case prompb.MetricMetadata_SUMMARY:
+		// quantiles are point-in-time values, only _sum and _count accumulate
+		if strings.HasSuffix(name, "_sum") || strings.HasSuffix(name, "_count") {
+			return pkg.MetricTypeCounter, true
+		}
+		return pkg.MetricTypeGauge, true
+	case prompb.MetricMetadata_UNKNOWN,
+		prompb.MetricMetadata_GAUGE:
+		return pkg.MetricTypeGauge, true
+	}
+
+	return "", false
+}
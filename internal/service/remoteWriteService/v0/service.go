@@ -0,0 +1,246 @@
+package v0
+
+import (
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/api/prompb"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+const (
+	reasonNoName        = "missing __name__ label"
+	reasonHistogram     = "native histograms are not supported"
+	reasonType          = "unsupported metric type"
+	reasonNoSamples     = "no finite samples"
+	reasonInvalidMetric = "invalid metric"
+	reasonConflict      = "conflicts with the stored metric"
+)
+
+type Service struct {
+	mtx                sync.Mutex
+	updateBatchService *updateBatchService.Service
+	metricRepository   MetricRepository
+	// counters holds the last cumulative value seen per counter series,
+	// Prometheus sends running totals while the storage expects deltas.
+	// The baseline lives only in memory, so after a restart the first sample
+	// of a series which is stored already becomes its baseline instead of
+	// adding its whole total again.
+	counters map[string]float64
+}
+
+func New(updateBatchService *updateBatchService.Service, metricRepo MetricRepository) *Service {
+	return &Service{
+		updateBatchService: updateBatchService,
+		metricRepository:   metricRepo,
+		counters:           make(map[string]float64),
+	}
+}
+
+func (srv *Service) Do(
+	ctx context.Context, ipAddress string, request *prompb.WriteRequest,
+) (report *models.RemoteWriteReport, err error) {
+	ctx, span := tracing.Start(ctx, "remoteWriteService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	metadata := make(map[string]prompb.MetricMetadata_MetricType, len(request.GetMetadata()))
+	for _, meta := range request.GetMetadata() {
+		metadata[meta.GetMetricFamilyName()] = meta.GetType()
+	}
+
+	resp := models.RemoteWriteReport{Rejected: make([]models.RejectedSeries, 0)}
+	reject := func(id, reason string) {
+		resp.Rejected = append(resp.Rejected, models.RejectedSeries{Series: id, Reason: reason})
+	}
+
+	candidates := make([]candidate, 0, len(request.GetTimeseries()))
+	for _, series := range request.GetTimeseries() {
+		name, labels := seriesLabels(series.GetLabels())
+		id := labels.Series(name)
+
+		if name == "" {
+			reject(id, reasonNoName)
+			continue
+		}
+		if len(series.GetHistograms()) > 0 {
+			reject(id, reasonHistogram)
+			continue
+		}
+
+		mtype, ok := metricType(name, metadata)
+		if !ok {
+			reject(id, reasonType)
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			id: id, name: name, labels: labels, mtype: mtype, samples: series.GetSamples(),
+		})
+	}
+
+	stored, err := srv.storedCounters(ctx, candidates)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	metrics := make([]models.Metric, 0, len(candidates))
+	staged := make(map[string]stage)
+
+	srv.mtx.Lock()
+	for _, c := range candidates {
+		metric, ok := srv.toMetric(c, stored[c.id], staged)
+		if !ok {
+			reject(c.id, reasonNoSamples)
+			continue
+		}
+		if err := srv.updateBatchService.Check(metric); err != nil {
+			srv.rollback(staged, c.id)
+			reject(c.id, reasonInvalidMetric)
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+	srv.mtx.Unlock()
+
+	ts := time.Now()
+	err = srv.updateBatchService.Do(ctx, ts, models.Request{IPAddress: ipAddress, Metrics: metrics})
+	if !rejected(err) {
+		if err != nil {
+			srv.rollbackLocked(staged, slices.Collect(maps.Keys(staged))...)
+			return nil, err
+		}
+		resp.Accepted = len(metrics)
+		return &resp, nil
+	}
+
+	// some series conflict with the stored metrics, they are rejected
+	// one by one while the rest is still stored
+	for i, metric := range metrics {
+		id := metric.Labels.Series(metric.ID)
+
+		err := srv.updateBatchService.Do(ctx, ts, models.Request{IPAddress: ipAddress, Metrics: []models.Metric{metric}})
+		if rejected(err) {
+			srv.rollbackLocked(staged, id)
+			reject(id, reasonConflict)
+			continue
+		}
+		if err != nil {
+			ids := make([]string, 0, len(metrics)-i)
+			for _, metric := range metrics[i:] {
+				ids = append(ids, metric.Labels.Series(metric.ID))
+			}
+			srv.rollbackLocked(staged, ids...)
+			return nil, err
+		}
+		resp.Accepted++
+	}
+
+	return &resp, nil
+}
+
+// storedCounters reports which of the counter series unknown to the service
+// are stored already. The lookups run outside of the lock.
+func (srv *Service) storedCounters(ctx context.Context, candidates []candidate) (map[string]bool, error) {
+	lookup := make([]candidate, 0)
+
+	srv.mtx.Lock()
+	for _, c := range candidates {
+		if _, seen := srv.counters[c.id]; !seen && c.mtype == pkg.MetricTypeCounter {
+			lookup = append(lookup, c)
+		}
+	}
+	srv.mtx.Unlock()
+
+	stored := make(map[string]bool, len(lookup))
+	for _, c := range lookup {
+		if _, done := stored[c.id]; done {
+			continue
+		}
+		_, ok, err := srv.metricRepository.GetCounter(ctx, c.name, c.labels)
+		if err != nil {
+			return nil, err
+		}
+		stored[c.id] = ok
+	}
+
+	return stored, nil
+}
+
+// toMetric folds the samples of one series into a single metric: the last
+// value for gauges and the summed increase for counters. Counter totals move
+// the baseline right away, staged keeps the previous one for the rollback.
+// The caller holds srv.mtx.
+func (srv *Service) toMetric(c candidate, stored bool, staged map[string]stage) (metric models.Metric, ok bool) {
+	metric = models.Metric{ID: c.name, MType: c.mtype, Labels: c.labels}
+
+	var delta int64
+	for _, sample := range c.samples {
+		value := sample.GetValue()
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		ok = true
+
+		if c.mtype == pkg.MetricTypeGauge {
+			metric.Value = pkg.ToPtr(value)
+			continue
+		}
+
+		last, seen := srv.counters[c.id]
+		st, found := staged[c.id]
+		if !found {
+			st.prev, st.seen = last, seen
+		}
+		if !seen {
+			// the stored series was written before the restart, its first sample is the baseline
+			last, seen = value, stored
+		}
+		switch {
+		case !seen, value < last:
+			// first sample of a new series or counter reset
+			delta += int64(value)
+		default:
+			delta += int64(value) - int64(last)
+		}
+		srv.counters[c.id] = value
+		st.value = value
+		staged[c.id] = st
+	}
+
+	if ok && c.mtype == pkg.MetricTypeCounter {
+		metric.Delta = pkg.ToPtr(delta)
+	}
+	return metric, ok
+}
+
+// rollback restores the baselines of the series which were not stored,
+// unless a later request has moved them on already. The caller holds srv.mtx.
+func (srv *Service) rollback(staged map[string]stage, ids ...string) {
+	for _, id := range ids {
+		st, ok := staged[id]
+		if !ok || srv.counters[id] != st.value {
+			continue
+		}
+		if st.seen {
+			srv.counters[id] = st.prev
+		} else {
+			delete(srv.counters, id)
+		}
+		delete(staged, id)
+	}
+}
+
+func (srv *Service) rollbackLocked(staged map[string]stage, ids ...string) {
+	srv.mtx.Lock()
+	defer srv.mtx.Unlock()
+
+	srv.rollback(staged, ids...)
+}
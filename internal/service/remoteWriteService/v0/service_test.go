@@ -0,0 +1,94 @@
+package v0
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/api/prompb"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/encode"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/inmemory"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/repository/storage/pg"
+	updateBatchService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateBatchService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+type outboxRepositoryMock struct{}
+
+func (m *outboxRepositoryMock) OutboxAdd(ctx context.Context, items []entities.Outbox, segment string) error {
+	return nil
+}
+
+func TestService_DoCounterDelta(t *testing.T) {
+	repo := pg.New(nil, inmemory.New(encode.New()), &outboxRepositoryMock{})
+	batchService := updateBatchService.New(1, repo)
+
+	write := func(srv *Service, value float64) int64 {
+		t.Helper()
+
+		_, err := srv.Do(context.Background(), "", &prompb.WriteRequest{
+			Timeseries: []*prompb.TimeSeries{{
+				Labels:  []*prompb.Label{{Name: labelName, Value: "requests_total"}},
+				Samples: []*prompb.Sample{{Value: value}},
+			}},
+		})
+		require.NoError(t, err)
+
+		item, ok, err := repo.GetCounter(context.Background(), "requests_total", nil)
+		require.NoError(t, err)
+		require.True(t, ok)
+		return item.MetricValue
+	}
+
+	srv := New(batchService, repo)
+	assert.Equal(t, int64(10), write(srv, 10))
+	assert.Equal(t, int64(15), write(srv, 15))
+	assert.Equal(t, int64(18), write(srv, 3), "counter reset")
+
+	// the restarted service takes the first sample of the stored series as the baseline
+	srv = New(batchService, repo)
+	assert.Equal(t, int64(18), write(srv, 40))
+	assert.Equal(t, int64(20), write(srv, 42))
+}
+
+func TestService_DoConflict(t *testing.T) {
+	repo := pg.New(nil, inmemory.New(encode.New()), &outboxRepositoryMock{})
+	batchService := updateBatchService.New(1, repo)
+
+	// the series is stored as a gauge already
+	err := batchService.Do(context.Background(), time.Now(), models.Request{Metrics: []models.Metric{
+		{ID: "jobs_total", MType: pkg.MetricTypeGauge, Value: pkg.ToPtr(1.0)},
+	}})
+	require.NoError(t, err)
+
+	srv := New(batchService, repo)
+	report, err := srv.Do(context.Background(), "", &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: labelName, Value: "jobs_total"}},
+				Samples: []*prompb.Sample{{Value: 7}},
+			},
+			{
+				Labels:  []*prompb.Label{{Name: labelName, Value: "requests_total"}},
+				Samples: []*prompb.Sample{{Value: 5}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, []models.RejectedSeries{{Series: "jobs_total", Reason: reasonConflict}}, report.Rejected)
+
+	item, ok, err := repo.GetCounter(context.Background(), "requests_total", nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(5), item.MetricValue)
+
+	assert.NotContains(t, srv.counters, "jobs_total", "the baseline of the rejected series is rolled back")
+	assert.Equal(t, 5.0, srv.counters["requests_total"])
+}
@@ -0,0 +1,11 @@
+package v0
+
+import "time"
+
+type Config struct {
+	// TTL drops the metrics not updated for longer, zero keeps them forever.
+	TTL time.Duration `env:"TTL" json:"ttl"`
+	// HistoryTTL drops the daily partitions of the history samples older than it,
+	// zero keeps them forever.
+	HistoryTTL time.Duration `env:"HISTORY_TTL" json:"historyTTL"`
+}
@@ -0,0 +1,10 @@
+package v0
+
+import (
+	"context"
+	"time"
+)
+
+type HistoryRepository interface {
+	DropHistory(ctx context.Context, before time.Time) (dropped []string, err error)
+}
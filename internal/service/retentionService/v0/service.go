@@ -0,0 +1,68 @@
+package v0
+
+import (
+	"context"
+	"log"
+	"time"
+
+	deleteMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/deleteMetricService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+)
+
+type Service struct {
+	config              Config
+	deleteMetricService *deleteMetricService.Service
+	historyRepository   HistoryRepository
+}
+
+func New(config Config, deleteMetricService *deleteMetricService.Service, historyRepo HistoryRepository) *Service {
+	return &Service{
+		config:              config,
+		deleteMetricService: deleteMetricService,
+		historyRepository:   historyRepo,
+	}
+}
+
+// Do drops the metrics whose last update is older than the TTL
+// and the history samples older than the history TTL.
+func (srv *Service) Do(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "retentionService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	if err := srv.expireMetrics(ctx); err != nil {
+		return err
+	}
+	return srv.expireHistory(ctx)
+}
+
+func (srv *Service) expireMetrics(ctx context.Context) error {
+	if srv.config.TTL <= 0 {
+		return nil
+	}
+
+	deleted, err := srv.deleteMetricService.Expire(ctx, time.Now().Add(-srv.config.TTL))
+	if err != nil {
+		return err
+	}
+	if len(deleted) > 0 {
+		log.Printf("retention deleted %d metrics {ttl=%v}\n", len(deleted), srv.config.TTL)
+	}
+
+	return nil
+}
+
+func (srv *Service) expireHistory(ctx context.Context) error {
+	if srv.config.HistoryTTL <= 0 {
+		return nil
+	}
+
+	dropped, err := srv.historyRepository.DropHistory(ctx, time.Now().Add(-srv.config.HistoryTTL))
+	if err != nil {
+		return err
+	}
+	if len(dropped) > 0 {
+		log.Printf("retention dropped history partitions {ttl=%v, partitions=%v}\n", srv.config.HistoryTTL, dropped)
+	}
+
+	return nil
+}
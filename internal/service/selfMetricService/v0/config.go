@@ -0,0 +1,7 @@
+package v0
+
+type Config struct {
+	// StoreEnabled also writes the operational metrics into the metric store,
+	// their names get the reserved pkg.SelfMetricPrefix.
+	StoreEnabled bool `env:"STORE_ENABLED" json:"storeEnabled"`
+}
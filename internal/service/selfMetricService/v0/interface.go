@@ -0,0 +1,23 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	listMetricService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/listMetricService/v0"
+)
+
+type SelfRepository interface {
+	List(ctx context.Context) (resp listMetricService.MetricData, err error)
+}
+
+type MetricRepository interface {
+	AddUpdateBatch(
+		ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+		outboxes []entities.Outbox, outboxSegment string,
+	) (ok bool, err error)
+}
+
+type OutboxRepository interface {
+	OutboxBacklog(ctx context.Context) (resp []entities.OutboxBacklog, err error)
+}
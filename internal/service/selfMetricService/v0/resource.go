@@ -0,0 +1,28 @@
+package v0
+
+import (
+	"slices"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+const outboxBacklogMetric = "outbox_backlog"
+
+// histogramDelta returns the observations made since the previous snapshot of the series,
+// or the whole histogram when the previous one is missing or has other buckets.
+// It reports false when there is nothing new.
+func histogramDelta(item entities.HistogramItem, prev *entities.HistogramItem) (entities.HistogramItem, bool) {
+	if prev == nil || !slices.Equal(item.Bounds, prev.Bounds) || len(item.Counts) != len(prev.Counts) || item.Count < prev.Count {
+		return item, item.Count > 0
+	}
+
+	delta := item
+	delta.Counts = make([]uint64, len(item.Counts))
+	for i, count := range item.Counts {
+		delta.Counts[i] = count - prev.Counts[i]
+	}
+	delta.Sum = item.Sum - prev.Sum
+	delta.Count = item.Count - prev.Count
+
+	return delta, delta.Count > 0
+}
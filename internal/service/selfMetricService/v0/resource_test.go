@@ -0,0 +1,65 @@
+package v0
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+func TestHistogramDelta(t *testing.T) {
+	item := entities.HistogramItem{
+		MetricName: "duration_seconds",
+		Bounds:     []float64{1, 2},
+		Counts:     []uint64{3, 2, 1},
+		Sum:        10,
+		Count:      6,
+	}
+
+	tests := []struct {
+		name   string
+		prev   *entities.HistogramItem
+		want   entities.HistogramItem
+		wantOk bool
+	}{
+		{
+			name:   "first run",
+			want:   item,
+			wantOk: true,
+		},
+		{
+			name: "since previous",
+			prev: &entities.HistogramItem{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 0}, Sum: 4, Count: 3},
+			want: entities.HistogramItem{
+				MetricName: "duration_seconds",
+				Bounds:     []float64{1, 2},
+				Counts:     []uint64{2, 0, 1},
+				Sum:        6,
+				Count:      3,
+			},
+			wantOk: true,
+		},
+		{
+			name:   "nothing new",
+			prev:   &item,
+			want:   entities.HistogramItem{MetricName: "duration_seconds", Bounds: []float64{1, 2}, Counts: []uint64{0, 0, 0}},
+			wantOk: false,
+		},
+		{
+			name:   "other buckets",
+			prev:   &entities.HistogramItem{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 1, Count: 1},
+			want:   item,
+			wantOk: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := histogramDelta(item, tt.prev)
+
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
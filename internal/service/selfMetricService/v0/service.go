@@ -0,0 +1,125 @@
+package v0
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
+)
+
+type Service struct {
+	config           Config
+	selfRepository   SelfRepository
+	metricRepository MetricRepository
+	outboxRepo       OutboxRepository
+
+	mx         sync.Mutex
+	backlogs   map[string]pkg.Labels
+	histograms map[string]entities.HistogramItem
+}
+
+func New(config Config, selfRepo SelfRepository, metricRepo MetricRepository, outboxRepo OutboxRepository) *Service {
+	return &Service{
+		config:           config,
+		selfRepository:   selfRepo,
+		metricRepository: metricRepo,
+		outboxRepo:       outboxRepo,
+		backlogs:         make(map[string]pkg.Labels),
+		histograms:       make(map[string]entities.HistogramItem),
+	}
+}
+
+// Do refreshes the outbox backlog and, when enabled, writes the operational metrics
+// into the metric store: counters and gauges are overwritten, histograms get the
+// observations made since the previous run.
+func (srv *Service) Do(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "selfMetricService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	srv.mx.Lock()
+	defer srv.mx.Unlock()
+
+	srv.recordBacklog(ctx)
+
+	if !srv.config.StoreEnabled {
+		return nil
+	}
+
+	resp, err := srv.selfRepository.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now()
+
+	counters := make([]entities.CounterItem, 0, len(resp.Counters))
+	for _, item := range resp.Counters {
+		item.MetricName = pkg.SelfMetricPrefix + item.MetricName
+		item.Op = pkg.OpSet
+		item.CreatedAt, item.UpdatedAt = ts, ts
+		counters = append(counters, item)
+	}
+
+	gauges := make([]entities.GaugeItem, 0, len(resp.Gauges))
+	for _, item := range resp.Gauges {
+		item.MetricName = pkg.SelfMetricPrefix + item.MetricName
+		item.Op = pkg.OpSet
+		item.CreatedAt, item.UpdatedAt = ts, ts
+		gauges = append(gauges, item)
+	}
+
+	histograms := make([]entities.HistogramItem, 0, len(resp.Histograms))
+	for _, item := range resp.Histograms {
+		key := item.Labels.Series(item.MetricName)
+
+		var prev *entities.HistogramItem
+		if x, ok := srv.histograms[key]; ok {
+			prev = &x
+		}
+
+		delta, ok := histogramDelta(item, prev)
+		if !ok {
+			continue
+		}
+		delta.MetricName = pkg.SelfMetricPrefix + delta.MetricName
+		delta.CreatedAt, delta.UpdatedAt = ts, ts
+		histograms = append(histograms, delta)
+	}
+
+	if _, err := srv.metricRepository.AddUpdateBatch(ctx, counters, gauges, histograms, nil, ""); err != nil {
+		return err
+	}
+
+	for _, item := range resp.Histograms {
+		srv.histograms[item.Labels.Series(item.MetricName)] = item
+	}
+
+	return nil
+}
+
+// recordBacklog sets the backlog gauge of every outbox destination and segment,
+// the ones drained since the previous run are set to zero. The backlog is left
+// as is while the outbox is unavailable.
+func (srv *Service) recordBacklog(ctx context.Context) {
+	backlogs, err := srv.outboxRepo.OutboxBacklog(ctx)
+	if err != nil {
+		return
+	}
+
+	counts := make(map[string]int64, len(backlogs))
+	for _, backlog := range backlogs {
+		labels := pkg.Labels{"destination": backlog.Destination, "segment": backlog.Segment}
+		key := labels.String()
+
+		srv.backlogs[key] = labels
+		counts[key] += backlog.Count
+	}
+
+	for key, labels := range srv.backlogs {
+		selfmetrics.Set(outboxBacklogMetric, labels, float64(counts[key]))
+	}
+}
@@ -0,0 +1,14 @@
+package v0
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+type SilenceRepository interface {
+	SilenceAdd(ctx context.Context, item entities.Silence) (resp entities.Silence, err error)
+	SilenceList(ctx context.Context, ts time.Time) (resp []entities.Silence, err error)
+	SilenceDelete(ctx context.Context, id entities.SilenceID) (ok bool, err error)
+}
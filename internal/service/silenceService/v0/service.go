@@ -0,0 +1,105 @@
+package v0
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+var (
+	errInvalidPeriod   *pkg.Error = pkg.ErrBadRequest.SetInfo("silence must end in the future and after it starts")
+	errInvalidMatchers *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid matchers")
+	errEmptySilence    *pkg.Error = pkg.ErrBadRequest.SetInfo("silence must match a rule, a metric or labels")
+)
+
+type Service struct {
+	silenceRepository SilenceRepository
+}
+
+func New(silenceRepo SilenceRepository) *Service {
+	return &Service{
+		silenceRepository: silenceRepo,
+	}
+}
+
+func (srv *Service) Add(ctx context.Context, silence models.Silence) (*models.Silence, error) {
+	ts := time.Now()
+
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = ts
+	}
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(ts) {
+		return nil, errInvalidPeriod
+	}
+	if !silence.Matchers.Valid() {
+		return nil, errInvalidMatchers
+	}
+	if silence.Rule == "" && silence.MetricName == "" && len(silence.Matchers) == 0 {
+		return nil, errEmptySilence
+	}
+
+	item, err := srv.silenceRepository.SilenceAdd(ctx, entities.Silence{
+		Rule:       silence.Rule,
+		MetricName: silence.MetricName,
+		Matchers:   silence.Matchers,
+		StartsAt:   silence.StartsAt,
+		EndsAt:     silence.EndsAt,
+		CreatedBy:  silence.CreatedBy,
+		Comment:    silence.Comment,
+		CreatedAt:  ts,
+	})
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	return pkg.ToPtr(toModel(item)), nil
+}
+
+// List returns the pending and active silences.
+func (srv *Service) List(ctx context.Context) ([]models.Silence, error) {
+	return srv.Active(ctx, time.Now())
+}
+
+// Active returns the silences that have not expired at ts.
+func (srv *Service) Active(ctx context.Context, ts time.Time) ([]models.Silence, error) {
+	items, err := srv.silenceRepository.SilenceList(ctx, ts)
+	if err != nil {
+		return nil, pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+
+	silences := make([]models.Silence, 0, len(items))
+	for _, item := range items {
+		silences = append(silences, toModel(item))
+	}
+
+	return silences, nil
+}
+
+func (srv *Service) Delete(ctx context.Context, id string) error {
+	ok, err := srv.silenceRepository.SilenceDelete(ctx, id)
+	if err != nil {
+		return pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+	if !ok {
+		return pkg.ErrNotFound.SetInfof("silence `%s` not found", id)
+	}
+
+	return nil
+}
+
+func toModel(item entities.Silence) models.Silence {
+	return models.Silence{
+		ID:         item.ID,
+		Rule:       item.Rule,
+		MetricName: item.MetricName,
+		Matchers:   item.Matchers,
+		StartsAt:   item.StartsAt,
+		EndsAt:     item.EndsAt,
+		CreatedBy:  item.CreatedBy,
+		Comment:    item.Comment,
+		CreatedAt:  item.CreatedAt,
+	}
+}
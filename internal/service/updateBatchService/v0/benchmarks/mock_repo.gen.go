@@ -37,16 +37,16 @@ func (m *MockMetricRepository) EXPECT() *MockMetricRepositoryMockRecorder {
 }
 
 // AddUpdateBatch mocks base method.
-func (m *MockMetricRepository) AddUpdateBatch(ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, outboxes []entities.Outbox, outboxSegment string) (bool, error) {
+func (m *MockMetricRepository) AddUpdateBatch(ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem, outboxes []entities.Outbox, outboxSegment string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUpdateBatch", ctx, counters, gauges, outboxes, outboxSegment)
+	ret := m.ctrl.Call(m, "AddUpdateBatch", ctx, counters, gauges, histograms, outboxes, outboxSegment)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddUpdateBatch indicates an expected call of AddUpdateBatch.
-func (mr *MockMetricRepositoryMockRecorder) AddUpdateBatch(ctx, counters, gauges, outboxes, outboxSegment interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) AddUpdateBatch(ctx, counters, gauges, histograms, outboxes, outboxSegment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUpdateBatch", reflect.TypeOf((*MockMetricRepository)(nil).AddUpdateBatch), ctx, counters, gauges, outboxes, outboxSegment)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUpdateBatch", reflect.TypeOf((*MockMetricRepository)(nil).AddUpdateBatch), ctx, counters, gauges, histograms, outboxes, outboxSegment)
 }
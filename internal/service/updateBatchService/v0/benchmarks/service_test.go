@@ -41,6 +41,7 @@ func BenchmarkUpdateBatchService(b *testing.B) {
 		gomock.Eq([]entities.CounterItem{{
 			MetricType:  counter.MType,
 			MetricName:  counter.ID,
+			Op:          pkg.OpInc,
 			MetricValue: 10,
 			CreatedAt:   now,
 			UpdatedAt:   now,
@@ -48,10 +49,12 @@ func BenchmarkUpdateBatchService(b *testing.B) {
 		gomock.Eq([]entities.GaugeItem{{
 			MetricType:  gauge.MType,
 			MetricName:  gauge.ID,
+			Op:          pkg.OpSet,
 			MetricValue: *gauge.Value,
 			CreatedAt:   now,
 			UpdatedAt:   now,
 		}}),
+		gomock.Len(0),
 		gomock.Any(),
 		"",
 	).AnyTimes().Return(true, nil)
@@ -62,7 +65,7 @@ func BenchmarkUpdateBatchService(b *testing.B) {
 	}
 
 	call := func(ctx context.Context, _ time.Time, _ models.Request) (err error) {
-		srv := v0.New(mockRepo)
+		srv := v0.New(1, mockRepo)
 		return srv.Do(ctx, now, models.Request{
 			IPAddress: "localhost",
 			Metrics:   metrics,
@@ -8,6 +8,6 @@ import (
 
 type MetricRepository interface {
 	AddUpdateBatch(ctx context.Context, counters []entities.CounterItem, gauges []entities.GaugeItem,
-		outboxes []entities.Outbox, outboxSegment string,
+		histograms []entities.HistogramItem, outboxes []entities.Outbox, outboxSegment string,
 	) (ok bool, err error)
 }
@@ -0,0 +1,70 @@
+package v0
+
+import (
+	"net"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+// foldCounterOp combines two consecutive counter operations on the same series
+// into a single one with the same effect.
+func foldCounterOp(prevOp pkg.MetricOp, prev int64, op pkg.MetricOp, value int64) (pkg.MetricOp, int64) {
+	if op == pkg.OpSet {
+		return op, value
+	}
+	return prevOp, prev + value
+}
+
+// foldGaugeOp combines two consecutive gauge operations on the same series
+// into a single one with the same effect, ok is false when they cannot be combined.
+func foldGaugeOp(prevOp pkg.MetricOp, prev float64, op pkg.MetricOp, value float64) (_ pkg.MetricOp, _ float64, ok bool) {
+	switch {
+	case op == pkg.OpSet:
+		return op, value, true
+	case prevOp == pkg.OpSet:
+		return prevOp, pkg.ApplyGaugeOp(op, prev, value), true
+	case isDelta(prevOp) && isDelta(op):
+		return pkg.OpInc, signed(prevOp, prev) + signed(op, value), true
+	case prevOp == op:
+		return op, pkg.ApplyGaugeOp(op, prev, value), true
+	}
+	return "", 0, false
+}
+
+// foldVersion combines the expected versions of the same series, all of them
+// refer to the state before the batch and so must agree.
+func foldVersion(prev, version *int64) (_ *int64, ok bool) {
+	switch {
+	case prev == nil:
+		return version, true
+	case version == nil || *prev == *version:
+		return prev, true
+	}
+	return nil, false
+}
+
+func isDelta(op pkg.MetricOp) bool {
+	return op == pkg.OpInc || op == pkg.OpDec
+}
+
+func signed(op pkg.MetricOp, value float64) float64 {
+	if op == pkg.OpDec {
+		return -value
+	}
+	return value
+}
+
+// outboxKey is the key the audit events of the update are sharded by: the client
+// address without the port, or the first metric name when the address is unknown.
+func outboxKey(ipAddress string, metrics []string) string {
+	if host, _, err := net.SplitHostPort(ipAddress); err == nil {
+		return host
+	}
+	if ipAddress != "" {
+		return ipAddress
+	}
+	if len(metrics) > 0 {
+		return metrics[0]
+	}
+	return ""
+}
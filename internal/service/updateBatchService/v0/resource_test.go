@@ -0,0 +1,113 @@
+package v0
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+func TestFoldCounterOp(t *testing.T) {
+	tests := []struct {
+		name      string
+		prevOp    pkg.MetricOp
+		prev      int64
+		op        pkg.MetricOp
+		value     int64
+		wantOp    pkg.MetricOp
+		wantValue int64
+	}{
+		{name: "inc after inc", prevOp: pkg.OpInc, prev: 2, op: pkg.OpInc, value: 3, wantOp: pkg.OpInc, wantValue: 5},
+		{name: "inc after set", prevOp: pkg.OpSet, prev: 2, op: pkg.OpInc, value: 3, wantOp: pkg.OpSet, wantValue: 5},
+		{name: "set after inc", prevOp: pkg.OpInc, prev: 2, op: pkg.OpSet, value: 3, wantOp: pkg.OpSet, wantValue: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, value := foldCounterOp(tt.prevOp, tt.prev, tt.op, tt.value)
+
+			assert.Equal(t, tt.wantOp, op)
+			assert.Equal(t, tt.wantValue, value)
+		})
+	}
+}
+
+func TestFoldGaugeOp(t *testing.T) {
+	tests := []struct {
+		name      string
+		prevOp    pkg.MetricOp
+		prev      float64
+		op        pkg.MetricOp
+		value     float64
+		wantOp    pkg.MetricOp
+		wantValue float64
+		wantOK    bool
+	}{
+		{name: "set after max", prevOp: pkg.OpMax, prev: 2, op: pkg.OpSet, value: 1, wantOp: pkg.OpSet, wantValue: 1, wantOK: true},
+		{name: "dec after set", prevOp: pkg.OpSet, prev: 2, op: pkg.OpDec, value: 3, wantOp: pkg.OpSet, wantValue: -1, wantOK: true},
+		{name: "dec after inc", prevOp: pkg.OpInc, prev: 2, op: pkg.OpDec, value: 3, wantOp: pkg.OpInc, wantValue: -1, wantOK: true},
+		{name: "max after max", prevOp: pkg.OpMax, prev: 2, op: pkg.OpMax, value: 3, wantOp: pkg.OpMax, wantValue: 3, wantOK: true},
+		{name: "min after min", prevOp: pkg.OpMin, prev: 2, op: pkg.OpMin, value: 3, wantOp: pkg.OpMin, wantValue: 2, wantOK: true},
+		{name: "max after inc", prevOp: pkg.OpInc, prev: 2, op: pkg.OpMax, value: 3, wantOK: false},
+		{name: "min after max", prevOp: pkg.OpMax, prev: 2, op: pkg.OpMin, value: 3, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, value, ok := foldGaugeOp(tt.prevOp, tt.prev, tt.op, tt.value)
+
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantOp, op)
+				assert.Equal(t, tt.wantValue, value)
+			}
+		})
+	}
+}
+
+func TestFoldVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    *int64
+		version *int64
+		want    *int64
+		wantOK  bool
+	}{
+		{name: "none", wantOK: true},
+		{name: "first", version: pkg.ToPtr[int64](2), want: pkg.ToPtr[int64](2), wantOK: true},
+		{name: "kept", prev: pkg.ToPtr[int64](2), want: pkg.ToPtr[int64](2), wantOK: true},
+		{name: "same", prev: pkg.ToPtr[int64](2), version: pkg.ToPtr[int64](2), want: pkg.ToPtr[int64](2), wantOK: true},
+		{name: "different", prev: pkg.ToPtr[int64](2), version: pkg.ToPtr[int64](3), wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, ok := foldVersion(tt.prev, tt.version)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, version)
+		})
+	}
+}
+
+func TestOutboxKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipAddress string
+		metrics   []string
+		want      string
+	}{
+		{name: "host and port", ipAddress: "10.0.0.1:53412", want: "10.0.0.1"},
+		{name: "host", ipAddress: "10.0.0.1", want: "10.0.0.1"},
+		{name: "ipv6", ipAddress: "[::1]:53412", want: "::1"},
+		{name: "metric name", metrics: []string{`Alloc{host="a"}`}, want: `Alloc{host="a"}`},
+		{name: "empty", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, outboxKey(tt.ipAddress, tt.metrics))
+		})
+	}
+}
@@ -2,99 +2,224 @@ package v0
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/principal"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/requestid"
 )
 
 var (
-	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric value")
-	errInvalidMetricType  *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric type")
+	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricValue).SetInfo("invalid metric value")
+	errInvalidMetricType  *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
+	errInvalidLabels      *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidLabels).SetInfo("invalid labels")
+	errReservedName       *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricName).SetInfo("reserved metric name")
+	errInvalidMetricName  *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricName).SetInfo("invalid metric name")
+	errInvalidOp          *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidOp).SetInfo("invalid op")
+	errOpConflict         *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeOpConflict).SetInfo("ops cannot be combined")
+	errVersionMismatch    *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeVersionMismatch).SetInfo("different versions for the same metric")
+	errInvalidVersion     *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidVersion).SetInfo("version is not supported for histograms")
+	errConflict           *pkg.Error = pkg.ErrConflict.SetCode(pkg.CodeVersionConflict).SetInfo("version conflict")
+	errMergeConflict      *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeMergeConflict).SetInfo("histogram cannot be merged")
 )
 
 type Service struct {
+	outboxSegments   int
 	metricRepository MetricRepository
 }
 
 func New(
+	outboxSegments int,
 	metricRepository MetricRepository,
 ) *Service {
 	return &Service{
+		outboxSegments:   outboxSegments,
 		metricRepository: metricRepository,
 	}
 }
 
 func (srv *Service) Do(ctx context.Context, ts time.Time, request models.Request) (err error) {
+	ctx, span := tracing.Start(ctx, "updateBatchService.Do")
+	defer func() { tracing.End(span, err) }()
+
 	if len(request.Metrics) == 0 {
 		return nil
 	}
 
 	counters := make(map[string]entities.CounterItem, len(request.Metrics))
 	gauges := make(map[string]entities.GaugeItem, len(request.Metrics))
-	metrics := make([]string, 0, len(request.Metrics))
+	histograms := make(map[string]entities.HistogramItem)
 
 	for _, metric := range request.Metrics {
+		if err := srv.Check(metric); err != nil {
+			return err
+		}
+
+		series := metric.Labels.Series(metric.ID)
+
+		op := metric.Op
+		if op == "" {
+			op = pkg.DefaultOp(metric.MType)
+		}
+
 		switch metric.MType {
 		case pkg.MetricTypeCounter:
-			if metric.Delta == nil {
-				return errInvalidMetricValue
+			delta, version := *metric.Delta, metric.Version
+			if prev, ok := counters[series]; ok {
+				if version, ok = foldVersion(prev.IfVersion, version); !ok {
+					return errVersionMismatch
+				}
+				op, delta = foldCounterOp(prev.Op, prev.MetricValue, op, delta)
 			}
-
-			delta := *metric.Delta + counters[metric.ID].MetricValue
-			counters[metric.ID] = entities.CounterItem{
+			counters[series] = entities.CounterItem{
 				MetricType:  metric.MType,
 				MetricName:  metric.ID,
+				Labels:      metric.Labels,
+				Op:          op,
 				MetricValue: delta,
+				IfVersion:   version,
 				CreatedAt:   ts,
 				UpdatedAt:   ts,
 			}
 
 		case pkg.MetricTypeGauge:
-			if metric.Value == nil {
-				return errInvalidMetricValue
+			value, version := *metric.Value, metric.Version
+			if prev, ok := gauges[series]; ok {
+				if version, ok = foldVersion(prev.IfVersion, version); !ok {
+					return errVersionMismatch
+				}
+				if op, value, ok = foldGaugeOp(prev.Op, prev.MetricValue, op, value); !ok {
+					return errOpConflict
+				}
 			}
-			gauges[metric.ID] = entities.GaugeItem{
+			gauges[series] = entities.GaugeItem{
 				MetricType:  metric.MType,
 				MetricName:  metric.ID,
-				MetricValue: *metric.Value,
+				Labels:      metric.Labels,
+				Op:          op,
+				MetricValue: value,
+				IfVersion:   version,
 				CreatedAt:   ts,
 				UpdatedAt:   ts,
 			}
 
-		default:
-			return errInvalidMetricType
+		case pkg.MetricTypeHistogram:
+			item, ok := histograms[series]
+			if !ok {
+				empty := pkg.NewHistogram(metric.Histogram.Bounds)
+				item = entities.HistogramItem{
+					MetricType: metric.MType,
+					MetricName: metric.ID,
+					Labels:     metric.Labels,
+					Bounds:     empty.Bounds,
+					Counts:     empty.Counts,
+					CreatedAt:  ts,
+					UpdatedAt:  ts,
+				}
+			}
+
+			histogram := pkg.Histogram{Bounds: item.Bounds, Counts: item.Counts, Sum: item.Sum, Count: item.Count}
+			if !histogram.Merge(*metric.Histogram) {
+				return errMergeConflict
+			}
+			item.Sum, item.Count = histogram.Sum, histogram.Count
+
+			histograms[series] = item
 		}
+	}
 
-		metrics = append(metrics, metric.ID)
+	ok, err := srv.Write(ctx, ts, request.IPAddress,
+		pkg.ValuesToList(counters), pkg.ValuesToList(gauges), pkg.ValuesToList(histograms),
+	)
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return errConflict
+	}
+	if err != nil {
+		return pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+	if !ok {
+		return pkg.ErrBadRequest
 	}
 
+	return nil
+}
+
+// Write stores the items together with the audit event of the update in one
+// transaction, every metric update goes through it. ok is false when the
+// storage rejects the items.
+func (srv *Service) Write(ctx context.Context, ts time.Time, ipAddress string,
+	counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "updateBatchService.Write")
+	defer func() { tracing.End(span, err) }()
+
+	metrics := make([]string, 0, len(counters)+len(gauges)+len(histograms))
+	for _, item := range counters {
+		metrics = append(metrics, item.Labels.Series(item.MetricName))
+	}
+	for _, item := range gauges {
+		metrics = append(metrics, item.Labels.Series(item.MetricName))
+	}
+	for _, item := range histograms {
+		metrics = append(metrics, item.Labels.Series(item.MetricName))
+	}
+
+	segment := pkg.OutboxSegment(outboxKey(ipAddress, metrics), srv.outboxSegments)
 	outboxes := []entities.Outbox{
 		{
-			Destination: string(models.FileOutboxDestination),
-			Payload: pkg.MustJSON(models.FileEvent{
+			Destination: string(models.AuditOutboxDestination),
+			Segment:     segment,
+			Payload: pkg.MustJSON(models.UpdateEvent{
 				TS:        ts,
+				Action:    models.AuditActionUpdate,
 				Metrics:   metrics,
-				IPAddress: request.IPAddress,
-			}),
-		},
-		{
-			Destination: string(models.RemoteOutboxDestination),
-			Payload: pkg.MustJSON(models.RemoteEvent{
-				TS:        ts,
-				Metrics:   metrics,
-				IPAddress: request.IPAddress,
+				IPAddress: ipAddress,
+				RequestID: requestid.FromContext(ctx),
+				Principal: principal.FromContext(ctx),
 			}),
 		},
 	}
 
-	ok, err := srv.metricRepository.AddUpdateBatch(ctx, pkg.ValuesToList(counters), pkg.ValuesToList(gauges), outboxes, "")
-	if err != nil {
-		return pkg.ErrInternalServer.SetInfo(err.Error())
+	return srv.metricRepository.AddUpdateBatch(ctx, counters, gauges, histograms, outboxes, segment)
+}
+
+// Check reports whether the metric would be accepted by Do.
+func (srv *Service) Check(metric models.Metric) error {
+	if pkg.ReservedName(metric.ID) {
+		return errReservedName
 	}
-	if !ok {
-		return pkg.ErrBadRequest
+	if !pkg.ValidName(metric.ID) {
+		return errInvalidMetricName
+	}
+	if !metric.Labels.Valid() {
+		return errInvalidLabels
+	}
+	if !pkg.ValidOp(metric.MType, metric.Op) {
+		return errInvalidOp
+	}
+
+	switch metric.MType {
+	case pkg.MetricTypeCounter:
+		if metric.Delta == nil {
+			return errInvalidMetricValue
+		}
+	case pkg.MetricTypeGauge:
+		if metric.Value == nil {
+			return errInvalidMetricValue
+		}
+	case pkg.MetricTypeHistogram:
+		if metric.Histogram == nil || !metric.Histogram.Valid() {
+			return errInvalidMetricValue
+		}
+		if metric.Version != nil {
+			return errInvalidVersion
+		}
+	default:
+		return errInvalidMetricType
 	}
 
 	return nil
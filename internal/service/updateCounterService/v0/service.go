@@ -2,34 +2,68 @@ package v0
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
+var (
+	errReservedName      *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricName).SetInfo("reserved metric name")
+	errInvalidMetricName *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricName).SetInfo("invalid metric name")
+	errInvalidLabels     *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidLabels).SetInfo("invalid labels")
+	errInvalidOp         *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidOp).SetInfo("invalid op")
+	errConflict          *pkg.Error = pkg.ErrConflict.SetCode(pkg.CodeVersionConflict).SetInfo("version conflict")
+)
+
 type Service struct {
-	metricRepository MetricRepository
+	metricWriter MetricWriter
 }
 
-func New(metricRepo MetricRepository) *Service {
+func New(metricWriter MetricWriter) *Service {
 	return &Service{
-		metricRepository: metricRepo,
+		metricWriter: metricWriter,
 	}
 }
 
 func (srv *Service) Do(
-	ctx context.Context, metricName string, metricValue int64,
+	ctx context.Context, ipAddress, metricName string, labels pkg.Labels, op string, version *int64, metricValue int64,
 ) (err error) {
+	ctx, span := tracing.Start(ctx, "updateCounterService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	if pkg.ReservedName(metricName) {
+		return errReservedName
+	}
+	if !pkg.ValidName(metricName) {
+		return errInvalidMetricName
+	}
+	if !labels.Valid() {
+		return errInvalidLabels
+	}
+	if !pkg.ValidOp(pkg.MetricTypeCounter, op) {
+		return errInvalidOp
+	}
+
 	ts := time.Now()
 
-	ok, err := srv.metricRepository.Add(ctx, entities.CounterItem{
+	item := entities.CounterItem{
 		MetricType:  pkg.MetricTypeCounter,
 		MetricName:  metricName,
+		Labels:      labels,
+		Op:          op,
 		MetricValue: metricValue,
+		IfVersion:   version,
 		CreatedAt:   ts,
 		UpdatedAt:   ts,
-	})
+	}
+
+	ok, err := srv.metricWriter.Write(ctx, ts, ipAddress, []entities.CounterItem{item}, nil, nil)
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return errConflict
+	}
 	if err != nil {
 		return pkg.ErrInternalServer.SetInfo(err.Error())
 	}
@@ -6,45 +6,68 @@ import (
 
 	updateCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateCounterService/v0"
 	updateGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateGaugeService/v0"
+	updateHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateHistogramService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 var (
-	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric value")
-	errInvalidMetricType  *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric type")
+	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricValue).SetInfo("invalid metric value")
+	errInvalidMetricType  *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
+	errInvalidOp          *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidOp).SetInfo("invalid op")
+	errInvalidVersion     *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidVersion).SetInfo("version is not supported for histograms")
 )
 
 type Service struct {
-	updateCounterService *updateCounterService.Service
-	updateGaugeService   *updateGaugeService.Service
+	updateCounterService   *updateCounterService.Service
+	updateGaugeService     *updateGaugeService.Service
+	updateHistogramService *updateHistogramService.Service
 }
 
 func New(
 	updateCounterService *updateCounterService.Service,
 	updateGaugeService *updateGaugeService.Service,
+	updateHistogramService *updateHistogramService.Service,
 ) *Service {
 	return &Service{
-		updateCounterService: updateCounterService,
-		updateGaugeService:   updateGaugeService,
+		updateCounterService:   updateCounterService,
+		updateGaugeService:     updateGaugeService,
+		updateHistogramService: updateHistogramService,
 	}
 }
 
 func (srv *Service) Do(
-	ctx context.Context, metricType, metricName, metricValue string,
+	ctx context.Context, ipAddress, metricType, metricName string, labels pkg.Labels, op string, version *int64, metricValue string,
 ) (err error) {
+	ctx, span := tracing.Start(ctx, "updateFlatService.Do")
+	defer func() { tracing.End(span, err) }()
+
 	switch metricType {
 	case pkg.MetricTypeCounter:
 		if valueInt, err := strconv.ParseInt(metricValue, 10, 64); err != nil {
 			return errInvalidMetricValue
 		} else {
-			return srv.updateCounterService.Do(ctx, metricName, valueInt)
+			return srv.updateCounterService.Do(ctx, ipAddress, metricName, labels, op, version, valueInt)
 		}
 
 	case pkg.MetricTypeGauge:
 		if valueFloat, err := strconv.ParseFloat(metricValue, 64); err != nil {
 			return errInvalidMetricValue
 		} else {
-			return srv.updateGaugeService.Do(ctx, metricName, valueFloat)
+			return srv.updateGaugeService.Do(ctx, ipAddress, metricName, labels, op, version, valueFloat)
+		}
+
+	case pkg.MetricTypeHistogram:
+		if op != "" {
+			return errInvalidOp
+		}
+		if version != nil {
+			return errInvalidVersion
+		}
+		if valueFloat, err := strconv.ParseFloat(metricValue, 64); err != nil {
+			return errInvalidMetricValue
+		} else {
+			return srv.updateHistogramService.Observe(ctx, ipAddress, metricName, labels, valueFloat)
 		}
 
 	default:
@@ -2,10 +2,13 @@ package v0
 
 import (
 	"context"
+	"time"
 
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
 )
 
-type MetricRepository interface {
-	Update(ctx context.Context, item entities.GaugeItem) (ok bool, err error)
+type MetricWriter interface {
+	Write(ctx context.Context, ts time.Time, ipAddress string,
+		counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	) (ok bool, err error)
 }
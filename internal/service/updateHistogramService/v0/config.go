@@ -0,0 +1,6 @@
+package v0
+
+type Config struct {
+	// Buckets are the upper bounds used for single observations.
+	Buckets []float64 `env:"BUCKETS" envSeparator:"," envDefault:"0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10" json:"buckets"`
+}
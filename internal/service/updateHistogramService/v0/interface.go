@@ -0,0 +1,14 @@
+package v0
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+)
+
+type MetricWriter interface {
+	Write(ctx context.Context, ts time.Time, ipAddress string,
+		counters []entities.CounterItem, gauges []entities.GaugeItem, histograms []entities.HistogramItem,
+	) (ok bool, err error)
+}
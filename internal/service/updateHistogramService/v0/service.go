@@ -0,0 +1,96 @@
+package v0
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+var (
+	errReservedName       *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricName).SetInfo("reserved metric name")
+	errInvalidMetricName  *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricName).SetInfo("invalid metric name")
+	errInvalidLabels      *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidLabels).SetInfo("invalid labels")
+	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricValue).SetInfo("invalid metric value")
+	errMergeConflict      *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeMergeConflict).SetInfo("histogram cannot be merged")
+)
+
+type Service struct {
+	config       Config
+	metricWriter MetricWriter
+}
+
+func New(config Config, metricWriter MetricWriter) *Service {
+	if len(config.Buckets) == 0 {
+		config.Buckets = pkg.DefaultBuckets
+	}
+	config.Buckets = slices.Compact(slices.Sorted(slices.Values(config.Buckets)))
+
+	return &Service{
+		config:       config,
+		metricWriter: metricWriter,
+	}
+}
+
+// Do merges the histogram into the stored one, the buckets of both must match.
+func (srv *Service) Do(
+	ctx context.Context, ipAddress, metricName string, labels pkg.Labels, histogram pkg.Histogram,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "updateHistogramService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	if pkg.ReservedName(metricName) {
+		return errReservedName
+	}
+	if !pkg.ValidName(metricName) {
+		return errInvalidMetricName
+	}
+	if !labels.Valid() {
+		return errInvalidLabels
+	}
+	if !histogram.Valid() {
+		return errInvalidMetricValue
+	}
+
+	ts := time.Now()
+
+	item := entities.HistogramItem{
+		MetricType: pkg.MetricTypeHistogram,
+		MetricName: metricName,
+		Labels:     labels,
+		Bounds:     histogram.Bounds,
+		Counts:     histogram.Counts,
+		Sum:        histogram.Sum,
+		Count:      histogram.Count,
+		CreatedAt:  ts,
+		UpdatedAt:  ts,
+	}
+
+	ok, err := srv.metricWriter.Write(ctx, ts, ipAddress, nil, nil, []entities.HistogramItem{item})
+	if err != nil {
+		return pkg.ErrInternalServer.SetInfo(err.Error())
+	}
+	if !ok {
+		return errMergeConflict
+	}
+
+	return nil
+}
+
+// Observe records a single value using the configured buckets.
+func (srv *Service) Observe(
+	ctx context.Context, ipAddress, metricName string, labels pkg.Labels, value float64,
+) (err error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return errInvalidMetricValue
+	}
+
+	histogram := pkg.NewHistogram(srv.config.Buckets)
+	histogram.Observe(value)
+
+	return srv.Do(ctx, ipAddress, metricName, labels, histogram)
+}
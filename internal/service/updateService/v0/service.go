@@ -6,42 +6,66 @@ import (
 	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
 	updateCounterService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateCounterService/v0"
 	updateGaugeService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateGaugeService/v0"
+	updateHistogramService "github.com/MaksimMakarenko1001/ya-go-advanced/internal/service/updateHistogramService/v0"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
 	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
 )
 
 var (
-	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric value")
-	errInvalidMetricType  *pkg.Error = pkg.ErrBadRequest.SetInfo("invalid metric type")
+	errInvalidMetricValue *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricValue).SetInfo("invalid metric value")
+	errInvalidMetricType  *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidMetricType).SetInfo("invalid metric type")
+	errInvalidOp          *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidOp).SetInfo("invalid op")
+	errInvalidVersion     *pkg.Error = pkg.ErrBadRequest.SetCode(pkg.CodeInvalidVersion).SetInfo("version is not supported for histograms")
 )
 
 type Service struct {
-	updateCounterService *updateCounterService.Service
-	updateGaugeService   *updateGaugeService.Service
+	updateCounterService   *updateCounterService.Service
+	updateGaugeService     *updateGaugeService.Service
+	updateHistogramService *updateHistogramService.Service
 }
 
 func New(
 	updateCounterService *updateCounterService.Service,
 	updateGaugeService *updateGaugeService.Service,
+	updateHistogramService *updateHistogramService.Service,
 ) *Service {
 	return &Service{
-		updateCounterService: updateCounterService,
-		updateGaugeService:   updateGaugeService,
+		updateCounterService:   updateCounterService,
+		updateGaugeService:     updateGaugeService,
+		updateHistogramService: updateHistogramService,
 	}
 }
 
-func (srv *Service) Do(ctx context.Context, metric models.Metric) (err error) {
+func (srv *Service) Do(ctx context.Context, ipAddress string, metric models.Metric) (err error) {
+	ctx, span := tracing.Start(ctx, "updateService.Do")
+	defer func() { tracing.End(span, err) }()
+
 	switch metric.MType {
 	case pkg.MetricTypeCounter:
 		if metric.Delta == nil {
 			return errInvalidMetricValue
 		}
-		return srv.updateCounterService.Do(ctx, metric.ID, *metric.Delta)
+		return srv.updateCounterService.Do(ctx, ipAddress, metric.ID, metric.Labels, metric.Op, metric.Version, *metric.Delta)
 
 	case pkg.MetricTypeGauge:
 		if metric.Value == nil {
 			return errInvalidMetricValue
 		} else {
-			return srv.updateGaugeService.Do(ctx, metric.ID, *metric.Value)
+			return srv.updateGaugeService.Do(ctx, ipAddress, metric.ID, metric.Labels, metric.Op, metric.Version, *metric.Value)
+		}
+
+	case pkg.MetricTypeHistogram:
+		switch {
+		case metric.Op != "":
+			return errInvalidOp
+		case metric.Version != nil:
+			return errInvalidVersion
+		case metric.Histogram != nil:
+			return srv.updateHistogramService.Do(ctx, ipAddress, metric.ID, metric.Labels, *metric.Histogram)
+		case metric.Value != nil:
+			return srv.updateHistogramService.Observe(ctx, ipAddress, metric.ID, metric.Labels, *metric.Value)
+		default:
+			return errInvalidMetricValue
 		}
 
 	default:
@@ -9,9 +9,9 @@ import (
 
 type OutboxRepository interface {
 	OutboxGetNext(ctx context.Context, destination models.OutboxDestination, segment string, limit int) (resp []entities.Outbox, err error)
-	OutboxCommit(ctx context.Context, okIds []entities.OutboxID, failedIds []entities.OutboxID, segment string) (err error)
+	OutboxCommit(ctx context.Context, okIds []entities.OutboxID, failed []entities.OutboxFailure, segment string) (err error)
 }
 
-type RemoteRepository interface {
-	RemoteSend(ctx context.Context, body []byte) error
+type WebhookRepository interface {
+	WebhookSend(ctx context.Context, receiver string, body []byte) error
 }
@@ -0,0 +1,63 @@
+package v0
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/entities"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/models"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+)
+
+const segment = ""
+
+type Service struct {
+	outboxRepo  OutboxRepository
+	webhookRepo WebhookRepository
+}
+
+func New(outboxRepo OutboxRepository, webhookRepo WebhookRepository) *Service {
+	return &Service{
+		outboxRepo:  outboxRepo,
+		webhookRepo: webhookRepo,
+	}
+}
+
+// Do delivers the rendered notifications, the failed ones are retried by the outbox.
+func (srv *Service) Do(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "webhookService.Do")
+	defer func() { tracing.End(span, err) }()
+
+	items, err := srv.outboxRepo.OutboxGetNext(ctx, models.WebhookOutboxDestination, segment, 100)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	completed, failed := make([]entities.OutboxID, 0, len(items)), make([]entities.OutboxFailure, 0, len(items))
+
+	for _, item := range items {
+		var notification models.Notification
+		if err := json.Unmarshal(item.Payload, &notification); err != nil {
+			log.Printf("outbox payload not ok {dest=%v, id=%v, err=%v}\n", models.WebhookOutboxDestination, item.ID, err.Error())
+			completed = append(completed, item.ID)
+			continue
+		}
+
+		if err := srv.webhookRepo.WebhookSend(ctx, notification.Receiver, []byte(notification.Body)); err != nil {
+			log.Printf("outbox failure {dest=%v, id=%v, err=%v}\n", models.WebhookOutboxDestination, item.ID, err.Error())
+			failed = append(failed, entities.OutboxFailure{ID: item.ID, Error: err.Error()})
+		} else {
+			completed = append(completed, item.ID)
+		}
+	}
+
+	if err := srv.outboxRepo.OutboxCommit(ctx, completed, failed, segment); err != nil {
+		log.Printf("outbox commit failure {dest=%v, err=%v}\n", models.WebhookOutboxDestination, err.Error())
+	}
+
+	return nil
+}
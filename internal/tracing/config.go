@@ -0,0 +1,18 @@
+package tracing
+
+const (
+	ExporterNone   Exporter = "none"
+	ExporterStdout Exporter = "stdout"
+	ExporterFile   Exporter = "file"
+	ExporterOTLP   Exporter = "otlp"
+)
+
+type Config struct {
+	Exporter     Exporter `env:"EXPORTER" envDefault:"stdout" json:"exporter"`
+	FilePath     string   `env:"FILE_PATH" json:"filePath"`
+	OTLPEndpoint string   `env:"OTLP_ENDPOINT" json:"otlpEndpoint"`
+	ServiceName  string   `env:"SERVICE_NAME" envDefault:"metrics-server" json:"serviceName"`
+	SampleRatio  float64  `env:"SAMPLE_RATIO" envDefault:"1" json:"sampleRatio"`
+}
+
+type Exporter string
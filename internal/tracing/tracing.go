@@ -0,0 +1,117 @@
+// Package tracing sets up the OpenTelemetry tracer provider of the server
+// and provides helpers to start spans and record their errors.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/MaksimMakarenko1001/ya-go-advanced"
+
+// Provider owns the tracer provider together with the resources of its exporter.
+type Provider struct {
+	tp     *sdktrace.TracerProvider
+	closer io.Closer
+}
+
+// New builds the tracer provider for the configured exporter and installs it,
+// together with the W3C trace context propagator, as the global one.
+func New(config Config) (*Provider, error) {
+	exporter, closer, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if exporter == nil {
+		return &Provider{}, nil
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", config.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+
+	return &Provider{tp: tp, closer: closer}, nil
+}
+
+func newExporter(config Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch config.Exporter {
+	case ExporterNone:
+		return nil, nil, nil
+	case ExporterOTLP:
+		if config.OTLPEndpoint == "" {
+			return nil, nil, fmt.Errorf("tracing: otlp endpoint is not set")
+		}
+		exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+		return exporter, nil, err
+	case ExporterFile:
+		if config.FilePath == "" {
+			return nil, nil, fmt.Errorf("tracing: file path is not set")
+		}
+		file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	case ExporterStdout, "":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("tracing: unknown exporter %q", config.Exporter)
+	}
+}
+
+// Shutdown flushes the pending spans and releases the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.tp == nil {
+		return nil
+	}
+
+	err := p.tp.Shutdown(ctx)
+	if p.closer != nil {
+		if cerr := p.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Start starts a span as a child of the span of the context, if any.
+func Start(
+	ctx context.Context, name string, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, as the span status and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,81 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/internal/tracing"
+)
+
+func TestNew_OTLP(t *testing.T) {
+	var received atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/traces" {
+			received.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	provider, err := tracing.New(tracing.Config{
+		Exporter:     tracing.ExporterOTLP,
+		OTLPEndpoint: collector.URL + "/v1/traces",
+		ServiceName:  "test",
+		SampleRatio:  1,
+	})
+	require.NoError(t, err)
+
+	_, span := tracing.Start(context.Background(), "test.Do")
+	tracing.End(span, errors.New("failure"))
+
+	require.NoError(t, provider.Shutdown(context.Background()))
+	assert.Positive(t, received.Load())
+}
+
+func TestNew_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	provider, err := tracing.New(tracing.Config{
+		Exporter:    tracing.ExporterFile,
+		FilePath:    path,
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+
+	_, span := tracing.Start(context.Background(), "test.Do")
+	tracing.End(span, nil)
+
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"test.Do"`)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config tracing.Config
+	}{
+		{name: "unknown exporter", config: tracing.Config{Exporter: "unknown"}},
+		{name: "no otlp endpoint", config: tracing.Config{Exporter: tracing.ExporterOTLP}},
+		{name: "no file path", config: tracing.Config{Exporter: tracing.ExporterFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tracing.New(tt.config)
+			assert.Error(t, err)
+		})
+	}
+}
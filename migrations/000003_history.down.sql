@@ -0,0 +1,64 @@
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.counters, _items)
+        ),
+        ins_cte as (
+            insert into metric.counters as c (metric_type, metric_name, metric_value,
+                    created_at, updated_at)
+            select cte.metric_type, cte.metric_name, cte.metric_value,
+                    cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name) do update
+                set metric_value = c.metric_value + excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning c.metric_name
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.gauges, _items)
+        ),
+        ins_cte as (
+            insert into metric.gauges as g (metric_type, metric_name, metric_value,
+                    created_at, updated_at)
+            select src.metric_type, src.metric_name, src.metric_value,
+                    src.created_at, src.updated_at
+                from cte as src
+            on conflict (metric_name) do update
+                set metric_value = excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning g.metric_name
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+DROP FUNCTION metric.samples_downsample(text, text, timestamptz, timestamptz, interval);
+DROP FUNCTION metric.samples_ensure_partitions(json);
+
+DROP TABLE IF EXISTS metric.samples;
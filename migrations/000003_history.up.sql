@@ -0,0 +1,156 @@
+CREATE TABLE IF NOT EXISTS metric.samples (
+    metric_type TEXT NOT NULL,
+    metric_name TEXT NOT NULL,
+    metric_value DOUBLE PRECISION NOT NULL,
+    ts TIMESTAMPTZ NOT NULL
+) PARTITION BY RANGE (ts);
+
+CREATE INDEX IF NOT EXISTS samples_metric_ts_idx
+    ON metric.samples (metric_type, metric_name, ts);
+
+-- Creates daily partitions covering every updated_at in _items.
+CREATE OR REPLACE FUNCTION metric.samples_ensure_partitions(_items json)
+ RETURNS void
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _day date;
+    _partition text;
+begin
+    for _day in
+        select distinct (src.updated_at at time zone 'UTC')::date
+            from json_to_recordset(coalesce(_items, '[]'::json)) as src(updated_at timestamptz)
+    loop
+        _partition := 'samples_' || to_char(_day, 'YYYYMMDD');
+
+        if to_regclass('metric.' || _partition) is not null then
+            continue;
+        end if;
+
+        perform pg_advisory_xact_lock(hashtext('metric_' || _partition));
+
+        execute format(
+            'create table if not exists metric.%I partition of metric.samples for values from (%L) to (%L)',
+            _partition,
+            (_day::timestamp at time zone 'UTC'),
+            ((_day + 1)::timestamp at time zone 'UTC')
+        );
+    end loop;
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.samples_downsample(
+    _metric_type text, _metric_name text, _from timestamptz, _to timestamptz, _step interval
+)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _step_sec double precision := extract(epoch from _step);
+begin
+    with
+        cte as (
+            select
+                to_timestamp(floor(extract(epoch from s.ts) / _step_sec) * _step_sec) as bucket,
+                s.metric_value,
+                s.ts
+            from metric.samples as s
+                where s.metric_type = _metric_type
+                    and s.metric_name = _metric_name
+                    and s.ts >= _from
+                    and s.ts < _to
+        ),
+        agg as (
+            select
+                cte.bucket as ts,
+                min(cte.metric_value) as min,
+                max(cte.metric_value) as max,
+                avg(cte.metric_value) as avg,
+                (array_agg(cte.metric_value order by cte.ts desc))[1] as last,
+                count(*) as count
+            from cte
+            group by cte.bucket
+        )
+    select json_agg(agg.* order by agg.ts) from agg
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.counters, _items)
+        ),
+        ins_cte as (
+            insert into metric.counters as c (metric_type, metric_name, metric_value,
+                    created_at, updated_at)
+            select cte.metric_type, cte.metric_name, cte.metric_value,
+                    cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name) do update
+                set metric_value = c.metric_value + excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning c.metric_type, c.metric_name, c.metric_value, c.updated_at
+        ),
+        hist_cte as (
+            insert into metric.samples (metric_type, metric_name, metric_value, ts)
+            select ins_cte.metric_type, ins_cte.metric_name, ins_cte.metric_value, ins_cte.updated_at
+                from ins_cte
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.gauges, _items)
+        ),
+        ins_cte as (
+            insert into metric.gauges as g (metric_type, metric_name, metric_value,
+                    created_at, updated_at)
+            select src.metric_type, src.metric_name, src.metric_value,
+                    src.created_at, src.updated_at
+                from cte as src
+            on conflict (metric_name) do update
+                set metric_value = excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning g.metric_type, g.metric_name, g.metric_value, g.updated_at
+        ),
+        hist_cte as (
+            insert into metric.samples (metric_type, metric_name, metric_value, ts)
+            select ins_cte.metric_type, ins_cte.metric_name, ins_cte.metric_value, ins_cte.updated_at
+                from ins_cte
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
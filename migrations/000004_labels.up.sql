@@ -0,0 +1,171 @@
+ALTER TABLE metric.counters ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE metric.counters DROP CONSTRAINT IF EXISTS counters_metric_name_key;
+ALTER TABLE metric.counters ADD CONSTRAINT counters_metric_name_labels_key UNIQUE (metric_name, labels);
+
+ALTER TABLE metric.gauges ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE metric.gauges DROP CONSTRAINT IF EXISTS gauges_metric_name_key;
+ALTER TABLE metric.gauges ADD CONSTRAINT gauges_metric_name_labels_key UNIQUE (metric_name, labels);
+
+ALTER TABLE metric.samples ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+DROP INDEX IF EXISTS metric.samples_metric_ts_idx;
+CREATE INDEX IF NOT EXISTS samples_metric_labels_ts_idx
+    ON metric.samples (metric_type, metric_name, labels, ts);
+
+CREATE OR REPLACE FUNCTION metric.counters_get(_metric_name text, _labels jsonb)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select c.* from metric.counters as c
+                where c.metric_name = _metric_name
+                    and c.labels = coalesce(nullif(_labels, 'null'::jsonb), '{}'::jsonb)
+        )
+    select json_agg(cte.*) from cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_get(_metric_name text, _labels jsonb)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select g.* from metric.gauges as g
+                where g.metric_name = _metric_name
+                    and g.labels = coalesce(nullif(_labels, 'null'::jsonb), '{}'::jsonb)
+        )
+    select json_agg(cte.*) from cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+DROP FUNCTION metric.samples_downsample(text, text, timestamptz, timestamptz, interval);
+CREATE OR REPLACE FUNCTION metric.samples_downsample(
+    _metric_type text, _metric_name text, _labels jsonb, _from timestamptz, _to timestamptz, _step interval
+)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _step_sec double precision := extract(epoch from _step);
+begin
+    with
+        cte as (
+            select
+                to_timestamp(floor(extract(epoch from s.ts) / _step_sec) * _step_sec) as bucket,
+                s.metric_value,
+                s.ts
+            from metric.samples as s
+                where s.metric_type = _metric_type
+                    and s.metric_name = _metric_name
+                    and s.labels = coalesce(nullif(_labels, 'null'::jsonb), '{}'::jsonb)
+                    and s.ts >= _from
+                    and s.ts < _to
+        ),
+        agg as (
+            select
+                cte.bucket as ts,
+                min(cte.metric_value) as min,
+                max(cte.metric_value) as max,
+                avg(cte.metric_value) as avg,
+                (array_agg(cte.metric_value order by cte.ts desc))[1] as last,
+                count(*) as count
+            from cte
+            group by cte.bucket
+        )
+    select json_agg(agg.* order by agg.ts) from agg
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.counters, _items)
+        ),
+        ins_cte as (
+            insert into metric.counters as c (metric_type, metric_name, labels, metric_value,
+                    created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.metric_value,
+                    cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set metric_value = c.metric_value + excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning c.metric_type, c.metric_name, c.labels, c.metric_value, c.updated_at
+        ),
+        hist_cte as (
+            insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+            select ins_cte.metric_type, ins_cte.metric_name, ins_cte.labels, ins_cte.metric_value, ins_cte.updated_at
+                from ins_cte
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.gauges, _items)
+        ),
+        ins_cte as (
+            insert into metric.gauges as g (metric_type, metric_name, labels, metric_value,
+                    created_at, updated_at)
+            select src.metric_type, src.metric_name, coalesce(src.labels, '{}'::jsonb), src.metric_value,
+                    src.created_at, src.updated_at
+                from cte as src
+            on conflict (metric_name, labels) do update
+                set metric_value = excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning g.metric_type, g.metric_name, g.labels, g.metric_value, g.updated_at
+        ),
+        hist_cte as (
+            insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+            select ins_cte.metric_type, ins_cte.metric_name, ins_cte.labels, ins_cte.metric_value, ins_cte.updated_at
+                from ins_cte
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
@@ -0,0 +1,55 @@
+DROP FUNCTION metric.metrics_upsert(json, json, json, text, json);
+CREATE OR REPLACE FUNCTION metric.metrics_upsert(_counter_items json, _gauge_items json, _outbox_items json = NULL::json, _outbox_segment text = ''::text)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $$
+declare
+    _res json;
+begin
+    with cte(metric_name) as (
+        select * from json_array_elements(metric.counters_upsert(_counter_items))
+        union all
+        select * from json_array_elements(metric.gauges_upsert(_gauge_items))
+    )
+    select json_agg(cte.metric_name)
+	    into _res
+        from cte
+    ;
+
+    perform outbox.outbox_add_new(_outbox_items, _outbox_segment);
+
+    return coalesce(_res, '[]'::json);
+end;
+$$
+;
+
+CREATE OR REPLACE FUNCTION metric.metrics_list()
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        counter_data as (
+            select c.* from metric.counters as c
+        ),
+        gauge_data as (
+            select g.* from metric.gauges as g
+        )
+    select
+		json_build_object(
+			'counters', (select json_agg(r.*) from counter_data as r),
+			'gauges', (select json_agg(r.*) from gauge_data as r)
+		)
+	    into _res;
+
+    return _res;
+end;
+$function$
+;
+
+DROP FUNCTION metric.histograms_get(text, jsonb);
+DROP FUNCTION metric.histograms_upsert(json);
+
+DROP TABLE IF EXISTS metric.histograms;
@@ -0,0 +1,132 @@
+CREATE TABLE IF NOT EXISTS metric.histograms (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    metric_type TEXT NOT NULL DEFAULT 'histogram',
+    metric_name TEXT NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+    bounds DOUBLE PRECISION[] NOT NULL,
+    counts BIGINT[] NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    count BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT histograms_metric_name_labels_key UNIQUE (metric_name, labels)
+);
+
+CREATE OR REPLACE FUNCTION metric.histograms_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.histograms, _items)
+        ),
+        ins_cte as (
+            insert into metric.histograms as h (metric_type, metric_name, labels, bounds, counts,
+                    sum, count, created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.bounds, cte.counts,
+                    cte.sum, cte.count, cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set counts = (
+                        select array_agg(a.c + b.c order by a.i)
+                            from unnest(h.counts) with ordinality as a(c, i)
+                            join unnest(excluded.counts) with ordinality as b(c, i) using (i)
+                    ),
+                    sum = h.sum + excluded.sum,
+                    count = h.count + excluded.count,
+                    updated_at = excluded.updated_at
+                -- histograms with different buckets cannot be merged
+                where h.bounds = excluded.bounds
+            returning h.metric_name
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.histograms_get(_metric_name text, _labels jsonb)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select h.* from metric.histograms as h
+                where h.metric_name = _metric_name
+                    and h.labels = coalesce(nullif(_labels, 'null'::jsonb), '{}'::jsonb)
+        )
+    select json_agg(cte.*) from cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.metrics_list()
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        counter_data as (
+            select c.* from metric.counters as c
+        ),
+        gauge_data as (
+            select g.* from metric.gauges as g
+        ),
+        histogram_data as (
+            select h.* from metric.histograms as h
+        )
+    select
+		json_build_object(
+			'counters', (select json_agg(r.*) from counter_data as r),
+			'gauges', (select json_agg(r.*) from gauge_data as r),
+			'histograms', (select json_agg(r.*) from histogram_data as r)
+		)
+	    into _res;
+
+    return _res;
+end;
+$function$
+;
+
+DROP FUNCTION metric.metrics_upsert(json, json, json, text);
+CREATE OR REPLACE FUNCTION metric.metrics_upsert(
+    _counter_items json, _gauge_items json, _outbox_items json = NULL::json, _outbox_segment text = ''::text,
+    _histogram_items json = NULL::json
+)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $$
+declare
+    _res json;
+begin
+    with cte(metric_name) as (
+        select * from json_array_elements(metric.counters_upsert(_counter_items))
+        union all
+        select * from json_array_elements(metric.gauges_upsert(_gauge_items))
+        union all
+        select * from json_array_elements(metric.histograms_upsert(coalesce(_histogram_items, '[]'::json)))
+    )
+    select json_agg(cte.metric_name)
+	    into _res
+        from cte
+    ;
+
+    perform outbox.outbox_add_new(_outbox_items, _outbox_segment);
+
+    return coalesce(_res, '[]'::json);
+end;
+$$
+;
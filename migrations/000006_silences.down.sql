@@ -0,0 +1,8 @@
+DROP FUNCTION notify.silences_delete(text);
+DROP FUNCTION notify.silences_list(timestamptz);
+DROP FUNCTION notify.silences_add(json);
+
+DROP TABLE IF EXISTS notify.silences;
+DROP SEQUENCE IF EXISTS notify.silences_id_seq;
+
+DROP SCHEMA IF EXISTS notify;
@@ -0,0 +1,94 @@
+CREATE SCHEMA IF NOT EXISTS notify;
+
+CREATE SEQUENCE IF NOT EXISTS notify.silences_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE IF NOT EXISTS notify.silences(
+    id TEXT DEFAULT nextval('notify.silences_id_seq'::regclass)::TEXT NOT NULL,
+    rule TEXT NOT NULL DEFAULT '',
+    metric_name TEXT NOT NULL DEFAULT '',
+    matchers JSONB NOT NULL DEFAULT '{}'::jsonb,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE notify.silences
+    ADD CONSTRAINT silences_pkey PRIMARY KEY (id);
+
+CREATE INDEX IF NOT EXISTS silences_ends_at_idx
+    ON notify.silences (ends_at);
+
+CREATE OR REPLACE FUNCTION notify.silences_add(_item json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with
+        cte as (
+            select * from json_populate_record(null::notify.silences, _item)
+        ),
+        ins_cte as (
+            insert into notify.silences as s (rule, metric_name, matchers,
+                    starts_at, ends_at, created_by, comment, created_at)
+            select coalesce(src.rule, ''), coalesce(src.metric_name, ''),
+                    coalesce(nullif(src.matchers, 'null'::jsonb), '{}'::jsonb),
+                    src.starts_at, src.ends_at, coalesce(src.created_by, ''), coalesce(src.comment, ''),
+                    coalesce(src.created_at, now())
+                from cte as src
+            returning s.*
+        )
+    select row_to_json(ins_cte.*) from ins_cte
+        into _res;
+
+    return _res;
+end;
+$function$
+;
+
+-- Returns the silences that have not expired yet.
+CREATE OR REPLACE FUNCTION notify.silences_list(_ts timestamptz)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    select json_agg(s.* order by s.starts_at, s.id)
+        into _res
+        from notify.silences as s
+            where s.ends_at > _ts
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION notify.silences_delete(_id text)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with del_cte as (
+        delete from notify.silences as del
+            where del.id = _id
+        returning del.id
+    )
+    select json_agg(del_cte.id) from del_cte
+        into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
@@ -0,0 +1,5 @@
+DROP FUNCTION metric.metrics_delete(text, text, jsonb, text, timestamptz);
+
+DROP INDEX IF EXISTS metric.histograms_updated_at_idx;
+DROP INDEX IF EXISTS metric.gauges_updated_at_idx;
+DROP INDEX IF EXISTS metric.counters_updated_at_idx;
@@ -0,0 +1,73 @@
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.counters, _items)
+        ),
+        ins_cte as (
+            insert into metric.counters as c (metric_type, metric_name, labels, metric_value,
+                    created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.metric_value,
+                    cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set metric_value = c.metric_value + excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning c.metric_type, c.metric_name, c.labels, c.metric_value, c.updated_at
+        ),
+        hist_cte as (
+            insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+            select ins_cte.metric_type, ins_cte.metric_name, ins_cte.labels, ins_cte.metric_value, ins_cte.updated_at
+                from ins_cte
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.gauges, _items)
+        ),
+        ins_cte as (
+            insert into metric.gauges as g (metric_type, metric_name, labels, metric_value,
+                    created_at, updated_at)
+            select src.metric_type, src.metric_name, coalesce(src.labels, '{}'::jsonb), src.metric_value,
+                    src.created_at, src.updated_at
+                from cte as src
+            on conflict (metric_name, labels) do update
+                set metric_value = excluded.metric_value,
+                    updated_at = excluded.updated_at
+            returning g.metric_type, g.metric_name, g.labels, g.metric_value, g.updated_at
+        ),
+        hist_cte as (
+            insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+            select ins_cte.metric_type, ins_cte.metric_name, ins_cte.labels, ins_cte.metric_value, ins_cte.updated_at
+                from ins_cte
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
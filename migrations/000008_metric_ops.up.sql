@@ -0,0 +1,93 @@
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _names text[] := '{}';
+    _item json;
+    _row metric.counters;
+    _op text;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.counters, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'inc');
+
+        insert into metric.counters as c (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb), _row.metric_value,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'set' then excluded.metric_value
+                    else c.metric_value + excluded.metric_value
+                end,
+                updated_at = excluded.updated_at
+        returning c.metric_type, c.metric_name, c.labels, c.metric_value, c.updated_at
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _names := _names || _row.metric_name;
+    end loop;
+
+    select json_agg(n) from unnest(_names) as n
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _names text[] := '{}';
+    _item json;
+    _row metric.gauges;
+    _op text;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.gauges, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'set');
+
+        insert into metric.gauges as g (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb),
+                case _op when 'dec' then -_row.metric_value else _row.metric_value end,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'inc' then g.metric_value + excluded.metric_value
+                    when 'dec' then g.metric_value + excluded.metric_value
+                    when 'max' then greatest(g.metric_value, excluded.metric_value)
+                    when 'min' then least(g.metric_value, excluded.metric_value)
+                    else excluded.metric_value
+                end,
+                updated_at = excluded.updated_at
+        returning g.metric_type, g.metric_name, g.labels, g.metric_value, g.updated_at
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _names := _names || _row.metric_name;
+    end loop;
+
+    select json_agg(n) from unnest(_names) as n
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
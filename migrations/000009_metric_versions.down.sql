@@ -0,0 +1,135 @@
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _names text[] := '{}';
+    _item json;
+    _row metric.counters;
+    _op text;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.counters, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'inc');
+
+        insert into metric.counters as c (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb), _row.metric_value,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'set' then excluded.metric_value
+                    else c.metric_value + excluded.metric_value
+                end,
+                updated_at = excluded.updated_at
+        returning c.metric_type, c.metric_name, c.labels, c.metric_value, c.updated_at
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _names := _names || _row.metric_name;
+    end loop;
+
+    select json_agg(n) from unnest(_names) as n
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _names text[] := '{}';
+    _item json;
+    _row metric.gauges;
+    _op text;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.gauges, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'set');
+
+        insert into metric.gauges as g (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb),
+                case _op when 'dec' then -_row.metric_value else _row.metric_value end,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'inc' then g.metric_value + excluded.metric_value
+                    when 'dec' then g.metric_value + excluded.metric_value
+                    when 'max' then greatest(g.metric_value, excluded.metric_value)
+                    when 'min' then least(g.metric_value, excluded.metric_value)
+                    else excluded.metric_value
+                end,
+                updated_at = excluded.updated_at
+        returning g.metric_type, g.metric_name, g.labels, g.metric_value, g.updated_at
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _names := _names || _row.metric_name;
+    end loop;
+
+    select json_agg(n) from unnest(_names) as n
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.histograms_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.histograms, _items)
+        ),
+        ins_cte as (
+            insert into metric.histograms as h (metric_type, metric_name, labels, bounds, counts,
+                    sum, count, created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.bounds, cte.counts,
+                    cte.sum, cte.count, cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set counts = (
+                        select array_agg(a.c + b.c order by a.i)
+                            from unnest(h.counts) with ordinality as a(c, i)
+                            join unnest(excluded.counts) with ordinality as b(c, i) using (i)
+                    ),
+                    sum = h.sum + excluded.sum,
+                    count = h.count + excluded.count,
+                    updated_at = excluded.updated_at
+                -- histograms with different buckets cannot be merged
+                where h.bounds = excluded.bounds
+            returning h.metric_name
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+ALTER TABLE metric.counters DROP COLUMN IF EXISTS version;
+ALTER TABLE metric.gauges DROP COLUMN IF EXISTS version;
+ALTER TABLE metric.histograms DROP COLUMN IF EXISTS version;
@@ -0,0 +1,19 @@
+-- Returns the number of pending outbox items per destination and segment.
+CREATE OR REPLACE FUNCTION outbox.outbox_backlog() RETURNS json
+    LANGUAGE plpgsql
+    AS $$
+declare _res json;
+begin
+    with cte as (
+        select src.destination, src.segment, count(*) as count
+            from outbox.outbox as src
+            group by src.destination, src.segment
+    )
+    select json_agg(cte.*)
+        into _res
+        from cte
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$$;
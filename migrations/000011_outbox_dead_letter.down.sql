@@ -0,0 +1,60 @@
+DROP FUNCTION outbox.dead_letter_purge(text[], text);
+DROP FUNCTION outbox.dead_letter_requeue(text[], text);
+DROP FUNCTION outbox.dead_letter_list(text, integer);
+
+DROP FUNCTION outbox.outbox_get_next(text, text, integer, double precision);
+CREATE OR REPLACE FUNCTION outbox.outbox_get_next(_destination text, _segment text, _limit integer = 100) RETURNS json
+    LANGUAGE plpgsql
+    AS $$
+declare _res json;
+begin
+    perform pg_advisory_xact_lock(hashtext('outbox_'||_segment));
+
+    with
+        cte as (
+            select * 
+                from outbox.outbox as src
+                    where src.destination = _destination
+                        and src.segment = _segment
+                        and (src.lock_until is null or src.lock_until < now())
+                limit _limit
+        ),
+        upd_cte as (
+            update outbox.outbox as upd set
+                lock_until = now() + '30sec'::interval
+            from cte as src
+                where upd.id = src.id
+        )
+    select json_agg(src.*)
+        into _res
+        from cte as src
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$$;
+
+DROP FUNCTION outbox.outbox_commit(text[], json, text, integer, double precision, double precision);
+CREATE OR REPLACE FUNCTION outbox.outbox_commit(_ok_ids text[], _failed_ids text[], _segment text) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+begin
+    perform pg_advisory_xact_lock(hashtext('outbox_'||_segment));
+
+    delete from outbox.outbox as del
+        where del.id = any(_ok_ids)
+    ;
+
+    update outbox.outbox as upd set
+        lock_until = now()
+        where upd.id = any(_failed_ids)
+    ;
+end;
+$$;
+
+DROP TABLE IF EXISTS outbox.dead_letter;
+
+ALTER TABLE outbox.outbox
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS attempts;
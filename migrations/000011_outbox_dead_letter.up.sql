@@ -0,0 +1,173 @@
+ALTER TABLE outbox.outbox
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error TEXT,
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS outbox.dead_letter(
+    id TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    segment TEXT NOT NULL,
+    payload JSON NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE outbox.dead_letter
+    ADD CONSTRAINT dead_letter_pkey PRIMARY KEY (id);
+
+CREATE INDEX IF NOT EXISTS dead_letter_destination_idx
+    ON outbox.dead_letter (destination, created_at);
+
+DROP FUNCTION outbox.outbox_get_next(text, text, integer);
+CREATE OR REPLACE FUNCTION outbox.outbox_get_next(
+    _destination text, _segment text, _limit integer = 100, _lock_seconds double precision = 30
+) RETURNS json
+    LANGUAGE plpgsql
+    AS $$
+declare _res json;
+begin
+    perform pg_advisory_xact_lock(hashtext('outbox_'||_segment));
+
+    with
+        cte as (
+            select *
+                from outbox.outbox as src
+                    where src.destination = _destination
+                        and src.segment = _segment
+                        and (src.lock_until is null or src.lock_until < now())
+                        and (src.next_attempt_at is null or src.next_attempt_at <= now())
+                limit _limit
+        ),
+        upd_cte as (
+            update outbox.outbox as upd set
+                lock_until = now() + make_interval(secs => _lock_seconds)
+            from cte as src
+                where upd.id = src.id
+        )
+    select json_agg(src.*)
+        into _res
+        from cte as src
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$$;
+
+-- Deletes the delivered items and schedules the failed ones for redelivery with
+-- an exponentially growing delay. The items failed _max_attempts times are moved
+-- to the dead letter table, a non positive _max_attempts retries them forever.
+DROP FUNCTION outbox.outbox_commit(text[], text[], text);
+CREATE OR REPLACE FUNCTION outbox.outbox_commit(
+    _ok_ids text[], _failed json, _segment text,
+    _max_attempts integer = 10, _retry_seconds double precision = 1, _max_retry_seconds double precision = 3600
+) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+begin
+    perform pg_advisory_xact_lock(hashtext('outbox_'||_segment));
+
+    delete from outbox.outbox as del
+        where del.id = any(_ok_ids)
+    ;
+
+    with failed as (
+        select src->>'id' as id, src->>'error' as error
+            from json_array_elements(coalesce(_failed, '[]'::json)) as src
+    )
+    update outbox.outbox as upd set
+        attempts = upd.attempts + 1,
+        last_error = failed.error,
+        lock_until = null,
+        next_attempt_at = now() + make_interval(
+            secs => least(_retry_seconds * power(2, upd.attempts), _max_retry_seconds)
+        )
+        from failed
+            where upd.id = failed.id
+    ;
+
+    with dead as (
+        delete from outbox.outbox as del
+            where _max_attempts > 0
+                and del.segment = _segment
+                and del.attempts >= _max_attempts
+            returning del.id, del.destination, del.segment, del.payload, del.attempts, del.last_error
+    )
+    insert into outbox.dead_letter (id, destination, segment, payload, attempts, last_error)
+        select dead.id, dead.destination, dead.segment, dead.payload, dead.attempts, dead.last_error
+            from dead
+    ;
+end;
+$$;
+
+-- Returns the latest dead letters, the null destination matches any.
+CREATE OR REPLACE FUNCTION outbox.dead_letter_list(_destination text = NULL, _limit integer = 100) RETURNS json
+    LANGUAGE plpgsql
+    AS $$
+declare _res json;
+begin
+    with cte as (
+        select *
+            from outbox.dead_letter as src
+                where _destination is null or src.destination = _destination
+            order by src.created_at desc, src.id
+            limit _limit
+    )
+    select json_agg(cte.*)
+        into _res
+        from cte
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$$;
+
+-- Moves the dead letters back to the outbox with the attempts reset,
+-- the null arguments match any. Returns the ids of the requeued items.
+CREATE OR REPLACE FUNCTION outbox.dead_letter_requeue(_ids text[] = NULL, _destination text = NULL) RETURNS json
+    LANGUAGE plpgsql
+    AS $$
+declare _res json;
+begin
+    with
+        moved as (
+            delete from outbox.dead_letter as del
+                where (_ids is null or del.id = any(_ids))
+                    and (_destination is null or del.destination = _destination)
+                returning del.id, del.destination, del.segment, del.payload
+        ),
+        ins as (
+            insert into outbox.outbox (id, destination, segment, payload)
+                select moved.id, moved.destination, moved.segment, moved.payload
+                    from moved
+                returning id
+        )
+    select json_agg(ins.id)
+        into _res
+        from ins
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$$;
+
+-- Deletes the dead letters, the null arguments match any. Returns the ids of the deleted items.
+CREATE OR REPLACE FUNCTION outbox.dead_letter_purge(_ids text[] = NULL, _destination text = NULL) RETURNS json
+    LANGUAGE plpgsql
+    AS $$
+declare _res json;
+begin
+    with del_cte as (
+        delete from outbox.dead_letter as del
+            where (_ids is null or del.id = any(_ids))
+                and (_destination is null or del.destination = _destination)
+            returning del.id
+    )
+    select json_agg(del_cte.id)
+        into _res
+        from del_cte
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$$;
@@ -0,0 +1,7 @@
+UPDATE outbox.outbox
+    SET destination = substr(destination, length('audit:') + 1)
+    WHERE destination IN ('audit:file', 'audit:remote');
+
+UPDATE outbox.dead_letter
+    SET destination = substr(destination, length('audit:') + 1)
+    WHERE destination IN ('audit:file', 'audit:remote');
@@ -0,0 +1,8 @@
+-- The file and remote audit destinations became the sinks named after them.
+UPDATE outbox.outbox
+    SET destination = 'audit:' || destination
+    WHERE destination IN ('file', 'remote');
+
+UPDATE outbox.dead_letter
+    SET destination = 'audit:' || destination
+    WHERE destination IN ('file', 'remote');
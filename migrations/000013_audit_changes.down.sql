@@ -0,0 +1,191 @@
+CREATE OR REPLACE FUNCTION metric.metrics_upsert(
+    _counter_items json, _gauge_items json, _outbox_items json = NULL::json, _outbox_segment text = ''::text,
+    _histogram_items json = NULL::json
+)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $$
+declare
+    _res json;
+begin
+    with cte(metric_name) as (
+        select * from json_array_elements(metric.counters_upsert(_counter_items))
+        union all
+        select * from json_array_elements(metric.gauges_upsert(_gauge_items))
+        union all
+        select * from json_array_elements(metric.histograms_upsert(coalesce(_histogram_items, '[]'::json)))
+    )
+    select json_agg(cte.metric_name)
+	    into _res
+        from cte
+    ;
+
+    perform outbox.outbox_add_new(_outbox_items, _outbox_segment);
+
+    return coalesce(_res, '[]'::json);
+end;
+$$
+;
+
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _names text[] := '{}';
+    _item json;
+    _row metric.counters;
+    _op text;
+    _if_version bigint;
+    _inserted boolean;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.counters, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'inc');
+        _if_version := (_item->>'if_version')::bigint;
+
+        insert into metric.counters as c (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb), _row.metric_value,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'set' then excluded.metric_value
+                    else c.metric_value + excluded.metric_value
+                end,
+                version = c.version + 1,
+                updated_at = excluded.updated_at
+            where _if_version is null or c.version = _if_version
+        returning c.metric_type, c.metric_name, c.labels, c.metric_value, c.updated_at,
+                c.xmax = 0
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at,
+                _inserted;
+
+        -- a missing series has version 0
+        if not found or (_inserted and coalesce(_if_version, 0) <> 0) then
+            raise exception 'version conflict for %', _row.metric_name
+                using errcode = 'MV409';
+        end if;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _names := _names || _row.metric_name;
+    end loop;
+
+    select json_agg(n) from unnest(_names) as n
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _names text[] := '{}';
+    _item json;
+    _row metric.gauges;
+    _op text;
+    _if_version bigint;
+    _inserted boolean;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.gauges, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'set');
+        _if_version := (_item->>'if_version')::bigint;
+
+        insert into metric.gauges as g (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb),
+                case _op when 'dec' then -_row.metric_value else _row.metric_value end,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'inc' then g.metric_value + excluded.metric_value
+                    when 'dec' then g.metric_value + excluded.metric_value
+                    when 'max' then greatest(g.metric_value, excluded.metric_value)
+                    when 'min' then least(g.metric_value, excluded.metric_value)
+                    else excluded.metric_value
+                end,
+                version = g.version + 1,
+                updated_at = excluded.updated_at
+            where _if_version is null or g.version = _if_version
+        returning g.metric_type, g.metric_name, g.labels, g.metric_value, g.updated_at,
+                g.xmax = 0
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at,
+                _inserted;
+
+        -- a missing series has version 0
+        if not found or (_inserted and coalesce(_if_version, 0) <> 0) then
+            raise exception 'version conflict for %', _row.metric_name
+                using errcode = 'MV409';
+        end if;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _names := _names || _row.metric_name;
+    end loop;
+
+    select json_agg(n) from unnest(_names) as n
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.histograms_upsert(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with 
+        cte as (
+            select * from json_populate_recordset(null::metric.histograms, _items)
+        ),
+        ins_cte as (
+            insert into metric.histograms as h (metric_type, metric_name, labels, bounds, counts,
+                    sum, count, created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.bounds, cte.counts,
+                    cte.sum, cte.count, cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set counts = (
+                        select array_agg(a.c + b.c order by a.i)
+                            from unnest(h.counts) with ordinality as a(c, i)
+                            join unnest(excluded.counts) with ordinality as b(c, i) using (i)
+                    ),
+                    sum = h.sum + excluded.sum,
+                    count = h.count + excluded.count,
+                    version = h.version + 1,
+                    updated_at = excluded.updated_at
+                -- histograms with different buckets cannot be merged
+                where h.bounds = excluded.bounds
+            returning h.metric_name
+        )
+    select json_agg(ins_cte.metric_name) from ins_cte
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+DROP FUNCTION metric.histograms_upsert_changes(json);
+DROP FUNCTION metric.gauges_upsert_changes(json);
+DROP FUNCTION metric.counters_upsert_changes(json);
@@ -0,0 +1,273 @@
+-- The upserts return the changes of the series, the previous value is null for the new ones.
+CREATE OR REPLACE FUNCTION metric.counters_upsert_changes(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _changes json[] := '{}';
+    _item json;
+    _row metric.counters;
+    _op text;
+    _if_version bigint;
+    _inserted boolean;
+    _previous bigint;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.counters, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'inc');
+        _if_version := (_item->>'if_version')::bigint;
+
+        select c.metric_value into _previous
+            from metric.counters as c
+            where c.metric_name = _row.metric_name
+                and c.labels = coalesce(_row.labels, '{}'::jsonb)
+            for update;
+
+        insert into metric.counters as c (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb), _row.metric_value,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'set' then excluded.metric_value
+                    else c.metric_value + excluded.metric_value
+                end,
+                version = c.version + 1,
+                updated_at = excluded.updated_at
+            where _if_version is null or c.version = _if_version
+        returning c.metric_type, c.metric_name, c.labels, c.metric_value, c.version, c.updated_at,
+                c.xmax = 0
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.version, _row.updated_at,
+                _inserted;
+
+        -- a missing series has version 0
+        if not found or (_inserted and coalesce(_if_version, 0) <> 0) then
+            raise exception 'version conflict for %', _row.metric_name
+                using errcode = 'MV409';
+        end if;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _changes := _changes || json_build_object(
+            'type', _row.metric_type,
+            'name', _row.metric_name,
+            'labels', _row.labels,
+            'op', _op,
+            'submitted', _item->'metric_value',
+            'previous', case when _inserted then null else _previous end,
+            'result', _row.metric_value,
+            'version', _row.version
+        );
+    end loop;
+
+    return coalesce(array_to_json(_changes), '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert_changes(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _changes json[] := '{}';
+    _item json;
+    _row metric.gauges;
+    _op text;
+    _if_version bigint;
+    _inserted boolean;
+    _previous double precision;
+begin
+    perform metric.samples_ensure_partitions(_items);
+
+    for _item in select * from json_array_elements(_items)
+    loop
+        _row := json_populate_record(null::metric.gauges, _item);
+        _op := coalesce(nullif(_item->>'op', ''), 'set');
+        _if_version := (_item->>'if_version')::bigint;
+
+        select g.metric_value into _previous
+            from metric.gauges as g
+            where g.metric_name = _row.metric_name
+                and g.labels = coalesce(_row.labels, '{}'::jsonb)
+            for update;
+
+        insert into metric.gauges as g (metric_type, metric_name, labels, metric_value,
+                created_at, updated_at)
+        values (_row.metric_type, _row.metric_name, coalesce(_row.labels, '{}'::jsonb),
+                case _op when 'dec' then -_row.metric_value else _row.metric_value end,
+                _row.created_at, _row.updated_at)
+        on conflict (metric_name, labels) do update
+            set metric_value = case _op
+                    when 'inc' then g.metric_value + excluded.metric_value
+                    when 'dec' then g.metric_value + excluded.metric_value
+                    when 'max' then greatest(g.metric_value, excluded.metric_value)
+                    when 'min' then least(g.metric_value, excluded.metric_value)
+                    else excluded.metric_value
+                end,
+                version = g.version + 1,
+                updated_at = excluded.updated_at
+            where _if_version is null or g.version = _if_version
+        returning g.metric_type, g.metric_name, g.labels, g.metric_value, g.version, g.updated_at,
+                g.xmax = 0
+            into _row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.version, _row.updated_at,
+                _inserted;
+
+        -- a missing series has version 0
+        if not found or (_inserted and coalesce(_if_version, 0) <> 0) then
+            raise exception 'version conflict for %', _row.metric_name
+                using errcode = 'MV409';
+        end if;
+
+        insert into metric.samples (metric_type, metric_name, labels, metric_value, ts)
+        values (_row.metric_type, _row.metric_name, _row.labels, _row.metric_value, _row.updated_at);
+
+        _changes := _changes || json_build_object(
+            'type', _row.metric_type,
+            'name', _row.metric_name,
+            'labels', _row.labels,
+            'op', _op,
+            'submitted', _item->'metric_value',
+            'previous', case when _inserted then null else _previous end,
+            'result', _row.metric_value,
+            'version', _row.version
+        );
+    end loop;
+
+    return coalesce(array_to_json(_changes), '[]'::json);
+end;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.histograms_upsert_changes(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with
+        cte as (
+            select * from json_populate_recordset(null::metric.histograms, _items)
+        ),
+        prev_cte as (
+            select h.metric_name, h.labels, h.sum, h.count
+                from metric.histograms as h
+                join cte on h.metric_name = cte.metric_name
+                    and h.labels = coalesce(cte.labels, '{}'::jsonb)
+            for update of h
+        ),
+        ins_cte as (
+            insert into metric.histograms as h (metric_type, metric_name, labels, bounds, counts,
+                    sum, count, created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.bounds, cte.counts,
+                    cte.sum, cte.count, cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set counts = (
+                        select array_agg(a.c + b.c order by a.i)
+                            from unnest(h.counts) with ordinality as a(c, i)
+                            join unnest(excluded.counts) with ordinality as b(c, i) using (i)
+                    ),
+                    sum = h.sum + excluded.sum,
+                    count = h.count + excluded.count,
+                    version = h.version + 1,
+                    updated_at = excluded.updated_at
+                -- histograms with different buckets cannot be merged
+                where h.bounds = excluded.bounds
+            returning h.metric_type, h.metric_name, h.labels, h.sum, h.count, h.version
+        )
+    select json_agg(json_build_object(
+            'type', ins_cte.metric_type,
+            'name', ins_cte.metric_name,
+            'labels', ins_cte.labels,
+            'submitted', json_build_object('sum', cte.sum, 'count', cte.count),
+            'previous', case when prev_cte.metric_name is null then null
+                else json_build_object('sum', prev_cte.sum, 'count', prev_cte.count) end,
+            'result', json_build_object('sum', ins_cte.sum, 'count', ins_cte.count),
+            'version', ins_cte.version
+        ))
+        from ins_cte
+        join cte on cte.metric_name = ins_cte.metric_name
+            and coalesce(cte.labels, '{}'::jsonb) = ins_cte.labels
+        left join prev_cte on prev_cte.metric_name = ins_cte.metric_name
+            and prev_cte.labels = ins_cte.labels
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
+
+-- The single item upserts keep returning the names of the updated series.
+CREATE OR REPLACE FUNCTION metric.counters_upsert(_items json)
+ RETURNS json
+ LANGUAGE sql
+AS $function$
+    select coalesce(json_agg(c->'name'), '[]'::json)
+        from json_array_elements(metric.counters_upsert_changes(_items)) as c;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.gauges_upsert(_items json)
+ RETURNS json
+ LANGUAGE sql
+AS $function$
+    select coalesce(json_agg(c->'name'), '[]'::json)
+        from json_array_elements(metric.gauges_upsert_changes(_items)) as c;
+$function$
+;
+
+CREATE OR REPLACE FUNCTION metric.histograms_upsert(_items json)
+ RETURNS json
+ LANGUAGE sql
+AS $function$
+    select coalesce(json_agg(c->'name'), '[]'::json)
+        from json_array_elements(metric.histograms_upsert_changes(_items)) as c;
+$function$
+;
+
+-- The audit events of the batch get its changes, so they are built in the same transaction.
+CREATE OR REPLACE FUNCTION metric.metrics_upsert(
+    _counter_items json, _gauge_items json, _outbox_items json = NULL::json, _outbox_segment text = ''::text,
+    _histogram_items json = NULL::json
+)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $$
+declare
+    _res json;
+    _changes json;
+begin
+    with cte(change, ord) as (
+        select c, 1 from json_array_elements(metric.counters_upsert_changes(_counter_items)) as c
+        union all
+        select c, 2 from json_array_elements(metric.gauges_upsert_changes(_gauge_items)) as c
+        union all
+        select c, 3 from json_array_elements(metric.histograms_upsert_changes(coalesce(_histogram_items, '[]'::json))) as c
+    )
+    select json_agg(cte.change order by cte.ord), json_agg(cte.change->'name' order by cte.ord)
+	    into _changes, _res
+        from cte
+    ;
+
+    perform outbox.outbox_add_new(
+        (
+            select json_agg(case
+                    when o->>'destination' = 'audit'
+                        then jsonb_set(o::jsonb, '{payload,changes}', coalesce(_changes, '[]'::json)::jsonb)
+                    else o::jsonb
+                end)
+                from json_array_elements(coalesce(_outbox_items, '[]'::json)) as o
+        ),
+        _outbox_segment
+    );
+
+    return coalesce(_res, '[]'::json);
+end;
+$$
+;
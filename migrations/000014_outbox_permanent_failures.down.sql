@@ -0,0 +1,42 @@
+-- The permanent failures are retried again until _max_attempts.
+CREATE OR REPLACE FUNCTION outbox.outbox_commit(
+    _ok_ids text[], _failed json, _segment text,
+    _max_attempts integer = 10, _retry_seconds double precision = 1, _max_retry_seconds double precision = 3600
+) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+begin
+    perform pg_advisory_xact_lock(hashtext('outbox_'||_segment));
+
+    delete from outbox.outbox as del
+        where del.id = any(_ok_ids)
+    ;
+
+    with failed as (
+        select src->>'id' as id, src->>'error' as error
+            from json_array_elements(coalesce(_failed, '[]'::json)) as src
+    )
+    update outbox.outbox as upd set
+        attempts = upd.attempts + 1,
+        last_error = failed.error,
+        lock_until = null,
+        next_attempt_at = now() + make_interval(
+            secs => least(_retry_seconds * power(2, upd.attempts), _max_retry_seconds)
+        )
+        from failed
+            where upd.id = failed.id
+    ;
+
+    with dead as (
+        delete from outbox.outbox as del
+            where _max_attempts > 0
+                and del.segment = _segment
+                and del.attempts >= _max_attempts
+            returning del.id, del.destination, del.segment, del.payload, del.attempts, del.last_error
+    )
+    insert into outbox.dead_letter (id, destination, segment, payload, attempts, last_error)
+        select dead.id, dead.destination, dead.segment, dead.payload, dead.attempts, dead.last_error
+            from dead
+    ;
+end;
+$$;
@@ -0,0 +1,59 @@
+CREATE OR REPLACE FUNCTION metric.histograms_upsert_changes(_items json)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+begin
+    with
+        cte as (
+            select * from json_populate_recordset(null::metric.histograms, _items)
+        ),
+        prev_cte as (
+            select h.metric_name, h.labels, h.sum, h.count
+                from metric.histograms as h
+                join cte on h.metric_name = cte.metric_name
+                    and h.labels = coalesce(cte.labels, '{}'::jsonb)
+            for update of h
+        ),
+        ins_cte as (
+            insert into metric.histograms as h (metric_type, metric_name, labels, bounds, counts,
+                    sum, count, created_at, updated_at)
+            select cte.metric_type, cte.metric_name, coalesce(cte.labels, '{}'::jsonb), cte.bounds, cte.counts,
+                    cte.sum, cte.count, cte.created_at, cte.updated_at
+                from cte
+            on conflict (metric_name, labels) do update
+                set counts = (
+                        select array_agg(a.c + b.c order by a.i)
+                            from unnest(h.counts) with ordinality as a(c, i)
+                            join unnest(excluded.counts) with ordinality as b(c, i) using (i)
+                    ),
+                    sum = h.sum + excluded.sum,
+                    count = h.count + excluded.count,
+                    version = h.version + 1,
+                    updated_at = excluded.updated_at
+                -- histograms with different buckets cannot be merged
+                where h.bounds = excluded.bounds
+            returning h.metric_type, h.metric_name, h.labels, h.sum, h.count, h.version
+        )
+    select json_agg(json_build_object(
+            'type', ins_cte.metric_type,
+            'name', ins_cte.metric_name,
+            'labels', ins_cte.labels,
+            'submitted', json_build_object('sum', cte.sum, 'count', cte.count),
+            'previous', case when prev_cte.metric_name is null then null
+                else json_build_object('sum', prev_cte.sum, 'count', prev_cte.count) end,
+            'result', json_build_object('sum', ins_cte.sum, 'count', ins_cte.count),
+            'version', ins_cte.version
+        ))
+        from ins_cte
+        join cte on cte.metric_name = ins_cte.metric_name
+            and coalesce(cte.labels, '{}'::jsonb) = ins_cte.labels
+        left join prev_cte on prev_cte.metric_name = ins_cte.metric_name
+            and prev_cte.labels = ins_cte.labels
+	    into _res;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
@@ -0,0 +1 @@
+DROP FUNCTION outbox.outbox_fanout(json, text[], text);
@@ -0,0 +1,13 @@
+-- Replaces the source items with the items fanned out of them in one transaction,
+-- so that a crash in between neither loses nor duplicates the events.
+CREATE OR REPLACE FUNCTION outbox.outbox_fanout(_items json, _done_ids text[], _segment text) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+begin
+    perform outbox.outbox_add_new(coalesce(_items, '[]'::json), _segment);
+
+    delete from outbox.outbox as del
+        where del.id = any(_done_ids)
+    ;
+end;
+$$;
@@ -0,0 +1,64 @@
+DROP FUNCTION metric.metrics_delete(text, text, jsonb, text, timestamptz, json, text);
+DROP FUNCTION metric.series(text, jsonb);
+
+-- Deletes the matching metrics with their samples, the null arguments match any metric.
+-- Returns the keys of the deleted metrics.
+CREATE OR REPLACE FUNCTION metric.metrics_delete(
+    _metric_type text = NULL, _metric_name text = NULL, _labels jsonb = NULL,
+    _prefix text = NULL, _updated_before timestamptz = NULL
+)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _res json;
+    _labels_eq jsonb := coalesce(nullif(_labels, 'null'::jsonb), '{}'::jsonb);
+begin
+    with
+        counter_cte as (
+            delete from metric.counters as c
+                where (_metric_type is null or c.metric_type = _metric_type)
+                    and (_metric_name is null or (c.metric_name = _metric_name and c.labels = _labels_eq))
+                    and (_prefix is null or starts_with(c.metric_name, _prefix))
+                    and (_updated_before is null or c.updated_at < _updated_before)
+            returning c.metric_type, c.metric_name, c.labels
+        ),
+        gauge_cte as (
+            delete from metric.gauges as g
+                where (_metric_type is null or g.metric_type = _metric_type)
+                    and (_metric_name is null or (g.metric_name = _metric_name and g.labels = _labels_eq))
+                    and (_prefix is null or starts_with(g.metric_name, _prefix))
+                    and (_updated_before is null or g.updated_at < _updated_before)
+            returning g.metric_type, g.metric_name, g.labels
+        ),
+        histogram_cte as (
+            delete from metric.histograms as h
+                where (_metric_type is null or h.metric_type = _metric_type)
+                    and (_metric_name is null or (h.metric_name = _metric_name and h.labels = _labels_eq))
+                    and (_prefix is null or starts_with(h.metric_name, _prefix))
+                    and (_updated_before is null or h.updated_at < _updated_before)
+            returning h.metric_type, h.metric_name, h.labels
+        ),
+        deleted_cte as (
+            select * from counter_cte
+            union all
+            select * from gauge_cte
+            union all
+            select * from histogram_cte
+        ),
+        sample_cte as (
+            delete from metric.samples as s
+                using deleted_cte as d
+                where s.metric_type = d.metric_type
+                    and s.metric_name = d.metric_name
+                    and s.labels = d.labels
+        )
+    select json_agg(d.* order by d.metric_name, d.labels::text)
+        into _res
+        from deleted_cte as d
+    ;
+
+    return coalesce(_res, '[]'::json);
+end;
+$function$
+;
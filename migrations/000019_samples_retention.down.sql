@@ -0,0 +1 @@
+DROP FUNCTION metric.samples_drop_expired(timestamptz);
@@ -0,0 +1,29 @@
+-- Drops the daily partitions of metric.samples which end before _before.
+-- Returns the names of the dropped partitions.
+CREATE OR REPLACE FUNCTION metric.samples_drop_expired(_before timestamptz)
+ RETURNS json
+ LANGUAGE plpgsql
+AS $function$
+declare
+    _partition text;
+    _res text[] := '{}';
+begin
+    for _partition in
+        select c.relname
+            from pg_inherits as i
+                join pg_class as c on c.oid = i.inhrelid
+            where i.inhparent = 'metric.samples'::regclass
+                and c.relname ~ '^samples_[0-9]{8}$'
+                and ((to_date(substr(c.relname, 9), 'YYYYMMDD') + 1)::timestamp at time zone 'UTC') <= _before
+            order by c.relname
+    loop
+        perform pg_advisory_xact_lock(hashtext('metric_' || _partition));
+
+        execute format('drop table if exists metric.%I', _partition);
+        _res := _res || _partition;
+    end loop;
+
+    return to_json(_res);
+end;
+$function$
+;
@@ -6,6 +6,12 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg/selfmetrics"
 )
 
 // ErrorClassification defines whether an error is retriable.
@@ -41,6 +47,8 @@ func NewBackoff(
 
 // WithLinear returns a decorator that retries the function with linear backoff.
 // The initial delay is t0 and increases by dt on each retry.
+// Every failed attempt is added as an event to the span of the context, if any,
+// and the retries are counted in the default selfmetrics registry.
 func (r *Backoff) WithLinear(t0 time.Duration, dt time.Duration) func(retried) retried {
 	return func(fn retried) retried {
 		return func(ctx context.Context) error {
@@ -55,15 +63,25 @@ func (r *Backoff) WithLinear(t0 time.Duration, dt time.Duration) func(retried) r
 						return nil
 					}
 
-					if r.errClassifyFunc(err) == NonRetriable {
+					classification := r.errClassifyFunc(err)
+					trace.SpanFromContext(ctx).AddEvent("attempt failed", trace.WithAttributes(
+						attribute.Int("attempt", int(attempt)+1),
+						attribute.Bool("retriable", classification == Retriable),
+						attribute.String("error", err.Error()),
+					))
+
+					if classification == NonRetriable {
+						selfmetrics.Inc("backoff_giveups_total", pkg.Labels{"reason": "non_retriable"})
 						return fmt.Errorf("non retriable, %w", err)
 					}
 
 					log.Printf("attempt #%d failed: %v", attempt+1, err)
 					if attempt < r.maxRetries {
+						selfmetrics.Inc("backoff_retries_total", nil)
 						log.Printf("retrying in %vs...", delay.Seconds())
 						time.Sleep(delay)
 					} else {
+						selfmetrics.Inc("backoff_giveups_total", pkg.Labels{"reason": "max_attempts"})
 						return fmt.Errorf("max attempts reached, %w", err)
 					}
 				}
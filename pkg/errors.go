@@ -26,16 +26,67 @@ var ErrBadRequest = &Error{
 	Status:  http.StatusBadRequest,
 }
 
+// ErrConflict represents a conflict with the current state of the resource.
+var ErrConflict = &Error{
+	Message: "Conflict",
+	Code:    "CONFLICT",
+	Status:  http.StatusConflict,
+}
+
+// ErrRequestTooLarge represents a request body over the accepted size.
+var ErrRequestTooLarge = &Error{
+	Message: "Request entity too large",
+	Code:    "REQUEST_TOO_LARGE",
+	Status:  http.StatusRequestEntityTooLarge,
+}
+
+// ErrUnsupportedMediaType represents a request body in an unsupported format.
+var ErrUnsupportedMediaType = &Error{
+	Message: "Unsupported media type",
+	Code:    "UNSUPPORTED_MEDIA_TYPE",
+	Status:  http.StatusUnsupportedMediaType,
+}
+
+// ErrUnavailable represents a temporarily unavailable dependency.
+var ErrUnavailable = &Error{
+	Message: "Service unavailable",
+	Code:    "UNAVAILABLE",
+	Status:  http.StatusServiceUnavailable,
+}
+
 // allowStatusError defines allowed HTTP status codes for errors.
 var allowStatusError = map[int]struct{}{
-	http.StatusInternalServerError: {},
-	http.StatusNotFound:            {},
-	http.StatusBadRequest:          {},
+	http.StatusInternalServerError:   {},
+	http.StatusNotFound:              {},
+	http.StatusBadRequest:            {},
+	http.StatusUnauthorized:          {},
+	http.StatusForbidden:             {},
+	http.StatusConflict:              {},
+	http.StatusRequestEntityTooLarge: {},
+	http.StatusUnsupportedMediaType:  {},
+	http.StatusTooManyRequests:       {},
+	http.StatusServiceUnavailable:    {},
 }
 
 // ErrorCode represents a unique error code identifier.
 type ErrorCode string
 
+// Error codes of the invalid metric input, shared by the services so that
+// clients can tell the problems apart regardless of the message.
+const (
+	CodeInvalidMetricType  ErrorCode = "INVALID_METRIC_TYPE"
+	CodeInvalidMetricName  ErrorCode = "INVALID_METRIC_NAME"
+	CodeInvalidMetricValue ErrorCode = "INVALID_METRIC_VALUE"
+	CodeInvalidLabels      ErrorCode = "INVALID_LABELS"
+	CodeInvalidOp          ErrorCode = "INVALID_OP"
+	CodeMetricNotFound     ErrorCode = "METRIC_NOT_FOUND"
+	CodeVersionConflict    ErrorCode = "VERSION_CONFLICT"
+	CodeVersionMismatch    ErrorCode = "VERSION_MISMATCH"
+	CodeInvalidVersion     ErrorCode = "INVALID_VERSION"
+	CodeOpConflict         ErrorCode = "OP_CONFLICT"
+	CodeMergeConflict      ErrorCode = "MERGE_CONFLICT"
+)
+
 // Error represents an application error with HTTP status code support.
 type Error struct {
 	Message string
@@ -79,6 +130,16 @@ func (e *Error) SetInfo(s string) *Error {
 	}
 }
 
+// SetCode creates a new error of the same kind with a more specific code.
+func (e *Error) SetCode(code ErrorCode) *Error {
+	return &Error{
+		Message: e.Message,
+		Code:    code,
+		Status:  e.Status,
+		Info:    e.Info,
+	}
+}
+
 // SetInfof creates a new error with formatted additional information.
 func (e *Error) SetInfof(s string, v ...any) *Error {
 	return e.SetInfo(fmt.Sprintf(s, v...))
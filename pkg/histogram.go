@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"math"
+	"slices"
+)
+
+// DefaultBuckets are the upper bounds used when no buckets are configured,
+// suited to request latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram represents a distribution of observed values over fixed buckets.
+// Counts holds one non-cumulative count per bound plus a trailing +Inf bucket.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// NewHistogram creates an empty histogram with the given bucket upper bounds.
+func NewHistogram(bounds []float64) Histogram {
+	return Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Valid reports whether the bounds are finite and strictly increasing
+// and the counts match the bounds, the sum and the total count.
+func (h Histogram) Valid() bool {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return false
+	}
+	for i, bound := range h.Bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) || (i > 0 && bound <= h.Bounds[i-1]) {
+			return false
+		}
+	}
+	if math.IsNaN(h.Sum) || math.IsInf(h.Sum, 0) {
+		return false
+	}
+
+	var total uint64
+	for _, count := range h.Counts {
+		total += count
+	}
+	return total == h.Count
+}
+
+// Observe adds a single value to the histogram.
+func (h *Histogram) Observe(value float64) {
+	i, _ := slices.BinarySearch(h.Bounds, value)
+	h.Counts[i]++
+	h.Sum += value
+	h.Count++
+}
+
+// Merge adds the other histogram to h. It reports false, leaving h untouched,
+// when the bucket bounds differ.
+func (h *Histogram) Merge(other Histogram) bool {
+	if !slices.Equal(h.Bounds, other.Bounds) || len(h.Counts) != len(other.Counts) {
+		return false
+	}
+
+	for i, count := range other.Counts {
+		h.Counts[i] += count
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return true
+}
+
+// Quantile estimates the q-quantile, 0 <= q <= 1, by linear interpolation
+// within the bucket holding it, the way Prometheus histogram_quantile does.
+// Values in the +Inf bucket are reported as the highest finite bound.
+func (h Histogram) Quantile(q float64) float64 {
+	if h.Count == 0 || math.IsNaN(q) || q < 0 || q > 1 {
+		return math.NaN()
+	}
+
+	rank := q * float64(h.Count)
+
+	var cumulative uint64
+	for i, count := range h.Counts {
+		if float64(cumulative+count) < rank || count == 0 {
+			cumulative += count
+			continue
+		}
+
+		if i == len(h.Bounds) {
+			break
+		}
+
+		lower := 0.0
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		} else if h.Bounds[0] < 0 {
+			return h.Bounds[0]
+		}
+		upper := h.Bounds[i]
+
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(count)
+	}
+
+	if len(h.Bounds) == 0 {
+		return math.NaN()
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
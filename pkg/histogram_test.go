@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram_Valid(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Histogram
+		want bool
+	}{
+		{name: "empty", h: NewHistogram([]float64{1, 2}), want: true},
+		{name: "no bounds", h: Histogram{Counts: []uint64{3}, Count: 3}, want: true},
+		{name: "counts", h: Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 3}, Sum: 10, Count: 6}, want: true},
+		{name: "counts length", h: Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2}, Count: 3}},
+		{name: "total count", h: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 4}},
+		{name: "unsorted bounds", h: Histogram{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}}},
+		{name: "equal bounds", h: Histogram{Bounds: []float64{1, 1}, Counts: []uint64{0, 0, 0}}},
+		{name: "nan bound", h: Histogram{Bounds: []float64{math.NaN()}, Counts: []uint64{0, 0}}},
+		{name: "inf bound", h: Histogram{Bounds: []float64{math.Inf(1)}, Counts: []uint64{0, 0}}},
+		{name: "nan sum", h: Histogram{Bounds: []float64{1}, Counts: []uint64{0, 0}, Sum: math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.h.Valid())
+		})
+	}
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{1, 2})
+	for _, v := range []float64{0.5, 1, 1.5, 3} {
+		h.Observe(v)
+	}
+
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, 6.0, h.Sum)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.True(t, h.Valid())
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	h := Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 2}, Sum: 7, Count: 3}
+
+	assert.True(t, h.Merge(Histogram{Bounds: []float64{1, 2}, Counts: []uint64{0, 4, 1}, Sum: 9, Count: 5}))
+	assert.Equal(t, Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 4, 3}, Sum: 16, Count: 8}, h)
+
+	before := Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 4, 3}, Sum: 16, Count: 8}
+	assert.False(t, h.Merge(Histogram{Bounds: []float64{1, 3}, Counts: []uint64{1, 1, 1}, Sum: 5, Count: 3}))
+	assert.False(t, h.Merge(Histogram{Bounds: []float64{1}, Counts: []uint64{1, 1}, Sum: 2, Count: 2}))
+	assert.Equal(t, before, h)
+}
+
+func TestHistogram_Quantile(t *testing.T) {
+	h := Histogram{Bounds: []float64{1, 2, 4}, Counts: []uint64{2, 2, 0, 0}, Count: 4}
+
+	tests := []struct {
+		name string
+		h    Histogram
+		q    float64
+		want float64
+	}{
+		{name: "zero", h: h, q: 0, want: 0},
+		{name: "median", h: h, q: 0.5, want: 1},
+		{name: "interpolated", h: h, q: 0.75, want: 1.5},
+		{name: "max", h: h, q: 1, want: 2},
+		{name: "inf bucket", h: Histogram{Bounds: []float64{1, 2, 4}, Counts: []uint64{1, 0, 0, 1}, Count: 2}, q: 1, want: 4},
+		{name: "negative bound", h: Histogram{Bounds: []float64{-1, 1}, Counts: []uint64{1, 0, 0}, Count: 1}, q: 0.5, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.h.Quantile(tt.q), 1e-9)
+		})
+	}
+
+	assert.True(t, math.IsNaN(NewHistogram([]float64{1}).Quantile(0.5)), "empty")
+	assert.True(t, math.IsNaN(h.Quantile(-0.1)), "below range")
+	assert.True(t, math.IsNaN(h.Quantile(1.1)), "above range")
+	assert.True(t, math.IsNaN(h.Quantile(math.NaN())), "nan")
+}
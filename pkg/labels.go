@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels represents an optional set of metric dimensions, e.g. host or service.
+type Labels map[string]string
+
+// Names returns the label names in sorted order.
+func (l Labels) Names() []string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// String returns the canonical `{a="1",b="2"}` form of the label set,
+// or an empty string when there are no labels.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range l.Names() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[name]))
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// Series returns the metric name together with its canonical label set,
+// which uniquely identifies a stored metric.
+func (l Labels) Series(name string) string {
+	return name + l.String()
+}
+
+// Match reports whether every label of the filter is present with the same value.
+func (l Labels) Match(filter Labels) bool {
+	for name, value := range filter {
+		if v, ok := l[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether both label sets hold the same labels.
+func (l Labels) Equal(other Labels) bool {
+	return len(l) == len(other) && l.Match(other)
+}
+
+// Valid reports whether every label name is a valid identifier
+// that does not use the reserved `__` prefix.
+func (l Labels) Valid() bool {
+	for name := range l {
+		if !labelNameRe.MatchString(name) || strings.HasPrefix(name, "__") {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabels parses a comma separated `name=value` list, e.g. `host=a,dc=b`.
+func ParseLabels(s string) (Labels, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	labels := make(Labels)
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid label %q", pair)
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+
+	if !labels.Valid() {
+		return nil, fmt.Errorf("invalid labels %q", s)
+	}
+	return labels, nil
+}
@@ -1,5 +1,7 @@
 package pkg
 
+import "strings"
+
 // MetricType represents the type of a metric.
 type MetricType = string
 
@@ -8,4 +10,85 @@ const (
 	MetricTypeGauge MetricType = "gauge"
 	// MetricTypeCounter represents a counter metric type.
 	MetricTypeCounter MetricType = "counter"
+	// MetricTypeHistogram represents a histogram metric type.
+	MetricTypeHistogram MetricType = "histogram"
 )
+
+// SelfMetricPrefix starts the names of the operational metrics the server stores about itself.
+// The prefix is reserved, such metrics cannot be updated by clients.
+const SelfMetricPrefix = "__server_"
+
+// ReservedName reports whether the metric name starts with the reserved prefix.
+func ReservedName(name string) bool {
+	return strings.HasPrefix(name, SelfMetricPrefix)
+}
+
+// ValidName reports whether the metric name is free of the characters
+// of the label set syntax, so that Labels.Series stays unique.
+func ValidName(name string) bool {
+	return !strings.ContainsAny(name, `{}"`)
+}
+
+// MetricOp represents an operation applied to the stored value of a metric.
+type MetricOp = string
+
+const (
+	// OpSet overwrites the stored value.
+	OpSet MetricOp = "set"
+	// OpInc adds the value to the stored one.
+	OpInc MetricOp = "inc"
+	// OpDec subtracts the value from the stored one.
+	OpDec MetricOp = "dec"
+	// OpMax keeps the greater of the values.
+	OpMax MetricOp = "max"
+	// OpMin keeps the lesser of the values.
+	OpMin MetricOp = "min"
+)
+
+// DefaultOp returns the operation used when none is given:
+// counters are incremented and gauges are overwritten.
+func DefaultOp(metricType MetricType) MetricOp {
+	if metricType == MetricTypeCounter {
+		return OpInc
+	}
+	return OpSet
+}
+
+// ValidOp reports whether the operation applies to the metric type,
+// the empty operation is always valid.
+func ValidOp(metricType MetricType, op MetricOp) bool {
+	if op == "" {
+		return true
+	}
+
+	switch metricType {
+	case MetricTypeCounter:
+		return op == OpInc || op == OpSet
+	case MetricTypeGauge:
+		return op == OpSet || op == OpInc || op == OpDec || op == OpMax || op == OpMin
+	}
+	return false
+}
+
+// ApplyCounterOp returns the counter value after the operation.
+func ApplyCounterOp(op MetricOp, current, value int64) int64 {
+	if op == OpSet {
+		return value
+	}
+	return current + value
+}
+
+// ApplyGaugeOp returns the gauge value after the operation.
+func ApplyGaugeOp(op MetricOp, current, value float64) float64 {
+	switch op {
+	case OpInc:
+		return current + value
+	case OpDec:
+		return current - value
+	case OpMax:
+		return max(current, value)
+	case OpMin:
+		return min(current, value)
+	}
+	return value
+}
@@ -0,0 +1,15 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidName(t *testing.T) {
+	assert.True(t, ValidName("requests_total"))
+	assert.True(t, ValidName("http.requests:rate"))
+	assert.False(t, ValidName(`a{x="1"}`), "collides with the series a labeled x=1")
+	assert.False(t, ValidName("a}"))
+	assert.False(t, ValidName(`a"`))
+}
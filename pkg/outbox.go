@@ -0,0 +1,27 @@
+package pkg
+
+import (
+	"hash/fnv"
+	"strconv"
+)
+
+// OutboxSegment returns the segment the outbox items of the key go to when
+// they are spread over n segments.
+func OutboxSegment(key string, n int) string {
+	if n <= 1 {
+		return OutboxSegmentName(0)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return OutboxSegmentName(int(h.Sum32() % uint32(n)))
+}
+
+// OutboxSegmentName returns the name of the i-th outbox segment. The first one
+// is empty, so the items written before the sharding was enabled are consumed.
+func OutboxSegmentName(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
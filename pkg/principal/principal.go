@@ -0,0 +1,45 @@
+// Package principal carries the acting principal of the request through
+// contexts, so that the audit events can tell who made the change.
+package principal
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+const (
+	// Anonymous is the principal of the requests neither signed nor authenticated by a certificate.
+	Anonymous = "anonymous"
+	// Signed is the principal of the requests signed with the shared hash key.
+	Signed = "hmac"
+
+	certPrefix = "cert:"
+)
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of the context carrying the principal.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, principal)
+}
+
+// FromContext returns the principal of the context, or an empty string for
+// the actions of the server itself.
+func FromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(ctxKey{}).(string)
+	return principal
+}
+
+// Resolve picks the common name of the verified client certificate, then Signed
+// when the request was signed with the shared key, and Anonymous otherwise.
+func Resolve(state *tls.ConnectionState, signed bool) string {
+	if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		if name := state.VerifiedChains[0][0].Subject.CommonName; name != "" {
+			return certPrefix + name
+		}
+	}
+	if signed {
+		return Signed
+	}
+	return Anonymous
+}
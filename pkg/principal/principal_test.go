@@ -0,0 +1,40 @@
+package principal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	verified := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "agent-1"}}}},
+	}
+
+	tests := []struct {
+		name   string
+		state  *tls.ConnectionState
+		signed bool
+		want   string
+	}{
+		{name: "certificate", state: verified, signed: true, want: "cert:agent-1"},
+		{name: "unverified certificate", state: &tls.ConnectionState{}, signed: true, want: Signed},
+		{name: "signed", signed: true, want: Signed},
+		{name: "anonymous", want: Anonymous},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Resolve(tt.state, tt.signed))
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, Signed, FromContext(WithPrincipal(context.Background(), Signed)))
+}
@@ -0,0 +1,113 @@
+// Package requestid carries the request correlation ID through contexts
+// and maps it to the X-Request-ID and W3C traceparent headers.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// Header is the HTTP header carrying the request ID.
+	Header = "X-Request-ID"
+	// TraceparentHeader is the W3C trace context header.
+	TraceparentHeader = "traceparent"
+	// MetadataKey is the gRPC metadata key carrying the request ID.
+	MetadataKey = "x-request-id"
+
+	maxLength = 128
+)
+
+type ctxKey struct{}
+
+// New returns a random ID that is also a valid W3C trace ID.
+func New() string {
+	return randomHex(16)
+}
+
+// WithID returns a copy of the context carrying the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID of the context, or an empty string.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Resolve picks the request ID from the X-Request-ID value, then from the trace ID
+// of the traceparent value, and generates a new one when neither is valid.
+func Resolve(requestID, traceparent string) string {
+	if Valid(requestID) {
+		return requestID
+	}
+	if traceID, _, ok := ParseTraceparent(traceparent); ok {
+		return traceID
+	}
+	return New()
+}
+
+// Valid reports whether the ID is short and made of safe characters only,
+// so that it can be logged and put into headers as is.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTraceparent parses a version 00 traceparent header, e.g.
+// `00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01`.
+func ParseTraceparent(s string) (traceID, parentID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", "", false
+	}
+
+	traceID, parentID = parts[1], parts[2]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(parts[3], 2) {
+		return "", "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", "", false
+	}
+	return traceID, parentID, true
+}
+
+// Traceparent returns a sampled traceparent header for the trace ID with a new span ID,
+// or an empty string when the ID is not a valid trace ID.
+func Traceparent(traceID string) string {
+	if !isHex(traceID, 32) {
+		return ""
+	}
+	return "00-" + traceID + "-" + randomHex(8) + "-01"
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,60 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		traceID     string
+		parentID    string
+		ok          bool
+	}{
+		{
+			name:        "valid",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+			parentID:    "00f067aa0ba902b7",
+			ok:          true,
+		},
+		{name: "unknown version", traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "upper case", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "short", traceparent: "00-4bf92f35-00f067aa0ba902b7-01"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traceID, parentID, ok := ParseTraceparent(tt.traceparent)
+
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.traceID, traceID)
+			assert.Equal(t, tt.parentID, parentID)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	assert.Equal(t, "req-1", Resolve("req-1", traceparent))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", Resolve("", traceparent))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", Resolve("bad id' --", traceparent))
+
+	id := Resolve("", "")
+	assert.Len(t, id, 32)
+
+	_, _, ok := ParseTraceparent(Traceparent(id))
+	assert.True(t, ok)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, "req-1", FromContext(WithID(context.Background(), "req-1")))
+}
@@ -0,0 +1,161 @@
+// Package selfmetrics provides an in-process registry of the operational metrics
+// of the application itself, such as request rates, latencies and retries.
+package selfmetrics
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+// Counter is a snapshot of a monotonically increasing series.
+type Counter struct {
+	Name   string
+	Labels pkg.Labels
+	Value  int64
+}
+
+// Gauge is a snapshot of a series holding the last set value.
+type Gauge struct {
+	Name   string
+	Labels pkg.Labels
+	Value  float64
+}
+
+// Histogram is a snapshot of a series of observations.
+type Histogram struct {
+	Name   string
+	Labels pkg.Labels
+	Value  pkg.Histogram
+}
+
+// Snapshot holds every series of the registry ordered by series key.
+type Snapshot struct {
+	Counters   []Counter
+	Gauges     []Gauge
+	Histograms []Histogram
+}
+
+// Registry stores the series recorded by the application. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	counters   map[string]*Counter
+	gauges     map[string]*Gauge
+	histograms map[string]*Histogram
+}
+
+// Default is the registry the package level functions record into.
+var Default = New()
+
+// New creates an empty registry.
+func New() *Registry {
+	return &Registry{
+		counters:   make(map[string]*Counter),
+		gauges:     make(map[string]*Gauge),
+		histograms: make(map[string]*Histogram),
+	}
+}
+
+// Add adds the delta to the counter.
+func (r *Registry) Add(name string, labels pkg.Labels, delta int64) {
+	key := labels.Series(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	x, ok := r.counters[key]
+	if !ok {
+		x = &Counter{Name: name, Labels: maps.Clone(labels)}
+		r.counters[key] = x
+	}
+	x.Value += delta
+}
+
+// Inc adds one to the counter.
+func (r *Registry) Inc(name string, labels pkg.Labels) {
+	r.Add(name, labels, 1)
+}
+
+// Set sets the gauge to the value.
+func (r *Registry) Set(name string, labels pkg.Labels, value float64) {
+	key := labels.Series(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	x, ok := r.gauges[key]
+	if !ok {
+		x = &Gauge{Name: name, Labels: maps.Clone(labels)}
+		r.gauges[key] = x
+	}
+	x.Value = value
+}
+
+// Observe adds the value to the histogram, which uses the default buckets.
+func (r *Registry) Observe(name string, labels pkg.Labels, value float64) {
+	key := labels.Series(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	x, ok := r.histograms[key]
+	if !ok {
+		x = &Histogram{Name: name, Labels: maps.Clone(labels), Value: pkg.NewHistogram(pkg.DefaultBuckets)}
+		r.histograms[key] = x
+	}
+	x.Value.Observe(value)
+}
+
+// ObserveSince adds the seconds elapsed since the start to the histogram.
+func (r *Registry) ObserveSince(name string, labels pkg.Labels, start time.Time) {
+	r.Observe(name, labels, time.Since(start).Seconds())
+}
+
+// Snapshot returns a copy of every series.
+func (r *Registry) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var s Snapshot
+	for _, key := range slices.Sorted(maps.Keys(r.counters)) {
+		s.Counters = append(s.Counters, *r.counters[key])
+	}
+	for _, key := range slices.Sorted(maps.Keys(r.gauges)) {
+		s.Gauges = append(s.Gauges, *r.gauges[key])
+	}
+	for _, key := range slices.Sorted(maps.Keys(r.histograms)) {
+		x := *r.histograms[key]
+		x.Value.Bounds = slices.Clone(x.Value.Bounds)
+		x.Value.Counts = slices.Clone(x.Value.Counts)
+		s.Histograms = append(s.Histograms, x)
+	}
+	return s
+}
+
+// Add adds the delta to the counter of the default registry.
+func Add(name string, labels pkg.Labels, delta int64) {
+	Default.Add(name, labels, delta)
+}
+
+// Inc adds one to the counter of the default registry.
+func Inc(name string, labels pkg.Labels) {
+	Default.Inc(name, labels)
+}
+
+// Set sets the gauge of the default registry to the value.
+func Set(name string, labels pkg.Labels, value float64) {
+	Default.Set(name, labels, value)
+}
+
+// Observe adds the value to the histogram of the default registry.
+func Observe(name string, labels pkg.Labels, value float64) {
+	Default.Observe(name, labels, value)
+}
+
+// ObserveSince adds the seconds elapsed since the start to the histogram of the default registry.
+func ObserveSince(name string, labels pkg.Labels, start time.Time) {
+	Default.ObserveSince(name, labels, start)
+}
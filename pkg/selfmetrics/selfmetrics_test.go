@@ -0,0 +1,53 @@
+package selfmetrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/MaksimMakarenko1001/ya-go-advanced/pkg"
+)
+
+func TestRegistry(t *testing.T) {
+	r := New()
+
+	r.Inc("requests_total", pkg.Labels{"route": "/b"})
+	r.Add("requests_total", pkg.Labels{"route": "/a"}, 2)
+	r.Inc("requests_total", pkg.Labels{"route": "/b"})
+	r.Set("backlog", nil, 3)
+	r.Set("backlog", nil, 1)
+	r.Observe("duration_seconds", nil, 0.2)
+	r.Observe("duration_seconds", nil, 20)
+
+	s := r.Snapshot()
+
+	require.Len(t, s.Counters, 2)
+	assert.Equal(t, Counter{Name: "requests_total", Labels: pkg.Labels{"route": "/a"}, Value: 2}, s.Counters[0])
+	assert.Equal(t, Counter{Name: "requests_total", Labels: pkg.Labels{"route": "/b"}, Value: 2}, s.Counters[1])
+
+	require.Len(t, s.Gauges, 1)
+	assert.Equal(t, 1.0, s.Gauges[0].Value)
+
+	require.Len(t, s.Histograms, 1)
+	h := s.Histograms[0].Value
+	assert.Equal(t, uint64(2), h.Count)
+	assert.Equal(t, 20.2, h.Sum)
+	assert.Equal(t, uint64(1), h.Counts[len(h.Counts)-1])
+
+	r.Observe("duration_seconds", nil, 0.2)
+	assert.Equal(t, uint64(2), h.Count, "snapshot must not change")
+	assert.Equal(t, uint64(1), h.Counts[5], "snapshot must not change")
+}
+
+func TestRegistry_LabelsCopied(t *testing.T) {
+	r := New()
+
+	labels := pkg.Labels{"route": "/a"}
+	r.Inc("requests_total", labels)
+	labels["status"] = "200"
+
+	s := r.Snapshot()
+	require.Len(t, s.Counters, 1)
+	assert.Equal(t, pkg.Labels{"route": "/a"}, s.Counters[0].Labels)
+}